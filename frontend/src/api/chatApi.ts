@@ -1,8 +1,10 @@
 import { fetchChatHistory } from "../db/messages";
-import { MessageContent, Provider } from "../utils/types/types";
+import { ChatReply, Citation, MessageContent, Provider, Source } from "../utils/types/types";
 
 type ChatAPIMessage = { role: "user" | "assistant"; content: MessageContent; ts?: number };
 
+// sendChatMessage streams the answer from /api/chat/stream, calling onDelta with the text so far,
+// and falls back to /api/chat when the server has no streaming endpoint
 export const sendChatMessage = async (
   sessionID: string,
   modelID: string,
@@ -10,37 +12,136 @@ export const sendChatMessage = async (
   sharedContext: boolean,
   tokens: number,
   systemPrompt: string,
-): Promise<string> => {
+  onDelta?: (text: string) => void,
+): Promise<ChatReply> => {
   const context = await buildContext(sessionID, modelID, sharedContext);
 
+  const body = JSON.stringify({
+    modelID,
+    provider,
+    context,
+    tokens,
+    systemPrompt
+  });
+
+  const res = await fetch("/api/chat/stream", {
+    method: "POST",
+    headers: { "Content-Type": "application/json", Accept: "text/event-stream" },
+    body,
+  });
+
+  if (STREAM_UNAVAILABLE.has(res.status) || (res.ok && !res.body)) {
+    return postChat(body);
+  }
+
+  if (!res.ok) {
+    throw await responseError(res);
+  }
+
+  return readChatStream(res.body!, onDelta);
+};
+
+// Statuses meaning the server can't stream, rather than that the chat failed
+const STREAM_UNAVAILABLE = new Set([404, 405, 501]);
+
+async function postChat(body: string): Promise<ChatReply> {
   const res = await fetch("/api/chat", {
     method: "POST",
     headers: { "Content-Type": "application/json" },
-    body: JSON.stringify({
-      modelID,
-      provider,
-      context,
-      tokens,
-      systemPrompt
-    }),
+    body,
   });
 
   if (!res.ok) {
-    const err = await res.json();
+    throw await responseError(res);
+  }
+
+  return toChatReply(await res.json());
+}
+
+// readChatStream reads delta, error and done events until the server closes the stream
+async function readChatStream(
+  stream: ReadableStream<Uint8Array>,
+  onDelta?: (text: string) => void,
+): Promise<ChatReply> {
+  const reader = stream.getReader();
+  const decoder = new TextDecoder();
+  let buffer = "";
+  let text = "";
 
-    const errMsg =
-      err.message ||
-      err.error ||
-      "An unexpected error occurred.";
+  while (true) {
+    const { value, done } = await reader.read();
+    buffer += decoder.decode(value, { stream: !done });
 
-    console.error(errMsg);
+    let boundary: number;
+    while ((boundary = buffer.indexOf("\n\n")) !== -1) {
+      const { event, data } = parseEvent(buffer.slice(0, boundary));
+      buffer = buffer.slice(boundary + 2);
 
-    throw new Error(errMsg);
+      if (!data) continue;
+
+      const payload = JSON.parse(data);
+
+      if (event === "delta") {
+        text += payload.text ?? "";
+        onDelta?.(text);
+      } else if (event === "error") {
+        const errMsg = payload.error || "The response stream failed.";
+        console.error(errMsg);
+        throw new Error(errMsg);
+      } else if (event === "done") {
+        await reader.cancel();
+        return toChatReply(payload);
+      }
+    }
+
+    if (done) break;
   }
 
-  const { response } = await res.json();
-  return response;
-};
+  throw new Error("The response stream ended before the answer finished.");
+}
+
+function parseEvent(block: string): { event: string; data: string } {
+  let event = "message";
+  const data: string[] = [];
+
+  for (const line of block.split("\n")) {
+    if (line.startsWith("event:")) event = line.slice(6).trim();
+    else if (line.startsWith("data:")) data.push(line.slice(5).trimStart());
+  }
+
+  return { event, data: data.join("\n") };
+}
+
+async function responseError(res: Response): Promise<Error> {
+  const err = await res.json().catch(() => ({}));
+
+  const errMsg =
+    err.message ||
+    err.error ||
+    "An unexpected error occurred.";
+
+  console.error(errMsg);
+
+  return new Error(errMsg);
+}
+
+// toChatReply keeps the answer text and every source it cites, web results first
+function toChatReply(result: any): ChatReply {
+  const sources: Source[] = [];
+  const seen = new Set<string>();
+
+  const add = (source: Source) => {
+    const key = source.url || source.id || source.title || "";
+    if (!key || seen.has(key)) return;
+    seen.add(key);
+    sources.push(source);
+  };
+
+  (result?.sources ?? []).forEach(add);
+  (result?.citations ?? []).forEach((c: Citation) => (c.sources ?? []).forEach(add));
+
+  return { text: result?.response ?? "", sources };
+}
 
 async function buildContext(
   sessionID: string,
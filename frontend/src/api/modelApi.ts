@@ -1,10 +1,10 @@
 import { database as db } from "../db/index";
-import type { Model } from "../utils/types/types";
+import type { Model, ModelRefresh, ProviderFailure } from "../utils/types/types";
 import { preferredOrder } from "../utils/constants";
 
-export async function fetchModels(): Promise<Model[]> {
+export async function fetchModels(): Promise<ModelRefresh> {
   const cached = await db.models.toArray();
-  if (cached.length > 0) return sortModels(cached);
+  if (cached.length > 0) return { models: sortModels(cached), failed: [] };
   return refreshModels();
 }
 
@@ -20,14 +20,20 @@ export const refreshModelsForProvider = (provider: string) =>
 async function refreshModelsBase(
   url: string,
   deleteProvider?: string
-): Promise<Model[]> {
+): Promise<ModelRefresh> {
   try {
     const r = await fetch(url);
-    if (!r.ok) throw new Error(String(r.status));
+    if (!r.ok) throw new Error(`model listing returned ${r.status}`);
 
     const payload = await r.json();
     const raw = extractRawModels(payload);
 
+    const failed: ProviderFailure[] = (payload?.failed ?? []).map((f: any) => ({
+      provider: String(f?.provider ?? ""),
+      code: f?.code,
+      error: f?.error,
+    }));
+
     const byKey = new Map<string, Model>();
     for (const x of raw) {
       const m = normalizeModel(x);
@@ -49,10 +55,13 @@ async function refreshModelsBase(
       await db.models.bulkPut(sorted);
     });
 
-    return sorted;
+    return { models: sorted, failed };
   } catch (e) {
     console.warn("Model refresh failed:", e);
-    return [];
+    return {
+      models: [],
+      failed: [{ provider: deleteProvider ?? "All providers", error: e instanceof Error ? e.message : String(e) }],
+    };
   }
 }
 
@@ -7,7 +7,7 @@ import rehypeKatex from "rehype-katex";
 import rehypeHighlight from "rehype-highlight";
 import "katex/dist/katex.min.css";
 import "highlight.js/styles/github-dark.css";
-import { ChatMessage, MessageContent } from "../../utils/types/types";
+import { ChatMessage, MessageContent, Source } from "../../utils/types/types";
 import { X, RefreshCw, Loader2 } from "lucide-react";
 import CodeBlock from "./CodeBlock";
 
@@ -172,6 +172,10 @@ function MessageBubble({
           ) : (
             renderMessageContent(chatMessage.content)
           )}
+
+          {!isPending && chatMessage.sources && chatMessage.sources.length > 0 && (
+            <SourceList sources={chatMessage.sources} />
+          )}
         </div>
 
       </div>
@@ -188,10 +192,44 @@ export default memo(MessageBubble, (prev, next) => {
     a.id === b.id &&
     a.content === b.content &&
     a.model_name === b.model_name &&
+    a.sources === b.sources &&
     a.role === b.role
   );
 });
 
+// SourceList numbers sources in the order the answer cites them, matching its [n] markers
+function SourceList({ sources }: { sources: Source[] }) {
+  return (
+    <div className="not-prose mt-2 mb-2 border-t border-zinc-200 dark:border-zinc-700 pt-2">
+      <div className="text-[10px] font-medium uppercase tracking-wide text-zinc-500 dark:text-zinc-400 mb-1 select-none">
+        Sources
+      </div>
+      <ol className="list-decimal ml-5 space-y-0.5 text-[13px]">
+        {sources.map((source, i) => {
+          const label = source.title || source.url || source.id || `Source ${i + 1}`;
+
+          return (
+            <li key={`${source.url ?? source.id ?? ""}-${i}`} title={source.snippet}>
+              {source.url ? (
+                <a
+                  href={source.url}
+                  target="_blank"
+                  rel="noopener noreferrer"
+                  className="text-blue-600 dark:text-blue-400 underline underline-offset-2 hover:text-blue-700 dark:hover:text-blue-300 break-all"
+                >
+                  {label}
+                </a>
+              ) : (
+                <span className="text-zinc-600 dark:text-zinc-300">{label}</span>
+              )}
+            </li>
+          );
+        })}
+      </ol>
+    </div>
+  );
+}
+
 function renderMessageContent(content: MessageContent) {
   if (typeof content === "string") {
     return (
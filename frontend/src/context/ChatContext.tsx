@@ -7,7 +7,8 @@ import {
   Provider,
   ImageAttachment,
   MessageContent,
-  ContentBlock
+  ContentBlock,
+  ProviderFailure
 } from "../utils/types/types";
 import {
   fetchModels,
@@ -111,9 +112,10 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
     initializeDB().catch(error => console.log("Failed to init DB", error))
     fetchSessions().then(setSessions).catch(err => console.log(err))
     fetchModels()
-      .then((modelList) => {
+      .then(({ models: modelList, failed }) => {
         setModels(modelList);
         setSelectedModel(modelList.length > 0 ? modelList[0].id : "")
+        showProviderFailures(failed);
       })
       .catch(console.error);
 
@@ -171,7 +173,9 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
   };
 
   const handleRefreshProviderModels = async (provider: string) => {
-    const refreshedModels = await refreshModelsForProvider(provider);
+    const { models: refreshedModels, failed } = await refreshModelsForProvider(provider);
+
+    showProviderFailures(failed);
 
     setModels(prev => [
       ...prev.filter(m => m.provider !== provider),
@@ -353,6 +357,13 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
   };
 
 
+  // showStreamedText replaces a placeholder's content with the answer streamed so far
+  const showStreamedText = (placeholderId: string, text: string) => {
+    setChatMessages(prev =>
+      prev.map(m => (m.id === placeholderId ? { ...m, content: text, pending: false } : m))
+    );
+  };
+
   async function handleResubmitFromMessage(clickedId: string) {
     const snapshot = [...chatMessages];
     const idx = snapshot.findIndex((m) => m.id === clickedId);
@@ -410,22 +421,24 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
         modelProvider,
         sharedContext,
         tokens,
-        systemPrompt
+        systemPrompt,
+        (text) => showStreamedText(tempId, text)
       );
 
       const pk = await addMessage({
         sessionId: selectedSession,
         role: "assistant",
-        content: resp,
+        content: resp.text,
         modelId: selectedModel,
         modelName,
+        sources: resp.sources,
         ts: Date.now(),
       });
 
       setChatMessages((prev) =>
         prev.map((m) =>
           m.id === tempId
-            ? { ...m, id: String(pk), content: resp, model_name: modelName, pending: false }
+            ? { ...m, id: String(pk), content: resp.text, model_name: modelName, sources: resp.sources, pending: false }
             : m
         )
       );
@@ -538,15 +551,17 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
         modelProvider,
         sharedContext,
         tokens,
-        systemPrompt
+        systemPrompt,
+        (text) => showStreamedText(tempAssistantId, text)
       );
 
       const assistantPk = await addMessage({
         sessionId: workingSessionId,
         role: "assistant",
-        content: resp,
+        content: resp.text,
         modelId: selectedModel,
         modelName,
+        sources: resp.sources,
         ts: Date.now(),
       });
 
@@ -556,8 +571,9 @@ export const ChatProvider: React.FC<{ children: React.ReactNode }> = ({ children
             return {
               id: String(assistantPk),
               role: "assistant",
-              content: resp,
+              content: resp.text,
               model_name: modelName,
+              sources: resp.sources,
               pending: false
             };
           }
@@ -645,6 +661,29 @@ export const useChat = (): ChatContextType => {
   return ctx;
 };
 
+// showProviderFailures tells the user which providers' models couldn't be listed
+function showProviderFailures(failed: ProviderFailure[]) {
+  if (failed.length === 0) return;
+
+  const entities: Record<string, string> = { "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" };
+  const escape = (text: string) => text.replace(/[&<>"']/g, c => entities[c]);
+
+  const items = failed
+    .map(f => `<li><strong>${escape(f.provider)}</strong>: ${escape(f.error || "unavailable")}</li>`)
+    .join("");
+
+  Swal.fire({
+    toast: true,
+    position: "top-end",
+    icon: "warning",
+    title: "Some models couldn't be loaded",
+    html: `<ul class='text-left text-sm text-gray-600'>${items}</ul>`,
+    showConfirmButton: false,
+    showCloseButton: true,
+    timer: 8000,
+  });
+}
+
 function generateTitleFromText(text: string) {
   if (!text) return "New chat";
   let t = text
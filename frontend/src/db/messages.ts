@@ -1,6 +1,6 @@
 import Dexie from "dexie";
 import { database as db } from "./index";
-import type { MessageRow, Role, ChatMessage, MessageContent } from "../utils/types/types"; 
+import type { MessageRow, Role, ChatMessage, MessageContent, Source } from "../utils/types/types"; 
 
 export async function getMessageById(id: string | number): Promise<MessageRow | undefined> {
   const pk = typeof id === "number" ? id : Number(id);
@@ -109,6 +109,7 @@ export function addMessage(args: {
   content: MessageContent;
   modelId: string;
   modelName?: string;
+  sources?: Source[];
   ts?: number;
 }) {
   const row: MessageRow = {
@@ -118,6 +119,7 @@ export function addMessage(args: {
     modelId: args.modelId,
     modelName: args.modelName ?? "",
     ts: args.ts ?? Date.now(),
+    ...(args.sources?.length ? { sources: args.sources } : {}),
   };
 
   return db.messages.add(row);
@@ -163,5 +165,6 @@ function rowToChat(m: MessageRow): ChatMessage {
     role: m.role,
     content: m.content,
     model_name: m.modelName,
+    sources: m.sources,
   };
 }
@@ -10,11 +10,41 @@ export type SessionSelectorProps = {
     onSelectSession: (sessionId: string) => void;
 }
 
+export type Modality = "text" | "image" | "file" | "audio" | "video";
+
+export type ModelPricing = {
+  input: number;
+  cachedInput: number;
+  output: number;
+};
+
 export type Model = {
   id: string;
   name: string;
   provider: Provider;
   enabled?: boolean;
+  contextLength?: number;
+  maxOutputTokens?: number;
+  inputModalities?: Modality[];
+  outputModalities?: Modality[];
+  supportsTools?: boolean;
+  supportsJSON?: boolean;
+  pricing?: ModelPricing;
+};
+
+export type Source = {
+  id?: string;
+  title?: string;
+  url?: string;
+  snippet?: string;
+};
+
+// Citation spans [start, end) of the response text and lists the sources backing it
+export type Citation = {
+  start: number;
+  end: number;
+  text: string;
+  sources: Source[];
 };
 
 export type ChatMessage = {
@@ -23,12 +53,32 @@ export type ChatMessage = {
   time?: string;
   content: MessageContent;
   model_name?: string;
+  sources?: Source[];
   pending?: boolean
 };
 
+// ChatReply is an assistant answer with the sources it was grounded on
+export type ChatReply = {
+  text: string;
+  sources: Source[];
+};
+
+// ProviderFailure is a provider the model listing couldn't reach
+export type ProviderFailure = {
+  provider: string;
+  code?: string;
+  error?: string;
+};
+
+export type ModelRefresh = {
+  models: Model[];
+  failed: ProviderFailure[];
+};
+
 export type ContentBlock =
   | { type: "text"; text: string }
-  | { type: "image_url"; image_url: { url: string } };
+  | { type: "image_url"; image_url: { url: string } }
+  | { type: "file"; file: { filename?: string; file_data?: string; file_id?: string } };
 
 export type MessageContent = string | ContentBlock[];
 
@@ -40,6 +90,7 @@ export type MessageContent = string | ContentBlock[];
   modelName: string;             
   role: "user" | "assistant";
   content: MessageContent;              
+  sources?: Source[];
   ts: number;                    
 };
 
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/keys"
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// ResolveProvider matches a provider name case-insensitively against the current config
+func ResolveProvider(name string) (types.Provider, error) {
+	for _, provider := range utils.ConfiguredProviders() {
+		if strings.EqualFold(string(provider), name) {
+			return provider, nil
+		}
+	}
+
+	return "", utils.ErrProviderNotSupported
+}
+
+func KeyStatuses() []types.ProviderKeyStatus {
+	active := llms.Clients()
+	settings := utils.CurrentSettings()
+	statuses := make([]types.ProviderKeyStatus, 0)
+
+	for _, provider := range settings.ConfiguredProviders() {
+		status := types.ProviderKeyStatus{Provider: provider, Source: "none"}
+
+		if _, ok := keys.Lookup(provider); ok {
+			status.Source = "stored"
+			status.UpdatedAt, _ = keys.Store.UpdatedAt(provider)
+		} else if len(utils.GetKeysFrom(settings, provider)) > 0 {
+			status.Source = "env"
+		} else if settings.KeylessProviders[provider] {
+			status.Source = "keyless"
+		}
+
+		client, ok := active[provider]
+		status.Active = ok
+
+		if pooled, ok := client.(llms.KeyPooled); ok {
+			status.Keys, status.Benched = pooled.KeyPool().Stats()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// SetKey stores a new or rotated key and swaps in clients that use it
+func SetKey(provider types.Provider, key string) error {
+	if keys.Store == nil {
+		return keys.ErrStoreDisabled
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := keys.Store.Set(provider, key); err != nil {
+		return err
+	}
+
+	llms.ReloadClients()
+	chatservice.InvalidateModelCache(provider)
+
+	log.Printf("stored key provider=%q", provider)
+
+	return nil
+}
+
+// DeleteKey removes a stored key, the provider falls back to its env key if it has one
+func DeleteKey(provider types.Provider) error {
+	if keys.Store == nil {
+		return keys.ErrStoreDisabled
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := keys.Store.Delete(provider); err != nil {
+		return err
+	}
+
+	llms.ReloadClients()
+	chatservice.InvalidateModelCache(provider)
+
+	log.Printf("deleted key provider=%q", provider)
+
+	return nil
+}
+
+// TestKey lists the provider's models with each of the given keys, or each current key when
+// candidate is empty, stopping at the first key the provider rejects
+func TestKey(ctx context.Context, provider types.Provider, candidate string) *types.KeyTestResult {
+	result := &types.KeyTestResult{Provider: provider}
+
+	providerKeys := utils.SplitKeys(candidate)
+
+	if len(providerKeys) == 0 {
+		providerKeys = llms.ResolveKeys(provider)
+	}
+
+	// A keyless provider is tested once without a key
+	if len(providerKeys) == 0 {
+		providerKeys = []string{""}
+	}
+
+	startTime := time.Now()
+
+	for index, key := range providerKeys {
+		client, err := llms.NewClient(provider, []string{key})
+
+		if err == nil {
+			var models []*types.Model
+
+			models, err = client.Models(ctx)
+			result.Models = len(models)
+		}
+
+		result.Keys = index + 1
+
+		if err != nil {
+			result.FailedKey = index + 1
+			result.Err = err
+			break
+		}
+	}
+
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+	result.OK = result.Err == nil
+
+	return result
+}
@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/config"
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/ratelimit"
+	"github.com/CodingWithKarim/AgentK/internal/usage"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+	"github.com/joho/godotenv"
+)
+
+var (
+	configPath string
+	reloadMu   sync.Mutex
+
+	// envOrigins holds, for every variable .env has set, the value it had before (nil when unset),
+	// so a variable removed from .env goes back to it on the next reload
+	envOrigins = map[string]*string{}
+)
+
+// InitializeReload remembers the config path so later reloads read the same file
+func InitializeReload(path string) {
+	configPath = path
+}
+
+// LoadEnv loads .env at startup without overriding the process environment, a missing file is fine
+func LoadEnv() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	values, err := godotenv.Read()
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		envOrigins[name] = nil
+		os.Setenv(name, value)
+	}
+
+	return nil
+}
+
+// Reload re-reads .env and the config file, then rebuilds and swaps the provider clients, rate
+// limits, fallback chains, budgets and auth users. Everything is built and validated before anything
+// is swapped in, so when the new config or environment is invalid the server keeps running on the old
+// one and .env edits are rolled back. DATA_DIR, KEYS_MASTER_SECRET and the agent tool settings are
+// only read at startup and need a restart
+func Reload() ([]types.Provider, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	restoreEnv, err := overloadEnv()
+
+	if err != nil {
+		return nil, err
+	}
+
+	newConfig, err := config.Load(configPath)
+
+	if err == nil {
+		err = swapConfig(newConfig)
+	}
+
+	if err != nil {
+		restoreEnv()
+		return nil, err
+	}
+
+	providers := make([]types.Provider, 0)
+
+	for provider := range llms.Clients() {
+		providers = append(providers, provider)
+	}
+
+	sort.Slice(providers, func(i, j int) bool { return providers[i] < providers[j] })
+
+	log.Printf("reloaded config path=%q providers=%d", configPath, len(providers))
+
+	return providers, nil
+}
+
+// swapConfig builds the settings, fallback chains, limits, budgets, auth and clients for a loaded config
+// and only swaps them in once all of them are valid. The limiter and key pools keep their state across the swap
+func swapConfig(newConfig *config.Config) error {
+	settings := config.BuildSettings(newConfig)
+
+	chains, err := chatservice.ParseFallbackChains()
+
+	if err != nil {
+		return err
+	}
+
+	limiter, err := ratelimit.NewLimiter(settings.ConfiguredProviders())
+
+	if err != nil {
+		return err
+	}
+
+	budgets, err := usage.ParseBudgets()
+
+	if err != nil {
+		return err
+	}
+
+	authConfig, err := auth.ParseFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	clients := llms.BuildClients(settings)
+
+	config.Apply(newConfig)
+	llms.SwapClients(clients)
+	chatservice.SetFallbackChains(chains)
+	ratelimit.Default.Update(limiter)
+	usage.SetBudgets(budgets)
+	auth.SetConfig(authConfig)
+	chatservice.InvalidateModelCache()
+
+	return nil
+}
+
+// overloadEnv sets every variable in .env, replacing values loaded at startup, and puts back the
+// value from before .env of every variable removed from the file. It returns a function that undoes both
+func overloadEnv() (func(), error) {
+	values, err := godotenv.Read()
+
+	if errors.Is(err, fs.ErrNotExist) {
+		values, err = map[string]string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	previousOrigins := maps.Clone(envOrigins)
+	previous := make(map[string]*string, len(values)+len(envOrigins))
+
+	for name, value := range values {
+		old := lookupEnv(name)
+		previous[name] = old
+
+		if _, ok := envOrigins[name]; !ok {
+			envOrigins[name] = old
+		}
+
+		os.Setenv(name, value)
+	}
+
+	for name, origin := range envOrigins {
+		if _, ok := values[name]; ok {
+			continue
+		}
+
+		previous[name] = lookupEnv(name)
+		setEnv(name, origin)
+		delete(envOrigins, name)
+	}
+
+	return func() {
+		for name, old := range previous {
+			setEnv(name, old)
+		}
+
+		envOrigins = previousOrigins
+	}, nil
+}
+
+func lookupEnv(name string) *string {
+	if value, ok := os.LookupEnv(name); ok {
+		return &value
+	}
+
+	return nil
+}
+
+// setEnv sets a variable to value, or unsets it when value is nil
+func setEnv(name string, value *string) {
+	if value == nil {
+		os.Unsetenv(name)
+	} else {
+		os.Setenv(name, *value)
+	}
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"os"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, contents string) {
+	t.Helper()
+
+	if err := os.WriteFile(".env", []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertEnv(t *testing.T, name string, want string, wantSet bool) {
+	t.Helper()
+
+	value, ok := os.LookupEnv(name)
+
+	if ok != wantSet || value != want {
+		t.Errorf("%s = %q (set %v), want %q (set %v)", name, value, ok, want, wantSet)
+	}
+}
+
+func TestOverloadEnvTracksRemovedKeys(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	previousOrigins := envOrigins
+	envOrigins = map[string]*string{}
+
+	t.Cleanup(func() {
+		envOrigins = previousOrigins
+	})
+
+	t.Setenv("RELOAD_TEST_PROCESS", "from process")
+	t.Setenv("RELOAD_TEST_FILE", "")
+	os.Unsetenv("RELOAD_TEST_FILE")
+
+	writeEnvFile(t, "RELOAD_TEST_PROCESS=from file\nRELOAD_TEST_FILE=one\n")
+
+	if err := LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Startup keeps the process environment
+	assertEnv(t, "RELOAD_TEST_PROCESS", "from process", true)
+	assertEnv(t, "RELOAD_TEST_FILE", "one", true)
+
+	if _, err := overloadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEnv(t, "RELOAD_TEST_PROCESS", "from file", true)
+
+	// Removing both from .env puts back what the process had
+	writeEnvFile(t, "RELOAD_TEST_OTHER=x\n")
+	t.Setenv("RELOAD_TEST_OTHER", "")
+
+	restore, err := overloadEnv()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEnv(t, "RELOAD_TEST_PROCESS", "from process", true)
+	assertEnv(t, "RELOAD_TEST_FILE", "", false)
+	assertEnv(t, "RELOAD_TEST_OTHER", "x", true)
+
+	// A failed reload rolls the removals back too
+	restore()
+
+	assertEnv(t, "RELOAD_TEST_PROCESS", "from file", true)
+	assertEnv(t, "RELOAD_TEST_FILE", "one", true)
+	assertEnv(t, "RELOAD_TEST_OTHER", "", true)
+
+	if err := os.Remove(".env"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the file altogether counts as removing every key
+	if _, err := overloadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEnv(t, "RELOAD_TEST_PROCESS", "from process", true)
+	assertEnv(t, "RELOAD_TEST_FILE", "", false)
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	DefaultMaxIterations = 8
+	MaxIterationsLimit   = 20
+)
+
+const (
+	StopCompleted     = "completed"
+	StopMaxIterations = "max_iterations"
+)
+
+var ErrInvalidRequest = errors.New("invalid agent request")
+
+// Run calls the model repeatedly, executing any requested tools and feeding the results
+// back, until the model answers without tool calls or the iteration limit is reached
+func Run(ctx context.Context, request *types.AgentRequest) (*types.AgentResult, error) {
+	if len(request.Tools) > 0 {
+		return nil, fmt.Errorf("%w: select server tools with enabledTools, not tools", ErrInvalidRequest)
+	}
+
+	tools, err := resolveTools(request.EnabledTools)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	maxIterations := request.MaxIterations
+
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+
+	maxIterations = min(maxIterations, MaxIterationsLimit)
+
+	chatRequest := request.ChatRequest
+	chatRequest.Tools = make([]types.ToolDefinition, 0, len(tools))
+
+	for _, tool := range tools {
+		chatRequest.Tools = append(chatRequest.Tools, tool.Definition())
+	}
+
+	result := &types.AgentResult{
+		StopReason: StopMaxIterations,
+		Steps:      make([]types.AgentStep, 0, maxIterations),
+	}
+
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		chatResult, err := chatservice.GenerateChatResponse(ctx, &chatRequest)
+
+		if err != nil {
+			return result, fmt.Errorf("agent iteration %d failed: %w", iteration, err)
+		}
+
+		addUsage(result, chatResult)
+
+		step := types.AgentStep{
+			Iteration: iteration,
+			Response:  chatResult,
+		}
+
+		result.Response = chatResult.Text
+
+		if len(chatResult.ToolCalls) == 0 {
+			result.Steps = append(result.Steps, step)
+			result.StopReason = StopCompleted
+			return result, nil
+		}
+
+		for _, toolCall := range chatResult.ToolCalls {
+			step.ToolResults = append(step.ToolResults, executeTool(ctx, tools, toolCall))
+		}
+
+		result.Steps = append(result.Steps, step)
+
+		chatRequest.Context, err = chatservice.AppendToolTurn(chatRequest.Context, chatResult, step.ToolResults)
+
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func executeTool(ctx context.Context, tools map[string]Tool, toolCall types.ToolCall) types.ToolResult {
+	toolResult := types.ToolResult{
+		ToolCallID: toolCall.ID,
+		Name:       toolCall.Name,
+	}
+
+	tool, ok := tools[toolCall.Name]
+
+	if !ok {
+		toolResult.Output = fmt.Sprintf("unknown tool %q", toolCall.Name)
+		toolResult.IsError = true
+		return toolResult
+	}
+
+	output, err := tool.Execute(ctx, toolCall.Arguments)
+
+	// Tool failures are reported back to the model so it can correct itself
+	if err != nil {
+		log.Printf("agent tool failed tool=%q err=%v", toolCall.Name, err)
+		toolResult.Output = err.Error()
+		toolResult.IsError = true
+		return toolResult
+	}
+
+	toolResult.Output = output
+
+	return toolResult
+}
+
+func addUsage(result *types.AgentResult, chatResult *types.ChatResult) {
+	result.Usage.InputTokens += chatResult.Usage.InputTokens
+	result.Usage.OutputTokens += chatResult.Usage.OutputTokens
+	result.Usage.CachedTokens += chatResult.Usage.CachedTokens
+
+	if chatResult.CostUSD != nil {
+		result.CostUSD += *chatResult.CostUSD
+	}
+}
@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const testProvider types.Provider = "AgentTest"
+
+// scriptedClient answers each call with the next scripted result and keeps the messages every call was encoded from
+type scriptedClient struct {
+	llms.LLMClient
+
+	mutex   sync.Mutex
+	results []*types.ChatResult
+	encoded [][]types.Message
+}
+
+func (c *scriptedClient) Encode(messages []types.Message) (any, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.encoded = append(c.encoded, messages)
+
+	return messages, nil
+}
+
+func (c *scriptedClient) Chat(ctx context.Context, request *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.results) == 0 {
+		return &types.ChatResult{Text: "done"}, nil
+	}
+
+	result := c.results[0]
+	c.results = c.results[1:]
+
+	return result, nil
+}
+
+// repeatClient asks for the same tool on every call, so only the iteration limit stops the loop
+type repeatClient struct {
+	llms.LLMClient
+}
+
+func (repeatClient) Encode(messages []types.Message) (any, error) {
+	return messages, nil
+}
+
+func (repeatClient) Chat(ctx context.Context, request *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	return &types.ChatResult{ToolCalls: []types.ToolCall{{ID: "call", Name: "echo", Arguments: json.RawMessage(`{"text":"again"}`)}}}, nil
+}
+
+type echoTool struct{}
+
+func (echoTool) Definition() types.ToolDefinition {
+	return types.ToolDefinition{Name: "echo", Parameters: json.RawMessage(`{"type":"object"}`)}
+}
+
+func (echoTool) Execute(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var input struct {
+		Text string `json:"text"`
+	}
+
+	if err := json.Unmarshal(arguments, &input); err != nil {
+		return "", err
+	}
+
+	return input.Text, nil
+}
+
+type failingTool struct{}
+
+func (failingTool) Definition() types.ToolDefinition {
+	return types.ToolDefinition{Name: "fail"}
+}
+
+func (failingTool) Execute(ctx context.Context, arguments json.RawMessage) (string, error) {
+	return "", errors.New("disk on fire")
+}
+
+func useClient(t *testing.T, client llms.LLMClient) {
+	t.Helper()
+
+	previous := llms.Clients()
+	llms.SwapClients(map[types.Provider]llms.LLMClient{testProvider: client})
+
+	t.Cleanup(func() {
+		llms.SwapClients(previous)
+	})
+}
+
+func useTools(t *testing.T, tools ...Tool) {
+	t.Helper()
+
+	for _, tool := range tools {
+		Register(tool)
+	}
+
+	t.Cleanup(func() {
+		registryMutex.Lock()
+		defer registryMutex.Unlock()
+
+		for _, tool := range tools {
+			delete(registry, tool.Definition().Name)
+		}
+	})
+}
+
+func newAgentRequest(enabledTools ...string) *types.AgentRequest {
+	return &types.AgentRequest{
+		ChatRequest: types.ChatRequest{
+			Provider: testProvider,
+			ModelID:  "scripted",
+			Context:  json.RawMessage(`[{"role":"user","content":"go"}]`),
+			Tokens:   100,
+		},
+		EnabledTools: enabledTools,
+	}
+}
+
+func TestRunDispatchesToolCalls(t *testing.T) {
+	useTools(t, echoTool{}, failingTool{})
+
+	client := &scriptedClient{results: []*types.ChatResult{
+		{ToolCalls: []types.ToolCall{
+			{ID: "1", Name: "echo", Arguments: json.RawMessage(`{"text":"hello"}`)},
+			{ID: "2", Name: "fail", Arguments: json.RawMessage(`{}`)},
+			{ID: "3", Name: "missing", Arguments: json.RawMessage(`{}`)},
+		}},
+		{Text: "all done"},
+	}}
+
+	useClient(t, client)
+
+	result, err := Run(context.Background(), newAgentRequest("echo", "fail"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.StopReason != StopCompleted || result.Response != "all done" || len(result.Steps) != 2 {
+		t.Fatalf("result = %+v, want completed after two steps", result)
+	}
+
+	want := []types.ToolResult{
+		{ToolCallID: "1", Name: "echo", Output: "hello"},
+		{ToolCallID: "2", Name: "fail", Output: "disk on fire", IsError: true},
+		{ToolCallID: "3", Name: "missing", Output: `unknown tool "missing"`, IsError: true},
+	}
+
+	toolResults := result.Steps[0].ToolResults
+
+	if len(toolResults) != len(want) {
+		t.Fatalf("tool results = %+v, want %+v", toolResults, want)
+	}
+
+	for i := range want {
+		if toolResults[i] != want[i] {
+			t.Errorf("tool result %d = %+v, want %+v", i, toolResults[i], want[i])
+		}
+	}
+
+	// The second call sees the tool turn with each failure flagged
+	if len(client.encoded) != 2 || len(client.encoded[1]) != 3 {
+		t.Fatalf("encoded contexts = %+v, want the second call to carry the tool turn", client.encoded)
+	}
+
+	parts, err := client.encoded[1][2].Parts()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("tool result parts = %+v", parts)
+	}
+
+	for i, part := range parts {
+		if part.Type != "tool_result" || part.ToolCallID != want[i].ToolCallID || part.Text != want[i].Output || part.IsError != want[i].IsError {
+			t.Errorf("part %d = %+v, want the result of %+v", i, part, want[i])
+		}
+	}
+}
+
+func TestRunStopsAtIterationLimit(t *testing.T) {
+	useTools(t, echoTool{})
+	useClient(t, repeatClient{})
+
+	request := newAgentRequest("echo")
+	request.MaxIterations = 3
+
+	result, err := Run(context.Background(), request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.StopReason != StopMaxIterations || len(result.Steps) != 3 {
+		t.Errorf("stopped with %q after %d steps, want %q after 3", result.StopReason, len(result.Steps), StopMaxIterations)
+	}
+
+	request.MaxIterations = MaxIterationsLimit + 5
+
+	result, err = Run(context.Background(), request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Steps) != MaxIterationsLimit {
+		t.Errorf("ran %d steps, want the limit of %d", len(result.Steps), MaxIterationsLimit)
+	}
+}
+
+func TestRunRejectsUnknownTools(t *testing.T) {
+	useClient(t, repeatClient{})
+
+	if _, err := Run(context.Background(), newAgentRequest("not-registered")); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("got %v, want %v", err, ErrInvalidRequest)
+	}
+}
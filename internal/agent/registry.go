@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/CodingWithKarim/AgentK/internal/agent/tools"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type Tool interface {
+	Definition() types.ToolDefinition
+	Execute(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+var (
+	registry      = map[string]Tool{}
+	registryMutex sync.RWMutex
+)
+
+// InitializeTools registers the built-in tools, the HTTP fetch and file browser tools are
+// only enabled when AGENT_FETCH_ALLOWED_HOSTS and AGENT_FILES_DIR are configured
+func InitializeTools() error {
+	Register(tools.NewCalculator())
+
+	if hosts := os.Getenv("AGENT_FETCH_ALLOWED_HOSTS"); hosts != "" {
+		allowedHosts := make([]string, 0)
+
+		for _, host := range strings.Split(hosts, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				allowedHosts = append(allowedHosts, host)
+			}
+		}
+
+		Register(tools.NewHTTPFetch(allowedHosts))
+	}
+
+	if directory := os.Getenv("AGENT_FILES_DIR"); directory != "" {
+		fileBrowser, err := tools.NewFileBrowser(directory)
+
+		if err != nil {
+			return err
+		}
+
+		Register(fileBrowser)
+	}
+
+	log.Printf("Registered agent tools=%d", len(RegisteredTools()))
+
+	return nil
+}
+
+func Register(tool Tool) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry[tool.Definition().Name] = tool
+}
+
+func RegisteredTools() []types.ToolDefinition {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	definitions := make([]types.ToolDefinition, 0, len(registry))
+
+	for _, tool := range registry {
+		definitions = append(definitions, tool.Definition())
+	}
+
+	return definitions
+}
+
+// resolveTools returns the named tools, or every registered tool when no names are given
+func resolveTools(names []string) (map[string]Tool, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	if len(names) == 0 {
+		tools := make(map[string]Tool, len(registry))
+
+		for name, tool := range registry {
+			tools[name] = tool
+		}
+
+		return tools, nil
+	}
+
+	tools := make(map[string]Tool, len(names))
+
+	for _, name := range names {
+		tool, ok := registry[name]
+
+		if !ok {
+			return nil, fmt.Errorf("tool %q is not registered", name)
+		}
+
+		tools[name] = tool
+	}
+
+	return tools, nil
+}
@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type Calculator struct{}
+
+type calculatorArguments struct {
+	Expression string `json:"expression"`
+}
+
+var calculatorFunctions = map[string]func(args []float64) (float64, error){
+	"sqrt":  oneArgument(math.Sqrt),
+	"abs":   oneArgument(math.Abs),
+	"floor": oneArgument(math.Floor),
+	"ceil":  oneArgument(math.Ceil),
+	"round": oneArgument(math.Round),
+	"log":   oneArgument(math.Log),
+	"log10": oneArgument(math.Log10),
+	"exp":   oneArgument(math.Exp),
+	"sin":   oneArgument(math.Sin),
+	"cos":   oneArgument(math.Cos),
+	"tan":   oneArgument(math.Tan),
+	"pow": func(args []float64) (float64, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("pow takes 2 arguments")
+		}
+
+		return math.Pow(args[0], args[1]), nil
+	},
+}
+
+var calculatorConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{}
+}
+
+func (c *Calculator) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "calculator",
+		Description: "Evaluates an arithmetic expression. Supports + - * / %, parentheses, pi, e and sqrt, abs, floor, ceil, round, log, log10, exp, sin, cos, tan, pow(x, y).",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"expression": {"type": "string", "description": "Expression to evaluate, e.g. (2 + 3) * sqrt(16)"}
+			},
+			"required": ["expression"]
+		}`),
+	}
+}
+
+func (c *Calculator) Execute(ctx context.Context, arguments json.RawMessage) (string, error) {
+	args := &calculatorArguments{}
+
+	if err := json.Unmarshal(arguments, args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	// Go's expression grammar covers arithmetic, the AST is walked without ever executing code
+	expression, err := parser.ParseExpr(args.Expression)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+
+	value, err := evaluate(expression)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+func evaluate(node ast.Expr) (float64, error) {
+	switch expression := node.(type) {
+	case *ast.BasicLit:
+		if expression.Kind != token.INT && expression.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", expression.Value)
+		}
+
+		return strconv.ParseFloat(expression.Value, 64)
+
+	case *ast.Ident:
+		value, ok := calculatorConstants[expression.Name]
+
+		if !ok {
+			return 0, fmt.Errorf("unknown identifier %q", expression.Name)
+		}
+
+		return value, nil
+
+	case *ast.ParenExpr:
+		return evaluate(expression.X)
+
+	case *ast.UnaryExpr:
+		value, err := evaluate(expression.X)
+
+		if err != nil {
+			return 0, err
+		}
+
+		switch expression.Op {
+		case token.ADD:
+			return value, nil
+		case token.SUB:
+			return -value, nil
+		}
+
+		return 0, fmt.Errorf("unsupported operator %s", expression.Op)
+
+	case *ast.BinaryExpr:
+		left, err := evaluate(expression.X)
+
+		if err != nil {
+			return 0, err
+		}
+
+		right, err := evaluate(expression.Y)
+
+		if err != nil {
+			return 0, err
+		}
+
+		switch expression.Op {
+		case token.ADD:
+			return left + right, nil
+		case token.SUB:
+			return left - right, nil
+		case token.MUL:
+			return left * right, nil
+		case token.QUO:
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+
+			return left / right, nil
+		case token.REM:
+			return math.Mod(left, right), nil
+		}
+
+		return 0, fmt.Errorf("unsupported operator %s", expression.Op)
+
+	case *ast.CallExpr:
+		name, ok := expression.Fun.(*ast.Ident)
+
+		if !ok {
+			return 0, fmt.Errorf("unsupported function call")
+		}
+
+		function, ok := calculatorFunctions[name.Name]
+
+		if !ok {
+			return 0, fmt.Errorf("unknown function %q", name.Name)
+		}
+
+		args := make([]float64, 0, len(expression.Args))
+
+		for _, arg := range expression.Args {
+			value, err := evaluate(arg)
+
+			if err != nil {
+				return 0, err
+			}
+
+			args = append(args, value)
+		}
+
+		return function(args)
+	}
+
+	return 0, fmt.Errorf("unsupported expression")
+}
+
+func oneArgument(function func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("function takes 1 argument")
+		}
+
+		return function(args[0]), nil
+	}
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const fileMaxReadBytes = 100 << 10
+
+// FileBrowser lists and reads files beneath a root directory, os.Root rejects any
+// path or symlink that escapes it
+type FileBrowser struct {
+	root *os.Root
+}
+
+type fileBrowserArguments struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+}
+
+func NewFileBrowser(directory string) (*FileBrowser, error) {
+	root, err := os.OpenRoot(directory)
+
+	if err != nil {
+		return nil, fmt.Errorf("open file browser root failed: %w", err)
+	}
+
+	return &FileBrowser{root: root}, nil
+}
+
+func (b *FileBrowser) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "file_browser",
+		Description: fmt.Sprintf("Read-only access to a project directory. Use action \"list\" to list a directory or \"read\" to read a file (truncated to %d KB). Paths are relative to the directory root.", fileMaxReadBytes>>10),
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"action": {"type": "string", "enum": ["list", "read"]},
+				"path": {"type": "string", "description": "Relative path, use . for the root"}
+			},
+			"required": ["action", "path"]
+		}`),
+	}
+}
+
+func (b *FileBrowser) Execute(ctx context.Context, arguments json.RawMessage) (string, error) {
+	args := &fileBrowserArguments{}
+
+	if err := json.Unmarshal(arguments, args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	path := strings.TrimPrefix(args.Path, "/")
+
+	if path == "" {
+		path = "."
+	}
+
+	switch args.Action {
+	case "list":
+		return b.list(path)
+	case "read":
+		return b.read(path)
+	default:
+		return "", fmt.Errorf("unknown action %q", args.Action)
+	}
+}
+
+func (b *FileBrowser) list(path string) (string, error) {
+	entries, err := fs.ReadDir(b.root.FS(), path)
+
+	if err != nil {
+		return "", err
+	}
+
+	var listing strings.Builder
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			fmt.Fprintf(&listing, "%s/\n", entry.Name())
+			continue
+		}
+
+		fmt.Fprintf(&listing, "%s\n", entry.Name())
+	}
+
+	return listing.String(), nil
+}
+
+func (b *FileBrowser) read(path string) (string, error) {
+	file, err := b.root.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, fileMaxReadBytes))
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	fetchTimeout      = 15 * time.Second
+	fetchMaxBodyBytes = 100 << 10
+)
+
+var errBlockedAddress = errors.New("destination address is not allowed")
+
+// blockedPrefixes are special purpose ranges that never reach the public internet, on top of
+// the private, loopback, link-local, multicast and unspecified addresses netip recognizes
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT shared space
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and limited broadcast
+	netip.MustParsePrefix("::/96"),           // IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, can reach any IPv4 address behind the gateway
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, Teredo included
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, can embed a private IPv4 address
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+}
+
+// HTTPFetch performs GET requests against an allow list of hosts and refuses to
+// connect to any address that isn't publicly routable
+type HTTPFetch struct {
+	allowedHosts []string
+	client       *http.Client
+}
+
+type httpFetchArguments struct {
+	URL string `json:"url"`
+}
+
+func NewHTTPFetch(allowedHosts []string) *HTTPFetch {
+	dialer := &net.Dialer{
+		Timeout: fetchTimeout,
+		// Checked after DNS resolution so a public hostname can't point at an internal address
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+
+			if err != nil || blockedAddress(addrPort.Addr()) {
+				return errBlockedAddress
+			}
+
+			return nil
+		},
+	}
+
+	fetch := &HTTPFetch{
+		allowedHosts: allowedHosts,
+	}
+
+	fetch.client = &http.Client{
+		Timeout:   fetchTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+
+			return fetch.checkURL(request.URL)
+		},
+	}
+
+	return fetch
+}
+
+func (f *HTTPFetch) Definition() types.ToolDefinition {
+	return types.ToolDefinition{
+		Name:        "http_fetch",
+		Description: fmt.Sprintf("Fetches a web page with an HTTP GET and returns the status and body text (truncated to %d KB). Allowed hosts: %s.", fetchMaxBodyBytes>>10, strings.Join(f.allowedHosts, ", ")),
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"url": {"type": "string", "description": "Absolute http or https URL"}
+			},
+			"required": ["url"]
+		}`),
+	}
+}
+
+func (f *HTTPFetch) Execute(ctx context.Context, arguments json.RawMessage) (string, error) {
+	args := &httpFetchArguments{}
+
+	if err := json.Unmarshal(arguments, args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	target, err := url.Parse(args.URL)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+
+	if err := f.checkURL(target); err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	response, err := f.client.Do(request)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, fetchMaxBodyBytes))
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("status: %d\ncontent-type: %s\n\n%s", response.StatusCode, response.Header.Get("Content-Type"), body), nil
+}
+
+// blockedAddress reports whether addr is anything but a public unicast address, IPv4-mapped
+// IPv6 addresses are judged by the IPv4 address they carry
+func blockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *HTTPFetch) checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs are allowed")
+	}
+
+	if !slices.Contains(f.allowedHosts, strings.ToLower(target.Hostname())) {
+		return fmt.Errorf("host %q is not in the allow list", target.Hostname())
+	}
+
+	return nil
+}
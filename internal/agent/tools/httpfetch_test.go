@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBlockedAddress(t *testing.T) {
+	cases := []struct {
+		address string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"0.0.0.0", true},
+		{"192.0.0.8", true},
+		{"198.18.0.1", true},
+		{"203.0.113.7", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:100.64.0.1", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+		{"64:ff9b::a00:1", true},
+		{"2001:db8::1", true},
+		{"2002:a00:1::", true},
+		{"8.8.8.8", false},
+		{"100.128.0.1", false},
+		{"::ffff:8.8.8.8", false},
+		{"2606:4700:4700::1111", false},
+	}
+
+	for _, tc := range cases {
+		if got := blockedAddress(netip.MustParseAddr(tc.address)); got != tc.blocked {
+			t.Errorf("blockedAddress(%s) = %v, want %v", tc.address, got, tc.blocked)
+		}
+	}
+}
+
+func TestHTTPFetchAllowList(t *testing.T) {
+	fetch := NewHTTPFetch([]string{"example.com"})
+
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/page", true},
+		{"http://EXAMPLE.com:8080/", true},
+		{"https://sub.example.com/", false},
+		{"https://example.org/", false},
+		{"ftp://example.com/file", false},
+		{"file:///etc/passwd", false},
+	}
+
+	for _, tc := range cases {
+		target, err := url.Parse(tc.url)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fetch.checkURL(target); (err == nil) != tc.allowed {
+			t.Errorf("checkURL(%s) = %v, want allowed %v", tc.url, err, tc.allowed)
+		}
+	}
+
+	// Redirects are held to the same list
+	redirect := &http.Request{URL: &url.URL{Scheme: "https", Host: "internal.example.org"}}
+
+	if err := fetch.client.CheckRedirect(redirect, []*http.Request{{}}); err == nil {
+		t.Error("redirect to a host outside the allow list was followed")
+	}
+}
+
+func TestHTTPFetchRefusesBlockedAddresses(t *testing.T) {
+	var requested atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requested.Store(true)
+	}))
+	defer server.Close()
+
+	// The host is allow listed, the loopback address it resolves to still isn't dialed
+	target, err := url.Parse(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := NewHTTPFetch([]string{target.Hostname()})
+	arguments, _ := json.Marshal(httpFetchArguments{URL: server.URL})
+
+	if _, err := fetch.Execute(context.Background(), arguments); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("got %v, want %v", err, errBlockedAddress)
+	}
+
+	if requested.Load() {
+		t.Error("the server behind a blocked address received the request")
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/admin"
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	"github.com/CodingWithKarim/AgentK/internal/keys"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type keyRequest struct {
+	Key  string `json:"key"`            // one key, or several separated by commas to spread load
+	Test bool   `json:"test,omitempty"` // refuse to save a key the provider rejects
+}
+
+func AdminReloadHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if !requireAdmin(response, request) {
+		return
+	}
+
+	providers, err := admin.Reload()
+
+	if err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Reload failed, keeping the current config: %v", err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"providers": providers})
+}
+
+func AdminKeysHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if !requireAdmin(response, request) {
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"keys": admin.KeyStatuses()})
+}
+
+// AdminKeyHandler adds or rotates (PUT) and removes (DELETE) a provider's stored key
+func AdminKeyHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPut && request.Method != http.MethodDelete {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if !requireAdmin(response, request) {
+		return
+	}
+
+	provider, err := admin.ResolveProvider(request.PathValue("provider"))
+
+	if err != nil {
+		writeError(response, http.StatusNotFound, "Unknown provider")
+		return
+	}
+
+	if request.Method == http.MethodDelete {
+		if err := admin.DeleteKey(provider); err != nil {
+			writeKeyError(response, err)
+			return
+		}
+
+		response.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	keyRequest := &keyRequest{}
+
+	// Decode errors are reported without detail since they could quote the key
+	if err := decodeJSONBody(response, request, keyRequest); err != nil {
+		writeError(response, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	keyRequest.Key = strings.TrimSpace(keyRequest.Key)
+
+	if keyRequest.Key == "" {
+		writeError(response, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	if keyRequest.Test {
+		result := testKey(request, provider, keyRequest.Key)
+
+		if !result.OK {
+			writeJSON(response, http.StatusUnprocessableEntity, result)
+			return
+		}
+	}
+
+	if err := admin.SetKey(provider, keyRequest.Key); err != nil {
+		writeKeyError(response, err)
+		return
+	}
+
+	for _, status := range admin.KeyStatuses() {
+		if status.Provider == provider {
+			writeJSON(response, http.StatusOK, status)
+			return
+		}
+	}
+}
+
+// AdminKeyTestHandler checks a candidate key, or the provider's current key when the body has none
+func AdminKeyTestHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if !requireAdmin(response, request) {
+		return
+	}
+
+	provider, err := admin.ResolveProvider(request.PathValue("provider"))
+
+	if err != nil {
+		writeError(response, http.StatusNotFound, "Unknown provider")
+		return
+	}
+
+	keyRequest := &keyRequest{}
+
+	if request.ContentLength != 0 {
+		if err := decodeJSONBody(response, request, keyRequest); err != nil {
+			writeError(response, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+
+	writeJSON(response, http.StatusOK, testKey(request, provider, strings.TrimSpace(keyRequest.Key)))
+}
+
+// testKey runs the check and swaps the raw provider error for the normalized one, raw
+// errors from some providers quote part of the rejected key
+func testKey(request *http.Request, provider types.Provider, key string) *types.KeyTestResult {
+	result := admin.TestKey(request.Context(), provider, key)
+
+	if result.Err != nil {
+		failure := chatErrorDetails(provider, result.Err)
+
+		result.Code = failure.Code
+		result.Error = failure.Message
+	}
+
+	return result
+}
+
+func writeKeyError(response http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, keys.ErrStoreDisabled):
+		writeError(response, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, keys.ErrKeyNotFound):
+		writeError(response, http.StatusNotFound, err.Error())
+	default:
+		writeError(response, http.StatusInternalServerError, "Unable to update the key store")
+	}
+}
+
+// requireAdmin rejects callers not listed in ADMIN_USERS
+func requireAdmin(response http.ResponseWriter, request *http.Request) bool {
+	if auth.IsAdmin(request.Context()) {
+		return true
+	}
+
+	writeError(response, http.StatusForbidden, "Admin access required")
+
+	return false
+}
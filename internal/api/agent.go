@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/CodingWithKarim/AgentK/internal/agent"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func AgentHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	agentRequest := &types.AgentRequest{}
+
+	if err := decodeJSONBody(response, request, agentRequest); err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	result, err := agent.Run(request.Context(), agentRequest)
+
+	if err != nil {
+		if errors.Is(err, agent.ErrInvalidRequest) {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		logChatFailure(request, "agent", &agentRequest.ChatRequest, err)
+
+		failure := chatErrorDetails(agentRequest.Provider, err)
+
+		setRetryAfter(response, failure.RetryAfter)
+
+		// Steps completed before the failure are still returned so the trace isn't lost
+		writeJSON(response, failure.Status, map[string]any{
+			"error": failure.Message,
+			"code":  failure.Code,
+			"trace": result,
+		})
+
+		return
+	}
+
+	writeJSON(response, http.StatusOK, result)
+}
+
+func AgentToolsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"tools": agent.RegisteredTools()})
+}
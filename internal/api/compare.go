@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// CompareHandler runs one context against several models, with "stream": true each
+// target's result is sent as its own SSE event as soon as it finishes
+func CompareHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	compareRequest := &types.CompareRequest{}
+
+	if err := decodeJSONBody(response, request, compareRequest); err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	if !compareRequest.Stream {
+		results, err := chatservice.CompareModels(request.Context(), compareRequest, nil)
+
+		if err != nil {
+			writeError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		for _, result := range results {
+			normalizeCompareError(result)
+		}
+
+		writeJSON(response, http.StatusOK, map[string]any{"results": results})
+		return
+	}
+
+	flusher, ok := response.(http.Flusher)
+
+	if !ok {
+		writeError(response, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	if len(compareRequest.Targets) == 0 || len(compareRequest.Targets) > chatservice.MaxCompareTargets {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d targets are required", chatservice.MaxCompareTargets))
+		return
+	}
+
+	startSSE(response, flusher)
+
+	results, _ := chatservice.CompareModels(request.Context(), compareRequest, func(result *types.CompareResult) {
+		normalizeCompareError(result)
+		_ = writeSSE(response, flusher, "result", result)
+	})
+
+	_ = writeSSE(response, flusher, "done", map[string]int{"targets": len(results)})
+}
+
+// normalizeCompareError swaps a raw provider error for the same message /api/chat returns
+func normalizeCompareError(result *types.CompareResult) {
+	if result.Err == nil {
+		return
+	}
+
+	failure := chatErrorDetails(result.Provider, result.Err)
+
+	result.Error = failure.Message
+	result.Code = failure.Code
+}
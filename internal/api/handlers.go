@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -16,39 +15,85 @@ func ChatHandler(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	chatRequest := &types.ChatRequest{}
+	chatRequest, err := decodeChatRequest(response, request)
 
-	// Limit the size of the request body to prevent abuse
-	decoder := json.NewDecoder(http.MaxBytesReader(response, request.Body, 10<<20))
-	decoder.DisallowUnknownFields()
-
-	// Decode the JSON request body into the ChatRequest struct
-	if err := decoder.Decode(chatRequest); err != nil {
+	if err != nil {
 		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
 		return
 	}
 
 	// Generate the chat response using the chat service
-	llmReply, err := chatservice.GenerateChatResponse(chatRequest)
+	llmReply, err := chatservice.GenerateChatResponse(request.Context(), chatRequest)
 
 	if err != nil {
-		fmt.Printf(
-			"[ERROR] provider=%s model=%s err=%v",
-			chatRequest.Provider,
-			chatRequest.ModelID,
-			err,
-		)
+		logChatFailure(request, "chat", chatRequest, err)
+
+		writeChatError(response, chatRequest.Provider, err)
+
+		return
+	}
 
-		msg := normalizeProviderError(string(chatRequest.Provider), err)
+	writeJSON(response, http.StatusOK, llmReply)
+}
 
-		writeJSON(response, http.StatusBadGateway, map[string]string{
-			"error": msg,
+func ChatStreamHandler(response http.ResponseWriter, request *http.Request) {
+	// Ensure the request method is POST
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	flusher, ok := response.(http.Flusher)
+
+	if !ok {
+		writeError(response, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	chatRequest, err := decodeChatRequest(response, request)
+
+	if err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	// Headers wait for the first delta so failures before the stream starts keep their status code
+	started := false
+
+	// Forward each token delta to the client as soon as the provider sends it
+	result, err := chatservice.StreamChatResponse(request.Context(), chatRequest, func(_ *types.ChatRequest, delta string) error {
+		if !started {
+			startSSE(response, flusher)
+			started = true
+		}
+
+		return writeSSE(response, flusher, "delta", map[string]string{"text": delta})
+	})
+
+	if err != nil {
+		logChatFailure(request, "chat stream", chatRequest, err)
+
+		if !started {
+			writeChatError(response, chatRequest.Provider, err)
+			return
+		}
+
+		failure := chatErrorDetails(chatRequest.Provider, err)
+
+		// Headers are already sent, so the error is reported as a stream event
+		_ = writeSSE(response, flusher, "error", map[string]string{
+			"error": failure.Message,
+			"code":  failure.Code,
 		})
 
 		return
 	}
 
-	writeJSON(response, http.StatusOK, map[string]any{"response": llmReply})
+	if !started {
+		startSSE(response, flusher)
+	}
+
+	_ = writeSSE(response, flusher, "done", result)
 }
 
 func GetModelsHandler(response http.ResponseWriter, request *http.Request) {
@@ -59,16 +104,19 @@ func GetModelsHandler(response http.ResponseWriter, request *http.Request) {
 
 	providerParam := request.URL.Query().Get("provider")
 
-	var models []*types.Model
-	var err error
-
 	// If a provider is specified, reload models for that provider only
-	if providerParam != "" {
-		models, err = chatservice.ReloadProviderModels(types.Provider(providerParam))
-	} else {
-		models = chatservice.GetAllModels()
+	if providerParam == "" {
+		list := chatservice.GetAllModels(request.Context())
+
+		describeModelFailures(list.Stale)
+		describeModelFailures(list.Failed)
+
+		writeJSON(response, http.StatusOK, list)
+		return
 	}
 
+	models, err := chatservice.ReloadProviderModels(request.Context(), types.Provider(providerParam))
+
 	if err != nil {
 		writeError(response, http.StatusInternalServerError, fmt.Sprintf("Unable to fetch models: %v", err))
 		return
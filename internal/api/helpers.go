@@ -2,12 +2,22 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/ratelimit"
+	"github.com/CodingWithKarim/AgentK/internal/usage"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 )
 
 func writeJSON(response http.ResponseWriter, status int, data any) {
@@ -28,7 +38,189 @@ func writeError(response http.ResponseWriter, status int, msg string) {
 	})
 }
 
+func startSSE(response http.ResponseWriter, flusher http.Flusher) {
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+}
+
+func writeSSE(response http.ResponseWriter, flusher http.Flusher, event string, data any) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}
+
+// writeSSEData writes an unnamed event, the framing OpenAI compatible clients expect
+func writeSSEData(response http.ResponseWriter, flusher http.Flusher, data any) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(response, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+
+	flusher.Flush()
+
+	return nil
+}
+
+func decodeChatRequest(response http.ResponseWriter, request *http.Request) (*types.ChatRequest, error) {
+	chatRequest := &types.ChatRequest{}
+
+	if err := decodeJSONBody(response, request, chatRequest); err != nil {
+		return nil, err
+	}
+
+	return chatRequest, nil
+}
+
+func decodeJSONBody(response http.ResponseWriter, request *http.Request, target any) error {
+	// Limit the size of the request body to prevent abuse
+	decoder := json.NewDecoder(http.MaxBytesReader(response, request.Body, 10<<20))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(target)
+}
+
+// chatFailure is what a client sees of a failed chat call
+type chatFailure struct {
+	Status     int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+// providerErrorStatus maps each provider error kind to the status returned to our own clients
+var providerErrorStatus = map[llmerrors.Kind]int{
+	llmerrors.KindAuth:            http.StatusBadGateway,
+	llmerrors.KindQuotaExceeded:   http.StatusPaymentRequired,
+	llmerrors.KindRateLimited:     http.StatusTooManyRequests,
+	llmerrors.KindOverloaded:      http.StatusServiceUnavailable,
+	llmerrors.KindContextLength:   http.StatusBadRequest,
+	llmerrors.KindContentFiltered: http.StatusUnprocessableEntity,
+	llmerrors.KindBadRequest:      http.StatusBadRequest,
+	llmerrors.KindNotFound:        http.StatusNotFound,
+	llmerrors.KindNetwork:         http.StatusGatewayTimeout,
+	llmerrors.KindCanceled:        http.StatusGatewayTimeout,
+	llmerrors.KindUnknown:         http.StatusBadGateway,
+}
+
+// chatErrorDetails maps a chat failure to its HTTP status, machine readable code, message and retry delay
+func chatErrorDetails(provider types.Provider, err error) chatFailure {
+	var limitErr *ratelimit.LimitError
+	var providerErr *llmerrors.ProviderError
+
+	switch {
+	case errors.As(err, &limitErr):
+		return chatFailure{http.StatusTooManyRequests, "rate_limited", err.Error(), limitErr.RetryAfter}
+	case errors.Is(err, usage.ErrBudgetExceeded):
+		return chatFailure{http.StatusPaymentRequired, "budget_exceeded", err.Error(), 0}
+	case errors.Is(err, usage.ErrUnpricedModel):
+		return chatFailure{http.StatusPaymentRequired, "budget_unpriced_model", err.Error(), 0}
+	case errors.Is(err, chatservice.ErrInvalidChatRequest):
+		return chatFailure{http.StatusBadRequest, "invalid_request", err.Error(), 0}
+	case errors.Is(err, utils.ErrProviderNotSupported):
+		return chatFailure{http.StatusBadRequest, "provider_not_supported", fmt.Sprintf("Provider %s is not configured", provider), 0}
+	case errors.As(err, &providerErr):
+		status, ok := providerErrorStatus[providerErr.Kind]
+
+		if !ok {
+			status = http.StatusBadGateway
+		}
+
+		// After a fallback the failing provider may not be the one that was asked for
+		return chatFailure{status, "provider_" + string(providerErr.Kind), normalizeProviderError(string(providerErr.Provider), err), providerErr.RetryAfter}
+	default:
+		return chatFailure{http.StatusBadGateway, "provider_error", normalizeProviderError(string(provider), err), 0}
+	}
+}
+
+// logChatFailure logs a failed chat once, the chat service leaves logging to the handlers
+func logChatFailure(request *http.Request, action string, chatRequest *types.ChatRequest, err error) {
+	log.Printf(
+		"%s failed user=%q provider=%q model=%q err=%v",
+		action,
+		auth.User(request.Context()),
+		chatRequest.Provider,
+		chatRequest.ModelID,
+		err,
+	)
+}
+
+func writeChatError(response http.ResponseWriter, provider types.Provider, err error) {
+	failure := chatErrorDetails(provider, err)
+
+	setRetryAfter(response, failure.RetryAfter)
+
+	writeJSON(response, failure.Status, map[string]string{
+		"error": failure.Message,
+		"code":  failure.Code,
+	})
+}
+
+// describeModelFailures fills the code and message of providers whose model listing failed
+func describeModelFailures(statuses []types.ProviderModelsStatus) {
+	for index := range statuses {
+		status := &statuses[index]
+
+		if status.Err == nil {
+			continue
+		}
+
+		failure := chatErrorDetails(status.Provider, status.Err)
+		status.Code, status.Error = failure.Code, failure.Message
+	}
+}
+
+func setRetryAfter(response http.ResponseWriter, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+}
+
 func normalizeProviderError(provider string, err error) string {
+	var providerErr *llmerrors.ProviderError
+
+	if errors.As(err, &providerErr) {
+		switch providerErr.Kind {
+		case llmerrors.KindAuth:
+			return fmt.Sprintf("Invalid or missing API key for %s", provider)
+		case llmerrors.KindQuotaExceeded:
+			return fmt.Sprintf("The %s account has run out of quota or credits", provider)
+		case llmerrors.KindRateLimited:
+			return fmt.Sprintf("%s is rate limiting requests, try again shortly", provider)
+		case llmerrors.KindOverloaded:
+			return fmt.Sprintf("%s is overloaded or unavailable, try again shortly", provider)
+		case llmerrors.KindContextLength:
+			return fmt.Sprintf("The conversation is too long for this %s model", provider)
+		case llmerrors.KindContentFiltered:
+			return fmt.Sprintf("%s refused the request under its content policy", provider)
+		case llmerrors.KindBadRequest:
+			return fmt.Sprintf("Chat request was rejected by %s: %s", provider, providerErr.Message)
+		case llmerrors.KindNotFound:
+			return fmt.Sprintf("The requested model was not found on %s", provider)
+		case llmerrors.KindNetwork:
+			return fmt.Sprintf("%s could not be reached or timed out", provider)
+		case llmerrors.KindCanceled:
+			return "Chat request was canceled"
+		}
+	}
+
 	msg := strings.ToLower(err.Error())
 
 	if strings.Contains(msg, "401") || strings.Contains(msg, "unauthorized") {
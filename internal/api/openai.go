@@ -0,0 +1,435 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// The /v1 facade speaks the OpenAI chat completions wire format so existing SDKs and tools
+// can use AgentK as a gateway, models are addressed as "provider/model"
+
+type openAIChatRequest struct {
+	Model               string          `json:"model"`
+	Messages            json.RawMessage `json:"messages"`
+	MaxTokens           int64           `json:"max_tokens"`
+	MaxCompletionTokens int64           `json:"max_completion_tokens"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Tools []struct {
+		Function types.ToolDefinition `json:"function"`
+	} `json:"tools"`
+	ToolChoice json.RawMessage `json:"tool_choice"`
+	// Perplexity's search parameters, passed through so its SDK examples work against the gateway
+	SearchRecencyFilter string   `json:"search_recency_filter"`
+	SearchDomainFilter  []string `json:"search_domain_filter"`
+	WebSearchOptions    struct {
+		SearchContextSize string `json:"search_context_size"`
+	} `json:"web_search_options"`
+}
+
+type openAISearchResult struct {
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+	ExtraContent *openAIExtraContent `json:"extra_content,omitempty"`
+}
+
+// openAIExtraContent carries Gemini thought signatures the way Gemini's own OpenAI endpoint does
+type openAIExtraContent struct {
+	Google struct {
+		ThoughtSignature string `json:"thought_signature"`
+	} `json:"google"`
+}
+
+type openAIMessage struct {
+	Role      string           `json:"role,omitempty"`
+	Content   *string          `json:"content,omitempty"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int            `json:"index"`
+	Message      *openAIMessage `json:"message,omitempty"`
+	Delta        *openAIMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type openAIUsage struct {
+	PromptTokens        int64 `json:"prompt_tokens"`
+	CompletionTokens    int64 `json:"completion_tokens"`
+	TotalTokens         int64 `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+	// Sources are reported the way Perplexity does, as bare URLs and as search results
+	Citations     []string             `json:"citations,omitempty"`
+	SearchResults []openAISearchResult `json:"search_results,omitempty"`
+}
+
+var openAIFinishReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+	"refusal":       "content_filter",
+	// Gemini finish reasons
+	"STOP":               "stop",
+	"MAX_TOKENS":         "length",
+	"SAFETY":             "content_filter",
+	"RECITATION":         "content_filter",
+	"PROHIBITED_CONTENT": "content_filter",
+	"BLOCKLIST":          "content_filter",
+	"SPII":               "content_filter",
+	// Cohere finish reasons
+	"COMPLETE":      "stop",
+	"STOP_SEQUENCE": "stop",
+	"TOOL_CALL":     "tool_calls",
+}
+
+func OpenAIChatCompletionsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeOpenAIError(response, http.StatusMethodNotAllowed, "invalid_request_error", "Method Not Allowed", nil)
+		return
+	}
+
+	body := &openAIChatRequest{}
+
+	// Unknown fields such as temperature are tolerated since SDKs send them by default
+	if err := json.NewDecoder(http.MaxBytesReader(response, request.Body, 10<<20)).Decode(body); err != nil {
+		writeOpenAIError(response, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid JSON: %v", err), nil)
+		return
+	}
+
+	chatRequest, err := buildOpenAIChatRequest(body)
+
+	if err != nil {
+		writeOpenAIError(response, http.StatusBadRequest, "invalid_request_error", err.Error(), nil)
+		return
+	}
+
+	completion := &openAICompletion{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   body.Model,
+	}
+
+	if body.Stream {
+		streamOpenAICompletion(response, request, chatRequest, completion, body.StreamOptions.IncludeUsage)
+		return
+	}
+
+	result, err := chatservice.GenerateChatResponse(request.Context(), chatRequest)
+
+	if err != nil {
+		logChatFailure(request, "completion", chatRequest, err)
+
+		failure, errorType := openAIErrorDetails(response, chatRequest.Provider, err)
+		writeOpenAIErrorJSON(response, failure.Status, errorType, failure.Message, failure.Code)
+		return
+	}
+
+	finishReason := openAIFinishReason(result)
+
+	// A fallback model answered, so report it rather than the one asked for
+	if len(result.FallbackFrom) > 0 {
+		completion.Model = string(result.Provider) + "/" + result.Model
+	}
+
+	completion.Object = "chat.completion"
+	completion.Usage = buildOpenAIUsage(result.Usage)
+	completion.Citations, completion.SearchResults = buildOpenAISources(result.Sources)
+	completion.Choices = []openAIChoice{{
+		Message: &openAIMessage{
+			Role:      "assistant",
+			Content:   &result.Text,
+			ToolCalls: buildOpenAIToolCalls(result.ToolCalls),
+		},
+		FinishReason: &finishReason,
+	}}
+
+	writeJSON(response, http.StatusOK, completion)
+}
+
+func streamOpenAICompletion(response http.ResponseWriter, request *http.Request, chatRequest *types.ChatRequest, completion *openAICompletion, includeUsage bool) {
+	flusher, ok := response.(http.Flusher)
+
+	if !ok {
+		writeOpenAIError(response, http.StatusInternalServerError, "server_error", "Streaming unsupported", nil)
+		return
+	}
+
+	completion.Object = "chat.completion.chunk"
+	headersSent := false
+
+	writeChunk := func(choices []openAIChoice, usage *openAIUsage) error {
+		if !headersSent {
+			startSSE(response, flusher)
+			headersSent = true
+		}
+
+		chunk := *completion
+		chunk.Choices = choices
+		chunk.Usage = usage
+
+		return writeSSEData(response, flusher, chunk)
+	}
+
+	// Fallbacks only take over before the first chunk, so every chunk names the model that served it
+	result, err := chatservice.StreamChatResponse(request.Context(), chatRequest, func(served *types.ChatRequest, delta string) error {
+		if served != chatRequest {
+			completion.Model = string(served.Provider) + "/" + served.ModelID
+		}
+
+		return writeChunk([]openAIChoice{{Delta: &openAIMessage{Role: "assistant", Content: &delta}}}, nil)
+	})
+
+	if err != nil {
+		logChatFailure(request, "completion stream", chatRequest, err)
+
+		failure, errorType := openAIErrorDetails(response, chatRequest.Provider, err)
+
+		// Before the first chunk a normal error response is still possible
+		if !headersSent {
+			writeOpenAIErrorJSON(response, failure.Status, errorType, failure.Message, failure.Code)
+			return
+		}
+
+		_ = writeSSEData(response, flusher, map[string]any{"error": map[string]string{"message": failure.Message, "type": errorType, "code": failure.Code}})
+		return
+	}
+
+	finishReason := openAIFinishReason(result)
+	completion.Citations, completion.SearchResults = buildOpenAISources(result.Sources)
+
+	// A fallback that answered with tool calls alone sent no chunk to name it yet
+	if len(result.FallbackFrom) > 0 && !headersSent {
+		completion.Model = string(result.Provider) + "/" + result.Model
+	}
+
+	// Tool calls and sources are only known once the stream completes, so they arrive with the final chunk
+	_ = writeChunk([]openAIChoice{{
+		Delta:        &openAIMessage{ToolCalls: buildOpenAIToolCalls(result.ToolCalls)},
+		FinishReason: &finishReason,
+	}}, nil)
+
+	if includeUsage {
+		_ = writeChunk([]openAIChoice{}, buildOpenAIUsage(result.Usage))
+	}
+
+	_, _ = fmt.Fprint(response, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+func OpenAIModelsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeOpenAIError(response, http.StatusMethodNotAllowed, "invalid_request_error", "Method Not Allowed", nil)
+		return
+	}
+
+	models := chatservice.GetAllModels(request.Context()).Models
+	data := make([]map[string]any, 0, len(models))
+
+	for _, model := range models {
+		if !model.Enabled {
+			continue
+		}
+
+		data = append(data, map[string]any{
+			"id":       fmt.Sprintf("%s/%s", model.Provider, model.ID),
+			"object":   "model",
+			"created":  0,
+			"owned_by": model.Provider,
+		})
+	}
+
+	writeJSON(response, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func buildOpenAIChatRequest(body *openAIChatRequest) (*types.ChatRequest, error) {
+	provider, modelID, err := chatservice.ResolveModelName(body.Model)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The chat service reads OpenAI messages as they are, system messages included
+	chatRequest := &types.ChatRequest{
+		ModelID:  modelID,
+		Provider: provider,
+		Context:  body.Messages,
+		Tokens:   max(body.MaxCompletionTokens, body.MaxTokens),
+	}
+
+	for _, tool := range body.Tools {
+		chatRequest.Tools = append(chatRequest.Tools, tool.Function)
+	}
+
+	if body.SearchRecencyFilter != "" || len(body.SearchDomainFilter) > 0 || body.WebSearchOptions.SearchContextSize != "" {
+		chatRequest.Search = &types.SearchOptions{
+			RecencyFilter: body.SearchRecencyFilter,
+			DomainFilter:  body.SearchDomainFilter,
+			ContextSize:   body.WebSearchOptions.SearchContextSize,
+		}
+	}
+
+	if len(body.ToolChoice) > 0 {
+		// tool_choice is either a mode string or {"type": "function", "function": {"name": ...}}
+		var named struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		}
+
+		if err := json.Unmarshal(body.ToolChoice, &chatRequest.ToolChoice); err != nil {
+			if err := json.Unmarshal(body.ToolChoice, &named); err != nil {
+				return nil, fmt.Errorf("invalid tool_choice: %w", err)
+			}
+
+			chatRequest.ToolChoice = named.Function.Name
+		}
+	}
+
+	return chatRequest, nil
+}
+
+func buildOpenAIToolCalls(toolCalls []types.ToolCall) []openAIToolCall {
+	results := make([]openAIToolCall, 0, len(toolCalls))
+
+	for index, toolCall := range toolCalls {
+		result := openAIToolCall{
+			Index: index,
+			ID:    toolCall.ID,
+			Type:  "function",
+		}
+
+		result.Function.Name = toolCall.Name
+		result.Function.Arguments = string(toolCall.Arguments)
+
+		if toolCall.Signature != "" {
+			result.ExtraContent = &openAIExtraContent{}
+			result.ExtraContent.Google.ThoughtSignature = toolCall.Signature
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func buildOpenAISources(sources []types.Source) ([]string, []openAISearchResult) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	citations := make([]string, 0, len(sources))
+	results := make([]openAISearchResult, 0, len(sources))
+
+	for _, source := range sources {
+		citations = append(citations, source.URL)
+		results = append(results, openAISearchResult{Title: source.Title, URL: source.URL, Snippet: source.Snippet})
+	}
+
+	return citations, results
+}
+
+func buildOpenAIUsage(usage types.Usage) *openAIUsage {
+	result := &openAIUsage{
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+
+	result.PromptTokensDetails.CachedTokens = usage.CachedTokens
+
+	return result
+}
+
+func openAIFinishReason(result *types.ChatResult) string {
+	// Gemini finishes tool calling turns with a plain STOP
+	if len(result.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+
+	if reason, ok := openAIFinishReasons[result.StopReason]; ok {
+		return reason
+	}
+
+	if result.StopReason == "" {
+		return "stop"
+	}
+
+	return result.StopReason
+}
+
+// openAIErrorDetails returns the chat failure reshaped for OpenAI clients along with its error type
+func openAIErrorDetails(response http.ResponseWriter, provider types.Provider, err error) (chatFailure, string) {
+	failure := chatErrorDetails(provider, err)
+
+	setRetryAfter(response, failure.RetryAfter)
+
+	switch failure.Status {
+	case http.StatusPaymentRequired:
+		// OpenAI clients expect quota exhaustion as a 429
+		failure.Status = http.StatusTooManyRequests
+		return failure, "insufficient_quota"
+	case http.StatusTooManyRequests:
+		return failure, "rate_limit_error"
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity:
+		return failure, "invalid_request_error"
+	default:
+		return failure, "api_error"
+	}
+}
+
+func writeOpenAIError(response http.ResponseWriter, status int, errorType string, msg string, code any) {
+	log.Printf("[ERROR %d]: %s", status, msg)
+
+	writeOpenAIErrorJSON(response, status, errorType, msg, code)
+}
+
+// writeOpenAIErrorJSON writes the error without logging it, for failures the caller already logged
+func writeOpenAIErrorJSON(response http.ResponseWriter, status int, errorType string, msg string, code any) {
+	writeJSON(response, status, map[string]any{
+		"error": map[string]any{
+			"message": msg,
+			"type":    errorType,
+			"code":    code,
+		},
+	})
+}
+
+func newCompletionID() string {
+	bytes := make([]byte, 12)
+	_, _ = rand.Read(bytes)
+
+	return "chatcmpl-" + hex.EncodeToString(bytes)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// streamClient streams its deltas, or fails as overloaded when it has none
+type streamClient struct {
+	llms.LLMClient
+
+	provider types.Provider
+	deltas   []string
+}
+
+func (c streamClient) Encode(messages []types.Message) (any, error) {
+	return messages, nil
+}
+
+func (c streamClient) ChatStream(ctx context.Context, request *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error) {
+	if len(c.deltas) == 0 {
+		return nil, llmerrors.Classify(c.provider, errors.New("overloaded"), 529, "overloaded_error", "Overloaded", nil)
+	}
+
+	for _, delta := range c.deltas {
+		if err := onDelta(delta); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.ChatResult{Text: strings.Join(c.deltas, ""), Model: request.ModelID + "-2025-01-01"}, nil
+}
+
+func TestOpenAIStreamNamesFallbackModel(t *testing.T) {
+	t.Setenv("RETRY_MAX_ATTEMPTS", "1")
+
+	previous := llms.Clients()
+	llms.SwapClients(map[types.Provider]llms.LLMClient{
+		"Primary": streamClient{provider: "Primary"},
+		"Backup":  streamClient{provider: "Backup", deltas: []string{"hel", "lo"}},
+	})
+	chatservice.SetFallbackChains(map[string][]string{"primary/busy": {"Backup/steady"}})
+
+	t.Cleanup(func() {
+		llms.SwapClients(previous)
+		chatservice.SetFallbackChains(nil)
+	})
+
+	body := `{"model":"Primary/busy","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	recorder := httptest.NewRecorder()
+
+	OpenAIChatCompletionsHandler(recorder, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	chunks := 0
+	scanner := bufio.NewScanner(recorder.Body)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+
+		if !ok || data == "[DONE]" {
+			continue
+		}
+
+		var chunk struct {
+			Model string `json:"model"`
+		}
+
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+
+		if chunk.Model != "Backup/steady" {
+			t.Errorf("chunk %d names %q, want the fallback Backup/steady", chunks, chunk.Model)
+		}
+
+		chunks++
+	}
+
+	if chunks != 3 {
+		t.Errorf("got %d chunks, want two deltas and the final chunk", chunks)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	"github.com/CodingWithKarim/AgentK/internal/storage"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type sessionRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func SessionsHandler(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		sessions, err := storage.Sessions.ListSessions(auth.User(request.Context()))
+
+		if err != nil {
+			writeError(response, http.StatusInternalServerError, fmt.Sprintf("Unable to fetch sessions: %v", err))
+			return
+		}
+
+		writeJSON(response, http.StatusOK, map[string]any{"sessions": sessions})
+
+	case http.MethodPost:
+		body := &sessionRequest{}
+
+		if err := decodeJSONBody(response, request, body); err != nil {
+			writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+			return
+		}
+
+		name := strings.TrimSpace(body.Name)
+
+		if name == "" {
+			writeError(response, http.StatusBadRequest, "Session name is required")
+			return
+		}
+
+		session, err := storage.Sessions.CreateSession(auth.User(request.Context()), name)
+
+		if err != nil {
+			writeError(response, http.StatusInternalServerError, fmt.Sprintf("Unable to create session: %v", err))
+			return
+		}
+
+		writeJSON(response, http.StatusCreated, session)
+
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func DeleteSessionHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodDelete {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if err := storage.Sessions.DeleteSession(auth.User(request.Context()), request.PathValue("id")); err != nil {
+		writeStoreError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func RenameSessionHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	body := &sessionRequest{}
+
+	if err := decodeJSONBody(response, request, body); err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+
+	if body.ID == "" || name == "" {
+		writeError(response, http.StatusBadRequest, "Session id and name are required")
+		return
+	}
+
+	session, err := storage.Sessions.RenameSession(auth.User(request.Context()), body.ID, name)
+
+	if err != nil {
+		writeStoreError(response, err)
+		return
+	}
+
+	writeJSON(response, http.StatusOK, session)
+}
+
+func MessagesHandler(response http.ResponseWriter, request *http.Request) {
+	sessionID := request.PathValue("id")
+
+	switch request.Method {
+	case http.MethodGet:
+		// Optional modelID filter mirrors the isolated context mode in the UI
+		messages, err := storage.Sessions.ListMessages(auth.User(request.Context()), sessionID, request.URL.Query().Get("modelID"))
+
+		if err != nil {
+			writeStoreError(response, err)
+			return
+		}
+
+		writeJSON(response, http.StatusOK, map[string]any{"messages": messages})
+
+	case http.MethodPost:
+		message := &types.StoredMessage{}
+
+		if err := decodeJSONBody(response, request, message); err != nil {
+			writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+			return
+		}
+
+		message.SessionID = sessionID
+
+		stored, err := storage.Sessions.AddMessage(auth.User(request.Context()), message)
+
+		if err != nil {
+			writeStoreError(response, err)
+			return
+		}
+
+		writeJSON(response, http.StatusCreated, stored)
+
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func MessageHandler(response http.ResponseWriter, request *http.Request) {
+	sessionID := request.PathValue("id")
+
+	messageID, err := strconv.ParseInt(request.PathValue("messageID"), 10, 64)
+
+	if err != nil {
+		writeError(response, http.StatusBadRequest, "Invalid message id")
+		return
+	}
+
+	switch request.Method {
+	case http.MethodPut:
+		message := &types.StoredMessage{}
+
+		if err := decodeJSONBody(response, request, message); err != nil {
+			writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+			return
+		}
+
+		message.ID = messageID
+		message.SessionID = sessionID
+
+		updated, err := storage.Sessions.UpdateMessage(auth.User(request.Context()), message)
+
+		if err != nil {
+			writeStoreError(response, err)
+			return
+		}
+
+		writeJSON(response, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		if err := storage.Sessions.DeleteMessage(auth.User(request.Context()), sessionID, messageID); err != nil {
+			writeStoreError(response, err)
+			return
+		}
+
+		response.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func writeStoreError(response http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrInvalidMessage) {
+		writeError(response, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, storage.ErrMessageNotFound) {
+		writeError(response, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeError(response, http.StatusInternalServerError, fmt.Sprintf("Storage error: %v", err))
+}
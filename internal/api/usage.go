@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	"github.com/CodingWithKarim/AgentK/internal/usage"
+)
+
+// UsageHandler aggregates recorded spend, from and to are inclusive UTC dates (YYYY-MM-DD). Callers
+// who aren't admins only see their own
+func UsageHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeError(response, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	from, err := parseDayParam(request.URL.Query().Get("from"), 0)
+
+	if err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid from date: %v", err))
+		return
+	}
+
+	// Advance the end bound a full day so the to date is included
+	to, err := parseDayParam(request.URL.Query().Get("to"), 24*time.Hour)
+
+	if err != nil {
+		writeError(response, http.StatusBadRequest, fmt.Sprintf("Invalid to date: %v", err))
+		return
+	}
+
+	report, err := buildUsageReport(request, from, to)
+
+	if err != nil {
+		writeError(response, http.StatusInternalServerError, fmt.Sprintf("Unable to build usage report: %v", err))
+		return
+	}
+
+	writeJSON(response, http.StatusOK, report)
+}
+
+// buildUsageReport shows admins everyone's spend and anyone else only their own. Without auth every call
+// is recorded anonymously, so the anonymous caller's records are all of them
+func buildUsageReport(request *http.Request, from int64, to int64) (*usage.Report, error) {
+	user := auth.User(request.Context())
+
+	if user == "" || auth.IsAdmin(request.Context()) {
+		return usage.BuildReport(from, to)
+	}
+
+	return usage.BuildUserReport(from, to, user)
+}
+
+func parseDayParam(value string, offset time.Duration) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return day.Add(offset).UnixMilli(), nil
+}
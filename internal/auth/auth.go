@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+type Identity struct {
+	User   string
+	Method string
+	IP     string
+}
+
+// Authenticator reports the caller's identity, ok is false when the request carries no
+// credentials this authenticator recognises
+type Authenticator interface {
+	Authenticate(request *http.Request) (identity *Identity, ok bool)
+}
+
+type identityKey struct{}
+
+// Config is the auth setup in effect, a reload swaps it whole so no request sees half of one.
+// Admins may call the /api/admin endpoints, admin access is impossible while auth is disabled
+type Config struct {
+	Authenticators []Authenticator
+	Admins         map[string]bool
+	TrustProxy     bool
+}
+
+var current atomic.Pointer[Config]
+
+// PublicPaths are served without credentials so health checks keep working
+var PublicPaths = []string{"/api/health"}
+
+// SetConfig swaps the auth setup, requests already past the middleware keep the identity they were given
+func SetConfig(config *Config) {
+	current.Store(config)
+}
+
+func currentConfig() *Config {
+	if config := current.Load(); config != nil {
+		return config
+	}
+
+	return &Config{}
+}
+
+// Middleware attaches the caller's identity to every request context, anonymous callers
+// are identified by IP alone when no authenticators are configured
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		config := currentConfig()
+		authenticators := config.Authenticators
+		ip := clientIP(request, config.TrustProxy)
+
+		if len(authenticators) == 0 {
+			next.ServeHTTP(response, request.WithContext(WithIdentity(request.Context(), &Identity{IP: ip})))
+			return
+		}
+
+		for _, path := range PublicPaths {
+			if request.URL.Path == path {
+				next.ServeHTTP(response, request)
+				return
+			}
+		}
+
+		for _, authenticator := range authenticators {
+			if identity, ok := authenticator.Authenticate(request); ok {
+				identity.IP = ip
+				next.ServeHTTP(response, request.WithContext(WithIdentity(request.Context(), identity)))
+				return
+			}
+		}
+
+		// Let browsers prompt for credentials when basic users are configured
+		if hasBasicUsers(authenticators) {
+			response.Header().Set("WWW-Authenticate", `Basic realm="AgentK", charset="UTF-8"`)
+		}
+
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(http.StatusUnauthorized)
+		_, _ = response.Write([]byte(`{"error":"Unauthorized"}` + "\n"))
+	})
+}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// User returns the authenticated user for a request context, or "" when auth is disabled
+func User(ctx context.Context) string {
+	if identity, ok := ctx.Value(identityKey{}).(*Identity); ok {
+		return identity.User
+	}
+
+	return ""
+}
+
+func IsAdmin(ctx context.Context) bool {
+	user := User(ctx)
+
+	return user != "" && currentConfig().Admins[user]
+}
+
+// Caller keys per-caller limits, the user when authenticated and the client IP otherwise
+func Caller(ctx context.Context) string {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+
+	if !ok {
+		return "anonymous"
+	}
+
+	if identity.User != "" {
+		return "user:" + identity.User
+	}
+
+	return "ip:" + identity.IP
+}
+
+// clientIP honours proxy headers only when TRUST_PROXY_HEADERS is set, otherwise
+// any caller could pick their own rate limit key
+func clientIP(request *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := request.Header.Get("Fly-Client-IP"); ip != "" {
+			return ip
+		}
+
+		if forwarded := request.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
+
+func hasBasicUsers(authenticators []Authenticator) bool {
+	for _, authenticator := range authenticators {
+		if _, ok := authenticator.(*BasicUsers); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseUserList splits "user:secret,user:secret" into a map, secrets may contain colons
+func parseUserList(value string) map[string]string {
+	entries := map[string]string{}
+
+	for _, entry := range strings.Split(value, ",") {
+		user, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+
+		if ok && user != "" && secret != "" {
+			entries[user] = secret
+		}
+	}
+
+	return entries
+}
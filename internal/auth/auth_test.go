@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useEnvConfig parses the auth setup from env and swaps it in for the test
+func useEnvConfig(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	for _, name := range []string{"AUTH_TOKENS", "AUTH_BASIC_USERS", "ADMIN_USERS", "TRUST_PROXY_HEADERS"} {
+		t.Setenv(name, env[name])
+	}
+
+	config, err := ParseFromEnv()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := current.Load()
+	SetConfig(config)
+
+	t.Cleanup(func() {
+		current.Store(previous)
+	})
+}
+
+// serve runs request through the middleware and returns the status and the identity the handler saw
+func serve(request *http.Request) (int, *Identity) {
+	var seen *Identity
+
+	handler := Middleware(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		seen, _ = request.Context().Value(identityKey{}).(*Identity)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return recorder.Code, seen
+}
+
+func TestMiddlewareAuthenticates(t *testing.T) {
+	digest := sha256.Sum256([]byte("hunter2"))
+
+	useEnvConfig(t, map[string]string{
+		"AUTH_TOKENS":      "alice:tok-alice,bob:tok-bob",
+		"AUTH_BASIC_USERS": "carol:pa:ss,dave:sha256:" + hex.EncodeToString(digest[:]),
+	})
+
+	cases := []struct {
+		name   string
+		setup  func(request *http.Request)
+		user   string
+		method string
+	}{
+		{"no credentials", func(*http.Request) {}, "", ""},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok-bob") }, "bob", "bearer"},
+		{"unknown bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok-eve") }, "", ""},
+		{"empty bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, "", ""},
+		{"basic password with a colon", func(r *http.Request) { r.SetBasicAuth("carol", "pa:ss") }, "carol", "basic"},
+		{"basic hashed password", func(r *http.Request) { r.SetBasicAuth("dave", "hunter2") }, "dave", "basic"},
+		{"basic wrong password", func(r *http.Request) { r.SetBasicAuth("dave", "hunter3") }, "", ""},
+		{"basic unknown user", func(r *http.Request) { r.SetBasicAuth("eve", "pa:ss") }, "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/chat", nil)
+			tc.setup(request)
+
+			status, identity := serve(request)
+
+			if tc.user == "" {
+				if status != http.StatusUnauthorized || identity != nil {
+					t.Fatalf("status = %d with identity %+v, want 401", status, identity)
+				}
+
+				return
+			}
+
+			if status != http.StatusOK || identity == nil || identity.User != tc.user || identity.Method != tc.method {
+				t.Fatalf("status = %d with identity %+v, want %s by %s", status, identity, tc.user, tc.method)
+			}
+		})
+	}
+
+	// Basic users make browsers prompt for credentials
+	recorder := httptest.NewRecorder()
+	Middleware(http.NotFoundHandler()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/chat", nil))
+
+	if recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Error("401 without a WWW-Authenticate challenge")
+	}
+
+	if status, _ := serve(httptest.NewRequest(http.MethodGet, "/api/health", nil)); status != http.StatusOK {
+		t.Errorf("public path status = %d, want 200", status)
+	}
+}
+
+func TestMiddlewareWithoutAuthenticators(t *testing.T) {
+	useEnvConfig(t, map[string]string{})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/chat", nil)
+	request.RemoteAddr = "203.0.113.9:5000"
+	request.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	status, identity := serve(request)
+
+	if status != http.StatusOK || identity == nil || identity.User != "" || identity.IP != "203.0.113.9" {
+		t.Fatalf("status = %d with identity %+v, want an anonymous caller keyed by the peer address", status, identity)
+	}
+
+	if caller := Caller(WithIdentity(context.Background(), identity)); caller != "ip:203.0.113.9" {
+		t.Errorf("caller = %q", caller)
+	}
+
+	// Proxy headers count only once they're trusted
+	useEnvConfig(t, map[string]string{"TRUST_PROXY_HEADERS": "true"})
+
+	if _, identity := serve(request); identity == nil || identity.IP != "198.51.100.1" {
+		t.Errorf("identity behind a trusted proxy = %+v, want the forwarded address", identity)
+	}
+}
+
+func TestSetConfigSwapsCredentials(t *testing.T) {
+	useEnvConfig(t, map[string]string{"AUTH_TOKENS": "alice:old-token"})
+
+	// The same middleware picks up the swapped config on the next request
+	handler := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	status := func(token string) int {
+		request := httptest.NewRequest(http.MethodGet, "/api/chat", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	if got := status("old-token"); got != http.StatusOK {
+		t.Fatalf("old token before the swap: status = %d", got)
+	}
+
+	useEnvConfig(t, map[string]string{"AUTH_TOKENS": "alice:new-token"})
+
+	if got := status("old-token"); got != http.StatusUnauthorized {
+		t.Errorf("old token after the swap: status = %d, want 401", got)
+	}
+
+	if got := status("new-token"); got != http.StatusOK {
+		t.Errorf("new token after the swap: status = %d, want 200", got)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	useEnvConfig(t, map[string]string{"AUTH_TOKENS": "alice:a,bob:b", "ADMIN_USERS": " alice , "})
+
+	alice := WithIdentity(context.Background(), &Identity{User: "alice"})
+	bob := WithIdentity(context.Background(), &Identity{User: "bob"})
+	anonymous := WithIdentity(context.Background(), &Identity{IP: "203.0.113.9"})
+
+	if !IsAdmin(alice) || IsAdmin(bob) || IsAdmin(anonymous) || IsAdmin(context.Background()) {
+		t.Errorf("admins = alice %v, bob %v, anonymous %v, want only alice", IsAdmin(alice), IsAdmin(bob), IsAdmin(anonymous))
+	}
+
+	useEnvConfig(t, map[string]string{"AUTH_TOKENS": "alice:a"})
+
+	if IsAdmin(alice) {
+		t.Error("alice is still an admin after ADMIN_USERS was cleared")
+	}
+}
+
+func TestParseFromEnvRejectsEmptyLists(t *testing.T) {
+	for _, name := range []string{"AUTH_TOKENS", "AUTH_BASIC_USERS"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("AUTH_TOKENS", "")
+			t.Setenv("AUTH_BASIC_USERS", "")
+			t.Setenv(name, "no-separator")
+
+			if _, err := ParseFromEnv(); err == nil {
+				t.Errorf("%s without user:secret entries was accepted", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// BearerTokens maps static API tokens to user names
+type BearerTokens struct {
+	users map[string]string
+}
+
+// BasicUsers maps user names to passwords, stored in plain text or as "sha256:<hex digest>"
+type BasicUsers struct {
+	passwords map[string]string
+}
+
+// LoadFromEnv parses the auth setup from the environment and swaps it in, see ParseFromEnv
+func LoadFromEnv() error {
+	config, err := ParseFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	SetConfig(config)
+
+	return nil
+}
+
+// ParseFromEnv builds authenticators from AUTH_TOKENS ("alice:token,bob:token") and
+// AUTH_BASIC_USERS ("alice:password,bob:sha256:<hex>"), auth is disabled when neither is set.
+// ADMIN_USERS ("alice,bob") names the users allowed on the admin endpoints
+func ParseFromEnv() (*Config, error) {
+	authenticators := make([]Authenticator, 0, 2)
+
+	if value := os.Getenv("AUTH_TOKENS"); value != "" {
+		tokens := &BearerTokens{users: map[string]string{}}
+
+		for user, token := range parseUserList(value) {
+			tokens.users[token] = user
+		}
+
+		if len(tokens.users) == 0 {
+			return nil, fmt.Errorf("AUTH_TOKENS is set but has no user:token entries")
+		}
+
+		authenticators = append(authenticators, tokens)
+	}
+
+	if value := os.Getenv("AUTH_BASIC_USERS"); value != "" {
+		basic := &BasicUsers{passwords: parseUserList(value)}
+
+		if len(basic.passwords) == 0 {
+			return nil, fmt.Errorf("AUTH_BASIC_USERS is set but has no user:password entries")
+		}
+
+		authenticators = append(authenticators, basic)
+	}
+
+	admins := map[string]bool{}
+
+	for _, user := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			admins[user] = true
+		}
+	}
+
+	if len(authenticators) == 0 {
+		log.Println("[WARN] No AUTH_TOKENS or AUTH_BASIC_USERS configured, the API is open to anyone who can reach it")
+	}
+
+	return &Config{
+		Authenticators: authenticators,
+		Admins:         admins,
+		TrustProxy:     os.Getenv("TRUST_PROXY_HEADERS") == "true",
+	}, nil
+}
+
+func (b *BearerTokens) Authenticate(request *http.Request) (*Identity, bool) {
+	token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+
+	if !ok || token == "" {
+		return nil, false
+	}
+
+	// Compare against every token so timing doesn't reveal which prefix matched
+	matchedUser := ""
+
+	for candidate, user := range b.users {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			matchedUser = user
+		}
+	}
+
+	if matchedUser == "" {
+		return nil, false
+	}
+
+	return &Identity{User: matchedUser, Method: "bearer"}, true
+}
+
+func (b *BasicUsers) Authenticate(request *http.Request) (*Identity, bool) {
+	user, password, ok := request.BasicAuth()
+
+	if !ok {
+		return nil, false
+	}
+
+	expected, ok := b.passwords[user]
+
+	if !ok {
+		return nil, false
+	}
+
+	if digest, hashed := strings.CutPrefix(expected, "sha256:"); hashed {
+		sum := sha256.Sum256([]byte(password))
+		expected, password = strings.ToLower(digest), hex.EncodeToString(sum[:])
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 {
+		return nil, false
+	}
+
+	return &Identity{User: user, Method: "basic"}, true
+}
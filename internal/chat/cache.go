@@ -0,0 +1,314 @@
+package chatservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	DefaultModelCacheTTL     = 10 * time.Minute
+	DefaultModelFetchTimeout = 10 * time.Second
+
+	// A provider whose listing just failed isn't asked again on every page load
+	modelRetryInterval = 30 * time.Second
+)
+
+// modelCache keeps each provider's last model list. Expired lists are still served while a
+// background fetch replaces them, so only a provider that was never listed makes callers wait
+var modelCache = &modelCacheStore{entries: map[types.Provider]*modelCacheEntry{}}
+
+type modelCacheStore struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[types.Provider]*modelCacheEntry
+	// generation numbers snapshots so a save that lost the race to a newer one is dropped
+	generation uint64
+
+	saveMutex       sync.Mutex
+	savedGeneration uint64
+}
+
+type modelCacheEntry struct {
+	Models    []*types.Model `json:"models"`
+	FetchedAt int64          `json:"fetchedAt"`
+
+	err      error
+	failedAt time.Time
+	fetching chan struct{} // closed when the running fetch ends
+}
+
+// providerModels is one provider's share of a listing
+type providerModels struct {
+	models []*types.Model
+	status *types.ProviderModelsStatus
+	stale  bool
+}
+
+// InitializeModelCache enables the on-disk copy of the cache when MODEL_CACHE_FILE is set,
+// so a restart serves the last lists straight away and refreshes them in the background
+func InitializeModelCache() {
+	path := os.Getenv("MODEL_CACHE_FILE")
+
+	if path == "" {
+		return
+	}
+
+	modelCache.mutex.Lock()
+	defer modelCache.mutex.Unlock()
+
+	modelCache.path = path
+
+	bytes, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	entries := map[types.Provider]*modelCacheEntry{}
+
+	if err == nil {
+		err = json.Unmarshal(bytes, &entries)
+	}
+
+	// A broken cache file only costs a refetch
+	if err != nil {
+		log.Printf("ignoring model cache file=%q err=%v", path, err)
+		return
+	}
+
+	modelCache.entries = entries
+
+	log.Printf("Loaded model cache providers=%d", len(entries))
+}
+
+// InvalidateModelCache drops the cached lists of the given providers, or of every provider when none
+// are given, after a reload or key change may have altered what they return
+func InvalidateModelCache(providers ...types.Provider) {
+	modelCache.mutex.Lock()
+
+	if len(providers) == 0 {
+		modelCache.entries = map[types.Provider]*modelCacheEntry{}
+	}
+
+	for _, provider := range providers {
+		delete(modelCache.entries, provider)
+	}
+
+	snapshot, generation := modelCache.snapshot()
+
+	modelCache.mutex.Unlock()
+
+	modelCache.save(snapshot, generation)
+}
+
+// get serves a provider's cached list, starting a refresh when it has expired. With nothing
+// cached it waits for the fetch, but no longer than the provider's MODEL_FETCH_TIMEOUT
+func (c *modelCacheStore) get(ctx context.Context, provider types.Provider, client llms.LLMClient) providerModels {
+	c.mutex.Lock()
+
+	entry := c.entry(provider)
+	age := time.Since(time.UnixMilli(entry.FetchedAt))
+
+	if entry.FetchedAt > 0 && age < utils.GetProviderDuration(provider, "MODEL_CACHE_TTL", DefaultModelCacheTTL) {
+		models := entry.Models
+		c.mutex.Unlock()
+
+		return providerModels{models: models}
+	}
+
+	done := c.refresh(provider, client, entry)
+
+	if entry.FetchedAt > 0 {
+		result := providerModels{models: entry.Models, status: entry.status(provider), stale: true}
+		c.mutex.Unlock()
+
+		return result
+	}
+
+	c.mutex.Unlock()
+
+	if done != nil {
+		timeout := utils.GetProviderDuration(provider, "MODEL_FETCH_TIMEOUT", DefaultModelFetchTimeout)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return providerModels{status: &types.ProviderModelsStatus{Provider: provider, Err: llmerrors.ClassifyTransport(provider, ctx.Err())}}
+		case <-timer.C:
+			// The fetch carries on and fills the cache for the next caller
+			err := fmt.Errorf("%s model list took longer than %s: %w", provider, timeout, context.DeadlineExceeded)
+
+			return providerModels{status: &types.ProviderModelsStatus{Provider: provider, Err: llmerrors.ClassifyTransport(provider, err)}}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if entry.FetchedAt == 0 {
+		return providerModels{status: entry.status(provider)}
+	}
+
+	return providerModels{models: entry.Models}
+}
+
+// refresh starts a background fetch unless one is running or the last one failed moments ago,
+// returning a channel closed when the fetch ends. The caller holds the mutex
+func (c *modelCacheStore) refresh(provider types.Provider, client llms.LLMClient, entry *modelCacheEntry) chan struct{} {
+	if entry.fetching != nil {
+		return entry.fetching
+	}
+
+	if entry.err != nil && time.Since(entry.failedAt) < modelRetryInterval {
+		return nil
+	}
+
+	done := make(chan struct{})
+	entry.fetching = done
+
+	go func() {
+		// Detached from the request so a slow provider still lands in the cache, the client applies its own timeout
+		_, _ = c.fetch(context.Background(), provider, client, entry)
+
+		c.mutex.Lock()
+		entry.fetching = nil
+		c.mutex.Unlock()
+
+		close(done)
+	}()
+
+	return done
+}
+
+// fetch lists a provider's models and stores the outcome in entry
+func (c *modelCacheStore) fetch(ctx context.Context, provider types.Provider, client llms.LLMClient, entry *modelCacheEntry) ([]*types.Model, error) {
+	models, err := client.Models(ctx)
+
+	if err == nil {
+		enrichModels(models)
+	} else {
+		log.Printf("failed to get models for provider=%q err=%v", provider, err)
+	}
+
+	c.mutex.Lock()
+
+	if err != nil {
+		entry.err, entry.failedAt = err, time.Now()
+	} else {
+		entry.Models, entry.FetchedAt, entry.err = models, time.Now().UnixMilli(), nil
+	}
+
+	// An entry invalidated mid-fetch has been replaced and must not be written back
+	current := c.entries[provider] == entry
+	snapshot, generation := c.snapshot()
+
+	c.mutex.Unlock()
+
+	if current && err == nil {
+		c.save(snapshot, generation)
+	}
+
+	return models, err
+}
+
+// entry returns the provider's cache entry, creating it if needed. The caller holds the mutex
+func (c *modelCacheStore) entry(provider types.Provider) *modelCacheEntry {
+	entry, ok := c.entries[provider]
+
+	if !ok {
+		entry = &modelCacheEntry{}
+		c.entries[provider] = entry
+	}
+
+	return entry
+}
+
+// snapshot encodes the entries for the cache file with its generation, nil when there is no file.
+// The caller holds the mutex
+func (c *modelCacheStore) snapshot() ([]byte, uint64) {
+	if c.path == "" {
+		return nil, 0
+	}
+
+	listed := make(map[types.Provider]*modelCacheEntry, len(c.entries))
+
+	for provider, entry := range c.entries {
+		if entry.FetchedAt > 0 {
+			listed[provider] = entry
+		}
+	}
+
+	bytes, err := json.Marshal(listed)
+
+	if err != nil {
+		log.Printf("encode model cache failed err=%v", err)
+		return nil, 0
+	}
+
+	c.generation++
+
+	return bytes, c.generation
+}
+
+// save writes to a temp file and renames it so a crash never leaves a half-written cache. Saves
+// run one at a time and a snapshot older than the one on disk is skipped
+func (c *modelCacheStore) save(snapshot []byte, generation uint64) {
+	if snapshot == nil {
+		return
+	}
+
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	if generation <= c.savedGeneration {
+		return
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+
+	if err != nil {
+		log.Printf("write model cache failed file=%q err=%v", c.path, err)
+		return
+	}
+
+	tempPath := file.Name()
+	_, err = file.Write(snapshot)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tempPath, 0o644)
+	}
+
+	if err == nil {
+		err = os.Rename(tempPath, c.path)
+	}
+
+	if err != nil {
+		os.Remove(tempPath)
+		log.Printf("write model cache failed file=%q err=%v", c.path, err)
+		return
+	}
+
+	c.savedGeneration = generation
+}
+
+func (e *modelCacheEntry) status(provider types.Provider) *types.ProviderModelsStatus {
+	return &types.ProviderModelsStatus{Provider: provider, FetchedAt: e.FetchedAt, Err: e.err}
+}
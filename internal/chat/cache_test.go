@@ -0,0 +1,171 @@
+package chatservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// listingClient lists one model named after the number of times it was asked, waiting for release when set
+type listingClient struct {
+	llms.LLMClient
+
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (c *listingClient) Models(ctx context.Context) ([]*types.Model, error) {
+	call := c.calls.Add(1)
+
+	if c.release != nil {
+		<-c.release
+	}
+
+	return []*types.Model{{ID: fmt.Sprintf("model-%d", call), Provider: "Test"}}, nil
+}
+
+func newTestCache(path string) *modelCacheStore {
+	return &modelCacheStore{path: path, entries: map[types.Provider]*modelCacheEntry{}}
+}
+
+func modelIDs(models []*types.Model) []string {
+	ids := make([]string, 0, len(models))
+
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+
+	return ids
+}
+
+func TestModelCacheServesFreshLists(t *testing.T) {
+	cache := newTestCache("")
+	client := &listingClient{}
+
+	first := cache.get(context.Background(), "Test", client)
+	second := cache.get(context.Background(), "Test", client)
+
+	if calls := client.calls.Load(); calls != 1 {
+		t.Errorf("listed %d times, want once within the TTL", calls)
+	}
+
+	if len(first.models) != 1 || len(second.models) != 1 || second.stale || second.status != nil {
+		t.Errorf("first = %+v, second = %+v, want the same fresh list", first, second)
+	}
+}
+
+func TestModelCacheServesStaleWhileRefreshing(t *testing.T) {
+	cache := newTestCache("")
+	client := &listingClient{release: make(chan struct{})}
+
+	cache.entries["Test"] = &modelCacheEntry{
+		Models:    []*types.Model{{ID: "old", Provider: "Test"}},
+		FetchedAt: time.Now().Add(-time.Hour).UnixMilli(),
+	}
+
+	// The expired list comes back at once while the refresh waits on the provider
+	stale := cache.get(context.Background(), "Test", client)
+
+	if ids := modelIDs(stale.models); !stale.stale || len(ids) != 1 || ids[0] != "old" {
+		t.Fatalf("got %+v, want the old list marked stale", stale)
+	}
+
+	cache.mutex.Lock()
+	done := cache.entries["Test"].fetching
+	cache.mutex.Unlock()
+
+	if done == nil {
+		t.Fatal("no refresh running")
+	}
+
+	// A second caller shares the running refresh
+	cache.get(context.Background(), "Test", client)
+
+	close(client.release)
+	<-done
+
+	fresh := cache.get(context.Background(), "Test", client)
+
+	if ids := modelIDs(fresh.models); fresh.stale || len(ids) != 1 || ids[0] != "model-1" {
+		t.Errorf("got %+v, want the refreshed list", fresh)
+	}
+
+	if calls := client.calls.Load(); calls != 1 {
+		t.Errorf("listed %d times, want a single refresh", calls)
+	}
+}
+
+func TestModelCachePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	t.Setenv("MODEL_CACHE_FILE", path)
+
+	previous := modelCache
+	modelCache = newTestCache("")
+
+	t.Cleanup(func() {
+		modelCache = previous
+	})
+
+	InitializeModelCache()
+	modelCache.get(context.Background(), "Test", &listingClient{})
+
+	// A restart serves the saved list without asking the provider
+	modelCache = newTestCache("")
+	InitializeModelCache()
+
+	client := &listingClient{}
+	restored := modelCache.get(context.Background(), "Test", client)
+
+	if ids := modelIDs(restored.models); len(ids) != 1 || ids[0] != "model-1" || client.calls.Load() != 0 {
+		t.Errorf("after a restart got %v with %d listings, want the saved list", ids, client.calls.Load())
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("data dir holds %d files, want only the cache file", len(entries))
+	}
+}
+
+func TestModelCacheSkipsOlderSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	cache := newTestCache(path)
+
+	cache.entries["Test"] = &modelCacheEntry{Models: []*types.Model{{ID: "old"}}, FetchedAt: 1}
+	older, olderGeneration := cache.snapshot()
+
+	cache.entries["Test"] = &modelCacheEntry{Models: []*types.Model{{ID: "new"}}, FetchedAt: 2}
+	newer, newerGeneration := cache.snapshot()
+
+	// The newer refresh reaches the disk first, the slower older one must not overwrite it
+	cache.save(newer, newerGeneration)
+	cache.save(older, olderGeneration)
+
+	bytes, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved map[types.Provider]*modelCacheEntry
+
+	if err := json.Unmarshal(bytes, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := modelIDs(saved["Test"].Models); len(ids) != 1 || ids[0] != "new" {
+		t.Errorf("cache file lists %v, want the newer snapshot", ids)
+	}
+}
@@ -0,0 +1,91 @@
+package chatservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	MaxCompareTargets = 10
+	MaxCompareWorkers = 4
+)
+
+var ErrInvalidCompareRequest = errors.New("invalid compare request")
+
+// CompareModels sends one context to every target concurrently, onResult is called as each
+// target finishes and the returned slice keeps the original target order
+func CompareModels(ctx context.Context, request *types.CompareRequest, onResult func(result *types.CompareResult)) ([]*types.CompareResult, error) {
+	if len(request.Targets) == 0 || len(request.Targets) > MaxCompareTargets {
+		return nil, fmt.Errorf("%w: between 1 and %d targets are required", ErrInvalidCompareRequest, MaxCompareTargets)
+	}
+
+	results := make([]*types.CompareResult, len(request.Targets))
+	jobs := make(chan int)
+	resultsMutex := sync.Mutex{}
+	syncGroup := sync.WaitGroup{}
+
+	for range min(MaxCompareWorkers, len(request.Targets)) {
+		syncGroup.Add(1)
+
+		go func() {
+			defer syncGroup.Done()
+
+			for index := range jobs {
+				result := compareTarget(ctx, request, index)
+
+				// Serialize callbacks so a streaming writer never sees concurrent writes
+				resultsMutex.Lock()
+				results[index] = result
+
+				if onResult != nil {
+					onResult(result)
+				}
+
+				resultsMutex.Unlock()
+			}
+		}()
+	}
+
+	for index := range request.Targets {
+		jobs <- index
+	}
+
+	close(jobs)
+	syncGroup.Wait()
+
+	return results, nil
+}
+
+func compareTarget(ctx context.Context, request *types.CompareRequest, index int) *types.CompareResult {
+	target := request.Targets[index]
+
+	result := &types.CompareResult{
+		Index:    index,
+		Provider: target.Provider,
+		ModelID:  target.ModelID,
+	}
+
+	// A failing target is reported in place without affecting the others, and never falls back
+	// to another model since that would misattribute the answer
+	chatResult, err := generateChatResponse(ctx, &types.ChatRequest{
+		ModelID:      target.ModelID,
+		Provider:     target.Provider,
+		Context:      request.Context,
+		Tokens:       request.Tokens,
+		SystemPrompt: request.SystemPrompt,
+	})
+
+	if err != nil {
+		result.Err = err
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Result = chatResult
+
+	return result
+}
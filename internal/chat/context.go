@@ -0,0 +1,302 @@
+package chatservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// contextMessage reads a message in either the canonical or the OpenAI shape, the two only differ
+// in how tool turns are written so the frontend, the /v1 facade and stored contexts can all be parsed alike
+type contextMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls"`
+	ToolCallID string           `json:"tool_call_id"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+	ExtraContent struct {
+		Google struct {
+			ThoughtSignature string `json:"thought_signature"`
+		} `json:"google"`
+	} `json:"extra_content"`
+}
+
+// parseContext turns a raw context into canonical messages whose content is always a list of parts.
+// Developer messages become system messages, OpenAI tool_calls become tool_call parts and the tool
+// messages answering one assistant turn become the tool_result parts of a single user message
+func parseContext(rawContext json.RawMessage) ([]types.Message, error) {
+	var messages []contextMessage
+
+	if err := json.Unmarshal(rawContext, &messages); err != nil {
+		return nil, fmt.Errorf("invalid context: %w", err)
+	}
+
+	parsed := make([]types.Message, 0, len(messages))
+	previousTool := false
+
+	for i, message := range messages {
+		parts, err := parseMessageParts(message)
+
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+
+		role := message.Role
+
+		switch role {
+		case "developer":
+			role = "system"
+		case "tool":
+			role = "user"
+		}
+
+		if message.Role == "tool" && previousTool {
+			last := &parsed[len(parsed)-1]
+
+			previousParts, err := last.Parts()
+
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(previousParts, parts...)
+			parsed = parsed[:len(parsed)-1]
+		}
+
+		previousTool = message.Role == "tool"
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		content, err := json.Marshal(parts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, types.Message{Role: role, Content: content})
+	}
+
+	return parsed, nil
+}
+
+func parseMessageParts(message contextMessage) ([]types.MessagePart, error) {
+	switch message.Role {
+	case "system", "developer", "user", "assistant", "tool":
+	default:
+		return nil, fmt.Errorf("unsupported role %q", message.Role)
+	}
+
+	parts := make([]types.MessagePart, 0)
+
+	if len(message.Content) > 0 && string(message.Content) != "null" {
+		contentParts, err := types.Message{Content: message.Content}.Parts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		parts = contentParts
+	}
+
+	if message.Role == "tool" {
+		if message.ToolCallID == "" {
+			return nil, errors.New("tool message missing tool_call_id")
+		}
+
+		return []types.MessagePart{{Type: "tool_result", ToolCallID: message.ToolCallID, Text: partsText(parts)}}, nil
+	}
+
+	if len(message.ToolCalls) > 0 && message.Role != "assistant" {
+		return nil, errors.New("only assistant messages can carry tool_calls")
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		arguments := json.RawMessage(toolCall.Function.Arguments)
+
+		// Empty or truncated arguments are sent back as an empty object rather than failing the turn
+		if !json.Valid(arguments) {
+			arguments = json.RawMessage("{}")
+		}
+
+		parts = append(parts, types.MessagePart{
+			Type: "tool_call",
+			ToolCall: &types.ToolCall{
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: arguments,
+				Signature: toolCall.ExtraContent.Google.ThoughtSignature,
+			},
+		})
+	}
+
+	kept := make([]types.MessagePart, 0, len(parts))
+
+	for _, part := range parts {
+		// Providers reject empty text blocks, assistant turns with only tool calls often carry one
+		if part.Type == "text" && part.Text == "" {
+			continue
+		}
+
+		if err := validatePart(message.Role, part); err != nil {
+			return nil, err
+		}
+
+		kept = append(kept, part)
+	}
+
+	return kept, nil
+}
+
+// validatePart applies the rules every provider shares, so a context fails the same way whichever model it is sent to
+func validatePart(role string, part types.MessagePart) error {
+	if (role == "system" || role == "developer") && part.Type != "text" {
+		return fmt.Errorf("system messages only take text parts, got %q", part.Type)
+	}
+
+	if part.IsError && part.Type != "tool_result" {
+		return errors.New("is_error belongs to tool_result parts")
+	}
+
+	switch part.Type {
+	case "text":
+	case "thinking":
+		if role != "assistant" {
+			return errors.New("thinking parts belong to assistant messages")
+		}
+	case "image_url":
+		if part.ImageURL == nil || part.ImageURL.URL == "" {
+			return errors.New("image_url part missing url")
+		}
+	case "file":
+		if role != "user" {
+			return errors.New("file parts belong to user messages")
+		}
+
+		if part.File == nil || (part.File.FileData == "" && part.File.FileID == "") {
+			return errors.New("file part needs file_data or file_id")
+		}
+
+		if part.File.FileData == "" {
+			return nil
+		}
+
+		mediaType, _, err := utils.ParseDataURL(part.File.FileData)
+
+		if err != nil {
+			return fmt.Errorf("file %s: %w", utils.FileName(part.File), err)
+		}
+
+		if !utils.IsSupportedFileType(mediaType) {
+			return fmt.Errorf("file %s: unsupported type %q, attach a PDF or a text document", utils.FileName(part.File), mediaType)
+		}
+	case "tool_call":
+		if role != "assistant" {
+			return errors.New("tool_call parts belong to assistant messages")
+		}
+
+		if part.ToolCall == nil || part.ToolCall.ID == "" || part.ToolCall.Name == "" {
+			return errors.New("tool_call part needs an id and a name")
+		}
+
+		if len(part.ToolCall.Arguments) > 0 && !json.Valid(part.ToolCall.Arguments) {
+			return fmt.Errorf("tool_call %q has invalid arguments", part.ToolCall.ID)
+		}
+	case "tool_result":
+		if role != "user" {
+			return errors.New("tool_result parts belong to user messages")
+		}
+
+		if part.ToolCallID == "" {
+			return errors.New("tool_result part missing tool_call_id")
+		}
+	default:
+		return fmt.Errorf("unsupported part type %q", part.Type)
+	}
+
+	return nil
+}
+
+// encodeContext converts canonical messages with the client that will send them, replacing file parts
+// with their text when the model can't read files
+func encodeContext(client llms.LLMClient, modelID string, messages []types.Message) (any, error) {
+	if reader, ok := client.(llms.FileReader); !ok || !reader.ReadsFiles(modelID) {
+		inlined, err := inlineFileText(messages)
+
+		if err != nil {
+			return nil, err
+		}
+
+		messages = inlined
+	}
+
+	return client.Encode(messages)
+}
+
+// inlineFileText replaces file parts with their extracted text for models that can't read files
+func inlineFileText(messages []types.Message) ([]types.Message, error) {
+	inlined := make([]types.Message, 0, len(messages))
+
+	for _, message := range messages {
+		parts, err := message.Parts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.ContainsFunc(parts, func(part types.MessagePart) bool { return part.Type == "file" }) {
+			inlined = append(inlined, message)
+			continue
+		}
+
+		for i, part := range parts {
+			if part.Type != "file" {
+				continue
+			}
+
+			text, err := utils.AttachedFileText(part.File)
+
+			if err != nil {
+				return nil, err
+			}
+
+			parts[i] = types.MessagePart{Type: "text", Text: text}
+		}
+
+		content, err := json.Marshal(parts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		inlined = append(inlined, types.Message{Role: message.Role, Content: content})
+	}
+
+	return inlined, nil
+}
+
+func partsText(parts []types.MessagePart) string {
+	texts := make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,251 @@
+package chatservice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func assertMessages(t *testing.T, got []types.Message, want []types.Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		gotParts, err := got[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantParts, err := want[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got[i].Role != want[i].Role || !reflect.DeepEqual(gotParts, wantParts) {
+			gotJSON, _ := json.Marshal(gotParts)
+			wantJSON, _ := json.Marshal(wantParts)
+			t.Errorf("message %d:\n got %s %s\nwant %s %s", i, got[i].Role, gotJSON, want[i].Role, wantJSON)
+		}
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	cases := []struct {
+		name    string
+		context string
+		want    []types.Message
+	}{
+		{
+			name:    "string content becomes a text part",
+			context: `[{"role":"user","content":"hi"}]`,
+			want:    []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"hi"}]`)}},
+		},
+		{
+			name:    "developer messages become system messages",
+			context: `[{"role":"developer","content":"be brief"}]`,
+			want:    []types.Message{{Role: "system", Content: json.RawMessage(`[{"type":"text","text":"be brief"}]`)}},
+		},
+		{
+			name: "OpenAI tool turns become canonical parts with consecutive results merged",
+			context: `[
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"a","type":"function","function":{"name":"calc","arguments":"{\"x\":1}"}},
+					{"id":"b","type":"function","function":{"name":"calc","arguments":"{\"x\":2}"}}]},
+				{"role":"tool","tool_call_id":"a","content":"1"},
+				{"role":"tool","tool_call_id":"b","content":[{"type":"text","text":"2"}]},
+				{"role":"user","content":"thanks"}]`,
+			want: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{"x":1}}},{"type":"tool_call","tool_call":{"id":"b","name":"calc","arguments":{"x":2}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"a","text":"1"},{"type":"tool_result","tool_call_id":"b","text":"2"}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"thanks"}]`)},
+			},
+		},
+		{
+			name:    "Gemini thought signatures on OpenAI tool calls are kept",
+			context: `[{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"calc","arguments":"{}"},"extra_content":{"google":{"thought_signature":"sig"}}}]}]`,
+			want:    []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{},"signature":"sig"}}]`)}},
+		},
+		{
+			name:    "truncated tool arguments become an empty object",
+			context: `[{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"calc","arguments":"{\"x\":"}}]}]`,
+			want:    []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{}}}]`)}},
+		},
+		{
+			name:    "empty text parts and empty messages are dropped",
+			context: `[{"role":"assistant","content":""},{"role":"user","content":[{"type":"text","text":""},{"type":"text","text":"hi"}]}]`,
+			want:    []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"hi"}]`)}},
+		},
+		{
+			name:    "canonical contexts parse unchanged",
+			context: `[{"role":"assistant","content":[{"type":"thinking","text":"hmm","signature":"sig"},{"type":"text","text":"4"}]}]`,
+			want:    []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","text":"hmm","signature":"sig"},{"type":"text","text":"4"}]`)}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages, err := parseContext(json.RawMessage(tc.context))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, messages, tc.want)
+		})
+	}
+}
+
+func TestParseContextRejects(t *testing.T) {
+	cases := map[string]string{
+		"unknown role":             `[{"role":"robot","content":"hi"}]`,
+		"tool message without id":  `[{"role":"tool","content":"1"}]`,
+		"tool calls on a user":     `[{"role":"user","tool_calls":[{"id":"a","function":{"name":"calc","arguments":"{}"}}]}]`,
+		"context that isn't array": `{"role":"user"}`,
+	}
+
+	for name, context := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseContext(json.RawMessage(context)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidatePart(t *testing.T) {
+	pdf := "data:application/pdf;base64,JVBERi0xLjQ="
+
+	cases := []struct {
+		name  string
+		role  string
+		part  types.MessagePart
+		valid bool
+	}{
+		{"text", "user", types.MessagePart{Type: "text", Text: "hi"}, true},
+		{"system text", "system", types.MessagePart{Type: "text", Text: "be brief"}, true},
+		{"system image", "system", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://example.com/a.png"}}, false},
+		{"developer file", "developer", types.MessagePart{Type: "file", File: &types.File{FileData: pdf}}, false},
+		{"assistant thinking", "assistant", types.MessagePart{Type: "thinking", Text: "hmm"}, true},
+		{"user thinking", "user", types.MessagePart{Type: "thinking", Text: "hmm"}, false},
+		{"image", "user", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "data:image/png;base64,AAAA"}}, true},
+		{"image without url", "user", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{}}, false},
+		{"image without field", "user", types.MessagePart{Type: "image_url"}, false},
+		{"pdf file", "user", types.MessagePart{Type: "file", File: &types.File{FileData: pdf}}, true},
+		{"text file", "user", types.MessagePart{Type: "file", File: &types.File{FileData: "data:text/markdown;charset=utf-8;base64,IyBoaQ=="}}, true},
+		{"uploaded file", "user", types.MessagePart{Type: "file", File: &types.File{FileID: "file-1"}}, true},
+		{"assistant file", "assistant", types.MessagePart{Type: "file", File: &types.File{FileData: pdf}}, false},
+		{"empty file", "user", types.MessagePart{Type: "file", File: &types.File{Filename: "a.pdf"}}, false},
+		{"file that isn't a data URL", "user", types.MessagePart{Type: "file", File: &types.File{FileData: "https://example.com/a.pdf"}}, false},
+		{"file with bad base64", "user", types.MessagePart{Type: "file", File: &types.File{FileData: "data:application/pdf;base64,%%%"}}, false},
+		{"image sent as a file", "user", types.MessagePart{Type: "file", File: &types.File{FileData: "data:image/png;base64,AAAA"}}, false},
+		{"tool call", "assistant", types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: "a", Name: "calc", Arguments: json.RawMessage(`{}`)}}, true},
+		{"user tool call", "user", types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: "a", Name: "calc"}}, false},
+		{"tool call without name", "assistant", types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: "a"}}, false},
+		{"tool call with invalid arguments", "assistant", types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: "a", Name: "calc", Arguments: json.RawMessage(`{"x":`)}}, false},
+		{"tool result", "user", types.MessagePart{Type: "tool_result", ToolCallID: "a", Text: "1"}, true},
+		{"assistant tool result", "assistant", types.MessagePart{Type: "tool_result", ToolCallID: "a"}, false},
+		{"tool result without id", "user", types.MessagePart{Type: "tool_result", Text: "1"}, false},
+		{"failed tool result", "user", types.MessagePart{Type: "tool_result", ToolCallID: "a", Text: "boom", IsError: true}, true},
+		{"error flag on text", "user", types.MessagePart{Type: "text", Text: "boom", IsError: true}, false},
+		{"unknown type", "user", types.MessagePart{Type: "input_audio"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validatePart(tc.role, tc.part)
+
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// stubClient encodes to the canonical messages themselves so cases can see what reached the client
+type stubClient struct {
+	llms.LLMClient
+}
+
+func (stubClient) Encode(messages []types.Message) (any, error) {
+	return messages, nil
+}
+
+type fileReadingClient struct {
+	stubClient
+	fileModels []string
+}
+
+func (c fileReadingClient) ReadsFiles(modelID string) bool {
+	return slices.Contains(c.fileModels, modelID)
+}
+
+func TestEncodeContextFiles(t *testing.T) {
+	pdf := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF-1.4\n1 0 obj\n<< >>\nstream\nBT (Quarterly report) Tj ET\nendstream\nendobj\n"))
+	text := "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("plain notes"))
+	reader := fileReadingClient{fileModels: []string{"reads-files"}}
+
+	pdfMessage := types.Message{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"summarize"},{"type":"file","file":{"filename":"q3.pdf","file_data":"` + pdf + `"}}]`)}
+	textMessage := types.Message{Role: "user", Content: json.RawMessage(`[{"type":"file","file":{"filename":"notes.txt","file_data":"` + text + `"}}]`)}
+	inlinedPDF := types.Message{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"summarize"},{"type":"text","text":"Attached file q3.pdf:\n\nQuarterly report"}]`)}
+	inlinedText := types.Message{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"Attached file notes.txt:\n\nplain notes"}]`)}
+	plain := types.Message{Role: "assistant", Content: json.RawMessage(`[{"type":"text","text":"ok"}]`)}
+
+	cases := []struct {
+		name     string
+		client   llms.LLMClient
+		modelID  string
+		messages []types.Message
+		want     []types.Message
+	}{
+		{"pdf inlined for a client without file support", stubClient{}, "text-only", []types.Message{pdfMessage, plain}, []types.Message{inlinedPDF, plain}},
+		{"text file inlined for a client without file support", stubClient{}, "text-only", []types.Message{textMessage}, []types.Message{inlinedText}},
+		{"pdf inlined for a text-only model", reader, "text-only", []types.Message{pdfMessage}, []types.Message{inlinedPDF}},
+		{"text file inlined for a text-only model", reader, "text-only", []types.Message{textMessage}, []types.Message{inlinedText}},
+		{"pdf passed through to a model that reads files", reader, "reads-files", []types.Message{pdfMessage, plain}, []types.Message{pdfMessage, plain}},
+		{"text file passed through to a model that reads files", reader, "reads-files", []types.Message{textMessage}, []types.Message{textMessage}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := encodeContext(tc.client, tc.modelID, tc.messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, encoded.([]types.Message), tc.want)
+		})
+	}
+}
+
+func TestInlineFileTextRejects(t *testing.T) {
+	blank := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF-1.4\n1 0 obj\n<< >>\nstream\n0 0 m 10 10 l S\nendstream\nendobj\n"))
+
+	cases := map[string]string{
+		"pdf without text":         `[{"type":"file","file":{"filename":"scan.pdf","file_data":"` + blank + `"}}]`,
+		"upload reference":         `[{"type":"file","file":{"file_id":"file-1"}}]`,
+		"text file that is UTF-16": `[{"type":"file","file":{"filename":"a.txt","file_data":"data:text/plain;base64,//5hAA=="}}]`,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := inlineFileText([]types.Message{{Role: "user", Content: json.RawMessage(content)}}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package chatservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/ratelimit"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// fallbackChains maps a lowercased provider/model name to the models tried after it
+var fallbackChains atomic.Pointer[map[string][]string]
+
+// LoadFallbackChains parses FALLBACK_CHAINS and swaps the chains in
+func LoadFallbackChains() error {
+	chains, err := ParseFallbackChains()
+
+	if err != nil {
+		return err
+	}
+
+	SetFallbackChains(chains)
+
+	return nil
+}
+
+// ParseFallbackChains reads FALLBACK_CHAINS, chains separated by ";" with models joined by "->",
+// e.g. "Anthropic/claude-sonnet-4-0 -> OpenAI/gpt-4o -> OpenRouter/openai/gpt-4o"
+func ParseFallbackChains() (map[string][]string, error) {
+	chains := make(map[string][]string)
+
+	for _, chain := range strings.Split(os.Getenv("FALLBACK_CHAINS"), ";") {
+		if strings.TrimSpace(chain) == "" {
+			continue
+		}
+
+		names := make([]string, 0)
+
+		for _, name := range strings.Split(chain, "->") {
+			name = strings.TrimSpace(name)
+
+			if provider, modelID, ok := strings.Cut(name, "/"); !ok || provider == "" || modelID == "" {
+				return nil, fmt.Errorf("invalid FALLBACK_CHAINS model %q, expected provider/model", name)
+			}
+
+			names = append(names, name)
+		}
+
+		if len(names) < 2 {
+			return nil, fmt.Errorf("fallback chain %q needs at least two models", strings.TrimSpace(chain))
+		}
+
+		chains[strings.ToLower(names[0])] = names[1:]
+	}
+
+	return chains, nil
+}
+
+func SetFallbackChains(chains map[string][]string) {
+	fallbackChains.Store(&chains)
+}
+
+// withFallbacks runs call for the requested model and then for each fallback while the failure is one
+// another model could get past. allow can stop the walk, a stream that already sent output must not switch
+func withFallbacks(ctx context.Context, request *types.ChatRequest, allow func() bool, call func(candidate *types.ChatRequest) (*types.ChatResult, error)) (*types.ChatResult, error) {
+	result, err := call(request)
+	fallbacks := fallbackNames(request)
+
+	if err == nil || len(fallbacks) == 0 {
+		return result, err
+	}
+
+	failed := []string{string(request.Provider) + "/" + request.ModelID}
+
+	for _, name := range fallbacks {
+		if !shouldFallback(err) || (allow != nil && !allow()) || ctx.Err() != nil {
+			break
+		}
+
+		candidate, translateErr := translateChatRequest(request, name)
+
+		if translateErr != nil {
+			log.Printf("skipping fallback model=%q err=%v", name, translateErr)
+			continue
+		}
+
+		log.Printf("falling back user=%q from=%q to=%q err=%v", auth.User(ctx), failed[len(failed)-1], name, err)
+
+		result, err = call(candidate)
+
+		if err == nil {
+			result.FallbackFrom = failed
+			return result, nil
+		}
+
+		failed = append(failed, name)
+	}
+
+	return nil, err
+}
+
+// fallbackNames prefers the chain sent with the request over the configured one, dropping repeats
+// and the requested model itself so no model is called twice
+func fallbackNames(request *types.ChatRequest) []string {
+	primary := strings.ToLower(string(request.Provider) + "/" + request.ModelID)
+	names := request.Fallbacks
+
+	if len(names) == 0 {
+		if chains := fallbackChains.Load(); chains != nil {
+			names = (*chains)[primary]
+		}
+	}
+
+	seen := map[string]bool{primary: true}
+	unique := make([]string, 0, len(names))
+
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		unique = append(unique, name)
+	}
+
+	return unique
+}
+
+// shouldFallback reports whether the failure is about the model's availability rather than the request
+func shouldFallback(err error) bool {
+	var limitErr *ratelimit.LimitError
+
+	return llmerrors.IsRetryable(err) || errors.As(err, &limitErr)
+}
+
+// translateChatRequest copies the request for another model, the canonical context is encoded for
+// the new provider when the copy is prepared
+func translateChatRequest(request *types.ChatRequest, name string) (*types.ChatRequest, error) {
+	provider, modelID, err := ResolveModelName(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := *request
+	candidate.Provider = provider
+	candidate.ModelID = modelID
+	candidate.Fallbacks = nil
+
+	return &candidate, nil
+}
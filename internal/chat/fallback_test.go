@@ -0,0 +1,61 @@
+package chatservice
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func TestFallbackNames(t *testing.T) {
+	SetFallbackChains(map[string][]string{
+		"anthropic/claude-sonnet-4-0": {"OpenAI/gpt-4o", "openai/GPT-4o", "Anthropic/claude-sonnet-4-0", "Groq/llama-3.3-70b-versatile"},
+	})
+
+	t.Cleanup(func() {
+		SetFallbackChains(nil)
+	})
+
+	cases := []struct {
+		name      string
+		modelID   string
+		fallbacks []string
+		want      []string
+	}{
+		{"configured chain without repeats or the primary", "claude-sonnet-4-0", nil, []string{"OpenAI/gpt-4o", "Groq/llama-3.3-70b-versatile"}},
+		{"request chain wins", "claude-sonnet-4-0", []string{"Google/gemini-2.5-flash"}, []string{"Google/gemini-2.5-flash"}},
+		{"request chain without repeats or the primary", "claude-opus-4-1", []string{"anthropic/claude-opus-4-1", "OpenAI/gpt-4o", " OpenAI/gpt-4o "}, []string{"OpenAI/gpt-4o"}},
+		{"no chain", "claude-opus-4-1", nil, []string{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &types.ChatRequest{Provider: "Anthropic", ModelID: tc.modelID, Fallbacks: tc.fallbacks}
+
+			if got := fallbackNames(request); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateChatRequestCapsFallbacks(t *testing.T) {
+	request := &types.ChatRequest{Provider: "OpenAI", ModelID: "gpt-4o", Context: json.RawMessage(`"hi"`)}
+
+	for range utils.MaxFallbacks {
+		request.Fallbacks = append(request.Fallbacks, "Anthropic/claude-sonnet-4-0")
+	}
+
+	if err := validateChatRequest(request); err != nil {
+		t.Fatalf("%d fallbacks: %v", len(request.Fallbacks), err)
+	}
+
+	request.Fallbacks = append(request.Fallbacks, "Anthropic/claude-sonnet-4-0")
+
+	if err := validateChatRequest(request); !errors.Is(err, ErrInvalidChatRequest) {
+		t.Errorf("%d fallbacks: got %v, want %v", len(request.Fallbacks), err, ErrInvalidChatRequest)
+	}
+}
@@ -1,40 +1,203 @@
 package chatservice
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"time"
 
-	anthropicSvc "github.com/CodingWithKarim/AgentK/internal/llms/anthropic"
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/ratelimit"
+	"github.com/CodingWithKarim/AgentK/internal/usage"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
-	"github.com/openai/openai-go"
 )
 
-func getFormattedContext(provider types.Provider, rawContext json.RawMessage) (any, error) {
-	if provider != utils.ANTHROPIC {
-		var messages []openai.ChatCompletionMessageParamUnion
-		if err := json.Unmarshal(rawContext, &messages); err != nil {
-			return nil, err
-		}
-		return messages, nil
-	}
-
-	var msgs []types.Message
+// ErrInvalidChatRequest marks failures caused by the request itself rather than the provider
+var ErrInvalidChatRequest = errors.New("invalid chat request")
 
-	if err := json.Unmarshal(rawContext, &msgs); err != nil {
-		return nil, fmt.Errorf("invalid canonical context: %w", err)
+// applyDefaults fills the model and token limit from the configured defaults when the request leaves them out
+func applyDefaults(request *types.ChatRequest, settings *utils.Settings) {
+	if request.Provider == "" && request.ModelID == "" {
+		request.Provider = settings.DefaultProvider
+		request.ModelID = settings.DefaultModelID
 	}
 
-	// Normalize to anthripic format for potential images
-	return anthropicSvc.BuildAnthropicMessages(msgs)
+	if request.Tokens == 0 {
+		request.Tokens = settings.DefaultMaxTokens
+	}
 }
 
 func validateChatRequest(request *types.ChatRequest) error {
 	if request.ModelID == "" || request.Provider == "" || request.Context == nil {
 		log.Println("Missing request fields")
-		return errors.New("sessionID, modelID, and message are required")
+		return fmt.Errorf("%w: sessionID, modelID, and message are required", ErrInvalidChatRequest)
+	}
+
+	if len(request.Fallbacks) > utils.MaxFallbacks {
+		return fmt.Errorf("%w: fallbacks takes at most %d models", ErrInvalidChatRequest, utils.MaxFallbacks)
 	}
+
+	if search := request.Search; search != nil {
+		if search.RecencyFilter != "" && !slices.Contains(utils.SearchRecencyFilters, search.RecencyFilter) {
+			return fmt.Errorf("%w: search recencyFilter must be one of %s", ErrInvalidChatRequest, strings.Join(utils.SearchRecencyFilters, ", "))
+		}
+
+		if search.ContextSize != "" && !slices.Contains(utils.SearchContextSizes, search.ContextSize) {
+			return fmt.Errorf("%w: search contextSize must be one of %s", ErrInvalidChatRequest, strings.Join(utils.SearchContextSizes, ", "))
+		}
+
+		if len(search.DomainFilter) > utils.MaxSearchDomains {
+			return fmt.Errorf("%w: search domainFilter takes at most %d domains", ErrInvalidChatRequest, utils.MaxSearchDomains)
+		}
+	}
+
 	return nil
 }
+
+func prepareChatRequest(request *types.ChatRequest) (llms.LLMClient, any, error) {
+	// A reload may land mid-request, every setting below comes from this one snapshot
+	settings := utils.CurrentSettings()
+
+	applyDefaults(request, settings)
+
+	if err := validateChatRequest(request); err != nil {
+		return nil, nil, err
+	}
+
+	messages, err := parseContext(request.Context)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidChatRequest, err)
+	}
+
+	LLMClient, ok := llms.GetClient(request.Provider)
+
+	if !ok {
+		return nil, nil, utils.ErrProviderNotSupported
+	}
+
+	contextMessages, err := encodeContext(LLMClient, request.ModelID, messages)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidChatRequest, err)
+	}
+
+	return LLMClient, contextMessages, nil
+}
+
+// reserveBudget holds the most a call can cost against the spend budgets, counting the whole context as
+// input at about four bytes a token and the full output allowance as output. A model without a known
+// price is refused while a budget covers it, since its calls would count as free
+func reserveBudget(request *types.ChatRequest) (*usage.Reservation, error) {
+	estimate, priced := utils.EstimateCost(request.Provider, request.ModelID, types.Usage{
+		InputTokens:  int64(len(request.Context) / 4),
+		OutputTokens: request.Tokens,
+	})
+
+	if !priced && usage.Covers(request.Provider, request.ModelID) {
+		return nil, fmt.Errorf("%w: %s %s is under a spend budget but its calls can't be costed", usage.ErrUnpricedModel, request.Provider, request.ModelID)
+	}
+
+	return usage.Reserve(request.Provider, request.ModelID, estimate)
+}
+
+// finalizeChatResult fills in the fields every provider shares once a call completes
+func finalizeChatResult(request *types.ChatRequest, result *types.ChatResult, startTime time.Time) {
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+	result.Provider = request.Provider
+
+	if result.Model == "" {
+		result.Model = request.ModelID
+	}
+
+	// A served model ID the pricing table doesn't know is billed at the requested model's price
+	cost, ok := utils.EstimateCost(request.Provider, result.Model, result.Usage)
+
+	if !ok {
+		cost, ok = utils.EstimateCost(request.Provider, request.ModelID, result.Usage)
+	}
+
+	if ok {
+		result.CostUSD = &cost
+	}
+}
+
+// recordUsage writes the call to the usage ledger under the provider and model that served it, a ledger
+// failure never fails the chat itself
+func recordUsage(ctx context.Context, result *types.ChatResult) {
+	if usage.Records == nil {
+		return
+	}
+
+	record := &types.UsageRecord{
+		Timestamp:    time.Now().UnixMilli(),
+		User:         auth.User(ctx),
+		Provider:     result.Provider,
+		Model:        result.Model,
+		InputTokens:  result.Usage.InputTokens,
+		OutputTokens: result.Usage.OutputTokens,
+		CachedTokens: result.Usage.CachedTokens,
+		LatencyMs:    result.LatencyMs,
+	}
+
+	if result.CostUSD != nil {
+		record.CostUSD = *result.CostUSD
+	}
+
+	if err := usage.Records.Record(record); err != nil {
+		log.Printf("failed to record usage user=%q provider=%q model=%q err=%v", record.User, record.Provider, record.Model, err)
+	}
+}
+
+// AppendToolTurn adds the assistant's tool calls and their results to a raw context as canonical parts
+func AppendToolTurn(rawContext json.RawMessage, result *types.ChatResult, toolResults []types.ToolResult) (json.RawMessage, error) {
+	var messages []any
+
+	if err := json.Unmarshal(rawContext, &messages); err != nil {
+		return nil, fmt.Errorf("invalid context: %w", err)
+	}
+
+	assistantParts := make([]types.MessagePart, 0, len(result.ToolCalls)+1)
+
+	if result.Text != "" {
+		assistantParts = append(assistantParts, types.MessagePart{Type: "text", Text: result.Text})
+	}
+
+	for _, toolCall := range result.ToolCalls {
+		assistantParts = append(assistantParts, types.MessagePart{Type: "tool_call", ToolCall: &toolCall})
+	}
+
+	resultParts := make([]types.MessagePart, 0, len(toolResults))
+
+	for _, toolResult := range toolResults {
+		resultParts = append(resultParts, types.MessagePart{Type: "tool_result", ToolCallID: toolResult.ToolCallID, Text: toolResult.Output, IsError: toolResult.IsError})
+	}
+
+	messages = append(messages,
+		map[string]any{"role": "assistant", "content": assistantParts},
+		map[string]any{"role": "user", "content": resultParts},
+	)
+
+	return json.Marshal(messages)
+}
+
+func acquireRateLimit(ctx context.Context, provider types.Provider) (func(), error) {
+	if ratelimit.Default == nil {
+		return func() {}, nil
+	}
+
+	return ratelimit.Default.Acquire(auth.Caller(ctx), provider)
+}
+
+// enrichModels fills in capabilities and pricing the provider's model list didn't report
+func enrichModels(models []*types.Model) {
+	for _, model := range models {
+		utils.EnrichModel(model)
+	}
+}
@@ -0,0 +1,81 @@
+package chatservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/usage"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func useLedger(t *testing.T, budgets ...types.Budget) *usage.FileLedger {
+	t.Helper()
+
+	ledger, err := usage.NewFileLedger(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousRecords, previousBudgets := usage.Records, usage.Budgets
+	usage.Records, usage.Budgets = ledger, budgets
+
+	t.Cleanup(func() {
+		usage.Records, usage.Budgets = previousRecords, previousBudgets
+	})
+
+	return ledger
+}
+
+func TestReserveBudgetRefusesUnpricedModels(t *testing.T) {
+	useLedger(t, types.Budget{Provider: "OpenAI", Period: usage.PeriodDaily, LimitUSD: 10})
+
+	cases := []struct {
+		name     string
+		provider types.Provider
+		modelID  string
+		refused  bool
+	}{
+		{"priced model under a budget", "OpenAI", "gpt-4o", false},
+		{"unpriced model under a budget", "OpenAI", "gpt-4o-audio-preview", true},
+		{"unpriced model without a budget", "Groq", "openai/gpt-oss-120b", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reservation, err := reserveBudget(&types.ChatRequest{Provider: tc.provider, ModelID: tc.modelID, Context: json.RawMessage(`"hi"`), Tokens: 100})
+
+			if tc.refused != errors.Is(err, usage.ErrUnpricedModel) {
+				t.Fatalf("got %v, want refused = %v", err, tc.refused)
+			}
+
+			if reservation != nil {
+				reservation.Settle()
+			}
+		})
+	}
+}
+
+func TestRecordUsageChargesServedModel(t *testing.T) {
+	ledger := useLedger(t)
+
+	// The model reported as served is charged, at the requested model's price when its ID is unknown
+	request := &types.ChatRequest{Provider: "Anthropic", ModelID: "claude-sonnet-4-0"}
+	result := &types.ChatResult{Model: "claude-sonnet-4-0-preview", Usage: types.Usage{InputTokens: 1_000_000}}
+
+	finalizeChatResult(request, result, time.Now())
+	recordUsage(context.Background(), result)
+
+	records, err := ledger.Records(0, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Provider != "Anthropic" || records[0].Model != "claude-sonnet-4-0-preview" || records[0].CostUSD != 3 {
+		t.Errorf("records = %+v, want the served model charged $3", records)
+	}
+}
@@ -0,0 +1,28 @@
+package chatservice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// ResolveModelName splits a "provider/model" name such as "Anthropic/claude-sonnet-4" or
+// "OpenRouter/meta-llama/llama-3.3-70b-instruct", matching the provider case-insensitively
+func ResolveModelName(name string) (types.Provider, string, error) {
+	providerName, modelID, ok := strings.Cut(name, "/")
+
+	if !ok || modelID == "" {
+		return "", "", fmt.Errorf("model %q must be in provider/model form", name)
+	}
+
+	for provider := range llms.Clients() {
+		if strings.EqualFold(string(provider), providerName) {
+			return provider, modelID, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("%w: %s", utils.ErrProviderNotSupported, providerName)
+}
@@ -1,96 +1,190 @@
 package chatservice
 
 import (
+	"context"
 	"log"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 )
 
-func GenerateChatResponse(request *types.ChatRequest) (string, error) {
-	if err := validateChatRequest(request); err != nil {
-		return "", err
+// GenerateChatResponse answers with the requested model, walking its fallback chain if the model is unavailable
+func GenerateChatResponse(ctx context.Context, request *types.ChatRequest) (*types.ChatResult, error) {
+	return withFallbacks(ctx, request, nil, func(candidate *types.ChatRequest) (*types.ChatResult, error) {
+		return generateChatResponse(ctx, candidate)
+	})
+}
+
+// StreamChatResponse streams with the requested model, falling back only while nothing has been streamed.
+// onDelta is passed the request being streamed, which is a fallback's copy once the requested model failed
+func StreamChatResponse(ctx context.Context, request *types.ChatRequest, onDelta func(served *types.ChatRequest, delta string) error) (*types.ChatResult, error) {
+	emitted := false
+
+	return withFallbacks(ctx, request, func() bool { return !emitted }, func(candidate *types.ChatRequest) (*types.ChatResult, error) {
+		return streamChatResponse(ctx, candidate, func(delta string) error {
+			emitted = true
+			return onDelta(candidate, delta)
+		})
+	})
+}
+
+func generateChatResponse(ctx context.Context, request *types.ChatRequest) (*types.ChatResult, error) {
+	LLMClient, contextMessages, err := prepareChatRequest(request)
+
+	if err != nil {
+		return nil, err
 	}
 
-	contextMessages, err := getFormattedContext(request.Provider, request.Context)
+	// Settled after the actual cost is recorded, or straight away when the call fails
+	reservation, err := reserveBudget(request)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	LLMClient, ok := llms.Clients[request.Provider]
+	defer reservation.Settle()
 
-	if !ok {
-		return "", utils.ErrProviderNotSupported
+	release, err := acquireRateLimit(ctx, request.Provider)
+
+	if err != nil {
+		return nil, err
 	}
 
-	llmResponse, err := LLMClient.Chat(
-		request,
-		contextMessages,
-	)
+	defer release()
+
+	startTime := time.Now()
+
+	// Transient provider failures are retried with backoff before giving up
+	llmResponse, err := llmerrors.Retry(ctx, func() (*types.ChatResult, error) {
+		return LLMClient.Chat(
+			ctx,
+			request,
+			contextMessages,
+		)
+	})
 
 	if err != nil {
-		log.Printf("provider call failed model=%q err=%v", request.ModelID, err)
-		return "", err
+		return nil, err
 	}
 
+	finalizeChatResult(request, llmResponse, startTime)
+	recordUsage(ctx, llmResponse)
+
 	return llmResponse, nil
 }
 
-func GetAllModels() []*types.Model {
-	results := make([]*types.Model, 0)
-	channel := make(chan []*types.Model)
+func streamChatResponse(ctx context.Context, request *types.ChatRequest, onDelta func(delta string) error) (*types.ChatResult, error) {
+	LLMClient, contextMessages, err := prepareChatRequest(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Settled after the actual cost is recorded, or straight away when the call fails
+	reservation, err := reserveBudget(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reservation.Settle()
+
+	release, err := acquireRateLimit(ctx, request.Provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	startTime := time.Now()
+
+	emitted := false
+
+	// A stream is only retried while nothing has reached the caller, replaying deltas would duplicate text
+	result, err := llmerrors.RetryIf(ctx, func() bool { return !emitted }, func() (*types.ChatResult, error) {
+		return LLMClient.ChatStream(
+			ctx,
+			request,
+			contextMessages,
+			func(delta string) error {
+				emitted = true
+				return onDelta(delta)
+			},
+		)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	finalizeChatResult(request, result, startTime)
+	recordUsage(ctx, result)
+
+	return result, nil
+}
+
+// GetAllModels merges every provider's model list from the cache, reporting providers that failed
+// or were served an expired list instead of dropping them
+func GetAllModels(ctx context.Context) *types.ModelList {
+	clients := llms.Clients()
+	providers := make([]types.Provider, 0, len(clients))
+
+	for provider := range clients {
+		providers = append(providers, provider)
+	}
+
+	slices.Sort(providers)
+
+	results := make([]providerModels, len(providers))
 	syncGroup := sync.WaitGroup{}
 
-	for provider, LLMClient := range llms.Clients {
+	for index, provider := range providers {
 		syncGroup.Add(1)
 
-		go func(provider types.Provider) {
+		go func() {
 			defer syncGroup.Done()
 
-			models, err := LLMClient.Models()
-
-			if err != nil {
-				log.Printf("failed to get models for provider=%q err=%v", provider, err)
-				return
-			}
+			results[index] = modelCache.get(ctx, provider, clients[provider])
+		}()
+	}
 
-			if len(models) > 0 {
-				channel <- models
-			}
+	syncGroup.Wait()
 
-		}(provider)
-	}
+	list := &types.ModelList{Models: make([]*types.Model, 0)}
 
-	go func() {
-		syncGroup.Wait()
-		close(channel)
-	}()
+	for _, result := range results {
+		list.Models = append(list.Models, result.models...)
 
-	for models := range channel {
-		results = append(results, models...)
+		switch {
+		case result.stale:
+			list.Stale = append(list.Stale, *result.status)
+		case result.status != nil:
+			list.Failed = append(list.Failed, *result.status)
+		}
 	}
 
-	log.Printf("Fetched total models=%d", len(results))
+	log.Printf("Fetched total models=%d stale=%d failed=%d", len(list.Models), len(list.Stale), len(list.Failed))
 
-	return results
+	return list
 }
 
-func ReloadProviderModels(provider types.Provider) ([]*types.Model, error) {
-	LLMClient, ok := llms.Clients[provider]
+// ReloadProviderModels lists a provider's models live, bypassing and then refreshing the cache
+func ReloadProviderModels(ctx context.Context, provider types.Provider) ([]*types.Model, error) {
+	LLMClient, ok := llms.GetClient(provider)
 
 	if !ok {
 		return nil, utils.ErrProviderNotSupported
 	}
 
-	models, err := LLMClient.Models()
-
-	if err != nil {
-		log.Printf("failed to get models for provider=%q err=%v", provider, err)
-		return nil, err
-	}
+	modelCache.mutex.Lock()
+	entry := modelCache.entry(provider)
+	modelCache.mutex.Unlock()
 
-	return models, nil
+	return modelCache.fetch(ctx, provider, LLMClient, entry)
 }
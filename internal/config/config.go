@@ -0,0 +1,307 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	TypeAnthropic = "anthropic"
+	TypeGoogle    = "google"
+	TypeCohere    = "cohere"
+	TypeOpenAI    = "openai"
+)
+
+type Config struct {
+	Providers        []ProviderConfig `json:"providers"`
+	DisallowedModels []string         `json:"disallowedModels"`
+	Defaults         Defaults         `json:"defaults"`
+}
+
+type ProviderConfig struct {
+	Name             types.Provider    `json:"name"`
+	Type             string            `json:"type"` // anthropic, google, cohere or openai, the latter covers every OpenAI compatible API
+	BaseURL          string            `json:"baseURL"`
+	ModelEndpoint    string            `json:"modelEndpoint,omitempty"` // defaults to baseURL
+	KeyEnv           string            `json:"keyEnv,omitempty"`        // defaults to <NAME>_API_KEY
+	KeyOptional      bool              `json:"keyOptional,omitempty"`   // register without a key, for local servers such as Ollama
+	Headers          map[string]string `json:"headers,omitempty"`       // sent with every request, values expand ${ENV_VARS}
+	Models           []string          `json:"models,omitempty"`        // static list used when the model endpoint is missing or unusable
+	DisallowedModels []string          `json:"disallowedModels,omitempty"`
+}
+
+type Defaults struct {
+	Provider  types.Provider `json:"provider,omitempty"`
+	Model     string         `json:"model,omitempty"`
+	MaxTokens int64          `json:"maxTokens,omitempty"`
+	Timeout   string         `json:"timeout,omitempty"` // Go duration syntax, "0" disables
+}
+
+// nativeTypes maps the provider types with a dedicated client to the one provider that may use them
+var nativeTypes = map[string]types.Provider{
+	TypeAnthropic: utils.ANTHROPIC,
+	TypeGoogle:    utils.GOOGLE,
+	TypeCohere:    utils.COHERE,
+}
+
+// current is the configuration the server is running with
+var current atomic.Pointer[Config]
+
+// builtIn is captured before any file is applied so the compiled-in values stay reachable
+var builtIn = snapshot()
+
+// Default returns a copy of the built-in configuration
+func Default() *Config {
+	config := *builtIn
+	config.Providers = append([]ProviderConfig(nil), builtIn.Providers...)
+	config.DisallowedModels = append([]string(nil), builtIn.DisallowedModels...)
+
+	return &config
+}
+
+// InitializeConfig loads the file at path, or the built-in defaults when path is empty, and applies it
+func InitializeConfig(path string) error {
+	config, err := Load(path)
+
+	if err != nil {
+		return err
+	}
+
+	Apply(config)
+
+	return nil
+}
+
+// Load reads and validates a JSON config file, an empty path returns the built-in defaults
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("read config %s failed: %w", path, err)
+	}
+
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("parse config %s failed: %w", path, err)
+	}
+
+	// A file without a disallowedModels list keeps the built-in filter
+	if config.DisallowedModels == nil {
+		config.DisallowedModels = Default().DisallowedModels
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func (c *Config) Validate() error {
+	if len(c.Providers) == 0 {
+		return errors.New("at least one provider is required")
+	}
+
+	seen := make(map[string]bool, len(c.Providers))
+
+	for index, provider := range c.Providers {
+		if provider.Name == "" {
+			return fmt.Errorf("providers[%d]: name is required", index)
+		}
+
+		// Models are addressed as provider/model, so the name can't contain the separator
+		if strings.ContainsAny(string(provider.Name), "/ ") {
+			return fmt.Errorf("provider %q: name can't contain spaces or slashes", provider.Name)
+		}
+
+		key := strings.ToLower(string(provider.Name))
+
+		if seen[key] {
+			return fmt.Errorf("provider %s is defined more than once", provider.Name)
+		}
+
+		seen[key] = true
+
+		switch provider.Type {
+		case TypeOpenAI:
+		case TypeAnthropic, TypeGoogle, TypeCohere:
+			// Native clients are picked by provider name, Google and Cohere can still use their OpenAI endpoints as the openai type
+			if native := nativeTypes[provider.Type]; provider.Name != native {
+				return fmt.Errorf("provider %s: the %s type is only available as %s", provider.Name, provider.Type, native)
+			}
+
+			if provider.KeyOptional || len(provider.Headers) > 0 {
+				return fmt.Errorf("provider %s: keyOptional and headers are only supported for the %s type", provider.Name, TypeOpenAI)
+			}
+		default:
+			return fmt.Errorf("provider %s: type must be %q, %q, %q or %q, got %q", provider.Name, TypeAnthropic, TypeGoogle, TypeCohere, TypeOpenAI, provider.Type)
+		}
+
+		if err := validateURL(provider.BaseURL); err != nil {
+			return fmt.Errorf("provider %s: baseURL %w", provider.Name, err)
+		}
+
+		if provider.ModelEndpoint != "" {
+			if err := validateURL(provider.ModelEndpoint); err != nil {
+				return fmt.Errorf("provider %s: modelEndpoint %w", provider.Name, err)
+			}
+		}
+	}
+
+	if c.Defaults.Provider != "" && !seen[strings.ToLower(string(c.Defaults.Provider))] {
+		return fmt.Errorf("defaults: provider %s is not defined", c.Defaults.Provider)
+	}
+
+	if (c.Defaults.Provider == "") != (c.Defaults.Model == "") {
+		return errors.New("defaults: provider and model must be set together")
+	}
+
+	if c.Defaults.MaxTokens < 0 {
+		return errors.New("defaults: maxTokens can't be negative")
+	}
+
+	if c.Defaults.Timeout != "" {
+		if _, err := time.ParseDuration(c.Defaults.Timeout); err != nil {
+			return fmt.Errorf("defaults: invalid timeout %q: %w", c.Defaults.Timeout, err)
+		}
+	}
+
+	return nil
+}
+
+// Apply swaps in the settings built from config in a single step
+func Apply(config *Config) {
+	utils.StoreSettings(BuildSettings(config))
+	current.Store(config)
+}
+
+// Current returns the configuration the server is running with
+func Current() *Config {
+	return current.Load()
+}
+
+// BuildSettings derives the settings snapshot requests read from a validated config
+func BuildSettings(config *Config) *utils.Settings {
+	settings := &utils.Settings{
+		NativeProviders:           make([]types.Provider, 0),
+		OpenAICompatibleProviders: make([]types.Provider, 0, len(config.Providers)),
+		ProviderEndpoints:         make(map[types.Provider]types.ProviderEndpoints, len(config.Providers)),
+		StaticModels:              make(map[types.Provider][]string),
+		ProviderKeyEnvs:           make(map[types.Provider]string),
+		KeylessProviders:          make(map[types.Provider]bool),
+		ProviderHeaders:           make(map[types.Provider]map[string]string),
+		ProviderDisallowedModels:  make(map[types.Provider][]string),
+		DisallowedModels:          slices.Clone(config.DisallowedModels),
+		DefaultProvider:           config.Defaults.Provider,
+		DefaultModelID:            config.Defaults.Model,
+		DefaultMaxTokens:          config.Defaults.MaxTokens,
+		DefaultTimeout:            utils.DefaultProviderTimeout,
+	}
+
+	for _, provider := range config.Providers {
+		modelEndpoint := provider.ModelEndpoint
+
+		if modelEndpoint == "" {
+			modelEndpoint = provider.BaseURL
+		}
+
+		settings.ProviderEndpoints[provider.Name] = types.ProviderEndpoints{BaseURL: provider.BaseURL, ModelEndpoint: modelEndpoint}
+
+		if provider.Type == TypeOpenAI {
+			settings.OpenAICompatibleProviders = append(settings.OpenAICompatibleProviders, provider.Name)
+		} else {
+			settings.NativeProviders = append(settings.NativeProviders, provider.Name)
+		}
+
+		if len(provider.Models) > 0 {
+			settings.StaticModels[provider.Name] = slices.Clone(provider.Models)
+		}
+
+		if provider.KeyEnv != "" {
+			settings.ProviderKeyEnvs[provider.Name] = provider.KeyEnv
+		}
+
+		if provider.KeyOptional {
+			settings.KeylessProviders[provider.Name] = true
+		}
+
+		if len(provider.Headers) > 0 {
+			settings.ProviderHeaders[provider.Name] = make(map[string]string, len(provider.Headers))
+
+			for name, value := range provider.Headers {
+				settings.ProviderHeaders[provider.Name][name] = os.ExpandEnv(value)
+			}
+		}
+
+		if len(provider.DisallowedModels) > 0 {
+			settings.ProviderDisallowedModels[provider.Name] = slices.Clone(provider.DisallowedModels)
+		}
+	}
+
+	if config.Defaults.Timeout != "" {
+		// Validate already parsed it
+		settings.DefaultTimeout, _ = time.ParseDuration(config.Defaults.Timeout)
+	}
+
+	return settings
+}
+
+// snapshot turns the compiled-in provider tables into a Config
+func snapshot() *Config {
+	settings := utils.BuiltInSettings()
+
+	config := &Config{
+		Providers:        make([]ProviderConfig, 0, len(settings.NativeProviders)+len(settings.OpenAICompatibleProviders)),
+		DisallowedModels: settings.DisallowedModels,
+	}
+
+	for _, provider := range settings.ConfiguredProviders() {
+		endpoints := settings.ProviderEndpoints[provider]
+
+		providerConfig := ProviderConfig{
+			Name:          provider,
+			Type:          TypeOpenAI,
+			BaseURL:       endpoints.BaseURL,
+			ModelEndpoint: endpoints.ModelEndpoint,
+			Models:        settings.StaticModels[provider],
+		}
+
+		for nativeType, name := range nativeTypes {
+			if provider == name && settings.IsNativeProvider(provider) {
+				providerConfig.Type = nativeType
+			}
+		}
+
+		config.Providers = append(config.Providers, providerConfig)
+	}
+
+	return config
+}
+
+func validateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+
+	return nil
+}
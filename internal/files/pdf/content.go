@@ -0,0 +1,251 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// extractContentText interprets a content stream just enough to follow the strings shown inside BT and ET
+func extractContentText(content []byte) string {
+	var text strings.Builder
+
+	strs := make([]string, 0)
+	numbers := make([]float64, 0)
+	inText := false
+	inArray := false
+
+	for i := 0; i < len(content); {
+		c := content[i]
+
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+
+		case c == '(':
+			value, next := readLiteralString(content, i)
+			strs = append(strs, value)
+			i = next
+
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+
+		case c == '<':
+			value, next := readHexString(content, i)
+			strs = append(strs, value)
+			i = next
+
+		case c == '[':
+			inArray = true
+			i++
+
+		case c == ']':
+			inArray = false
+			i++
+
+		// Names such as /F1 run to the next delimiter and are never operators
+		case c == '/':
+			i++
+
+			for i < len(content) && !isWhitespace(content[i]) && !isDelimiter(content[i]) {
+				i++
+			}
+
+		case isWhitespace(c) || isDelimiter(c):
+			i++
+
+		default:
+			start := i
+
+			for i < len(content) && !isWhitespace(content[i]) && !isDelimiter(content[i]) {
+				i++
+			}
+
+			word := string(content[start:i])
+
+			if number, err := strconv.ParseFloat(word, 64); err == nil {
+				// Wide negative kerning inside a TJ array is how many generators space words
+				if inArray && number < -200 {
+					strs = append(strs, " ")
+				}
+
+				numbers = append(numbers, number)
+				continue
+			}
+
+			switch word {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				text.WriteString("\n")
+			case "Tj", "TJ":
+				if inText {
+					text.WriteString(strings.Join(strs, ""))
+				}
+			case "'", "\"":
+				if inText {
+					text.WriteString("\n" + strings.Join(strs, ""))
+				}
+			case "T*", "Tm":
+				if inText {
+					text.WriteString("\n")
+				}
+			case "Td", "TD":
+				// A vertical move starts a new line, a horizontal one separates words
+				if inText && len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					text.WriteString("\n")
+				} else if inText {
+					text.WriteString(" ")
+				}
+			}
+
+			strs = strs[:0]
+			numbers = numbers[:0]
+		}
+	}
+
+	return text.String()
+}
+
+func readLiteralString(content []byte, start int) (string, int) {
+	var value []byte
+
+	depth := 0
+	i := start
+
+	for ; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+
+			switch escaped := content[i]; escaped {
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 't':
+				value = append(value, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// A backslash before a line break continues the string on the next line
+				if escaped == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+					i++
+				}
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					code := 0
+
+					for digits := 0; digits < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; digits++ {
+						code = code*8 + int(content[i]-'0')
+						i++
+					}
+
+					i--
+					value = append(value, byte(code))
+				} else {
+					value = append(value, escaped)
+				}
+			}
+
+		case c == '(':
+			if depth > 0 {
+				value = append(value, c)
+			}
+
+			depth++
+
+		case c == ')':
+			depth--
+
+			if depth == 0 {
+				return decodeString(value), i + 1
+			}
+
+			value = append(value, c)
+
+		default:
+			value = append(value, c)
+		}
+	}
+
+	return decodeString(value), i
+}
+
+func readHexString(content []byte, start int) (string, int) {
+	end := bytes.IndexByte(content[start:], '>')
+
+	if end < 0 {
+		return "", len(content)
+	}
+
+	digits := string(bytes.Join(bytes.Fields(content[start+1:start+end]), nil))
+
+	if len(digits)%2 == 1 {
+		digits += "0"
+	}
+
+	value, err := hex.DecodeString(digits)
+
+	if err != nil {
+		return "", start + end + 1
+	}
+
+	return decodeString(value), start + end + 1
+}
+
+// decodeString reads UTF-16 strings marked by a byte order mark and treats anything else as Latin-1,
+// which matches PDFDocEncoding for the printable range
+func decodeString(value []byte) string {
+	if len(value) >= 2 && value[0] == 0xFE && value[1] == 0xFF {
+		units := make([]uint16, 0, len(value)/2)
+
+		for i := 2; i+1 < len(value); i += 2 {
+			units = append(units, uint16(value[i])<<8|uint16(value[i+1]))
+		}
+
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, 0, len(value))
+
+	for _, b := range value {
+		if b >= 0x20 || b == '\n' || b == '\t' {
+			runes = append(runes, rune(b))
+		}
+	}
+
+	return string(runes)
+}
+
+// tidyText trims every line and collapses the blank lines left behind by positioning operators
+func tidyText(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+
+		if line == "" && (len(kept) == 0 || kept[len(kept)-1] == "") {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
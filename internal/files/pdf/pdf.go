@@ -0,0 +1,137 @@
+// Package pdf is a best effort text extractor for PDFs attached to chats. It follows the strings drawn by
+// the text operators of every content stream without decoding fonts, so PDFs whose fonts use custom
+// encodings come out empty or garbled. Input is untrusted, so its size, stream count and decompressed
+// bytes are all capped.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// Request bodies are capped at 10MB, so anything larger didn't come through the API
+	MaxInputSize = 16 << 20
+
+	// A flate stream can expand a thousandfold, so the total across streams is capped too
+	MaxDecompressedSize = 64 << 20
+
+	MaxStreams = 4096
+)
+
+var (
+	ErrTooLarge          = errors.New("pdf is larger than the 16MB limit")
+	ErrTooManyStreams    = errors.New("pdf has more than 4096 streams")
+	ErrDecompressedLimit = errors.New("pdf streams decompress past the 64MB limit")
+)
+
+// Streams holding images, fonts or cross references never carry page text
+var skippedStreams = []string{
+	"/Subtype/Image", "/Type/XRef", "/Type/ObjStm", "/Type/Metadata", "/Type/EmbeddedFile",
+	"/Length1", "/Length2", "/Subtype/Type1C", "/Subtype/CIDFontType0C", "/Subtype/OpenType",
+}
+
+// ExtractText returns the text shown on the pages of a PDF, an empty string when none could be read
+func ExtractText(data []byte) (string, error) {
+	if len(data) > MaxInputSize {
+		return "", ErrTooLarge
+	}
+
+	var text strings.Builder
+
+	streams := 0
+	budget := MaxDecompressedSize
+
+	for offset := 0; ; {
+		start := bytes.Index(data[offset:], []byte("stream"))
+
+		if start < 0 {
+			break
+		}
+
+		start += offset
+
+		// The keyword also ends every stream
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			offset = start + len("stream")
+			continue
+		}
+
+		header := data[max(bytes.LastIndex(data[:start], []byte("obj")), 0):start]
+		bodyStart := start + len("stream")
+
+		if bodyStart < len(data) && data[bodyStart] == '\r' {
+			bodyStart++
+		}
+
+		if bodyStart < len(data) && data[bodyStart] == '\n' {
+			bodyStart++
+		}
+
+		end := bytes.Index(data[bodyStart:], []byte("endstream"))
+
+		if end < 0 {
+			break
+		}
+
+		body := data[bodyStart : bodyStart+end]
+		offset = bodyStart + end + len("endstream")
+
+		if streams++; streams > MaxStreams {
+			return "", ErrTooManyStreams
+		}
+
+		content, err := decodeStream(header, body, budget)
+
+		if err != nil {
+			return "", err
+		}
+
+		budget -= len(content)
+		text.WriteString(extractContentText(content))
+	}
+
+	return tidyText(text.String()), nil
+}
+
+// decodeStream returns the content of a stream that may hold page text, nil for any other stream. Flate
+// streams may decode to at most budget bytes
+func decodeStream(header []byte, body []byte, budget int) ([]byte, error) {
+	// "/Subtype /Image" becomes "/Subtype/Image" while "/Length 12" keeps its space
+	compact := strings.ReplaceAll(strings.Join(strings.Fields(string(header)), " "), " /", "/")
+
+	for _, marker := range skippedStreams {
+		if strings.Contains(compact, marker) {
+			return nil, nil
+		}
+	}
+
+	if !strings.Contains(compact, "/Filter") {
+		return body, nil
+	}
+
+	// Other filters are image codecs or rare enough to skip
+	if !strings.Contains(compact, "/FlateDecode") || strings.Count(compact, "Decode") > 1 {
+		return nil, nil
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(body))
+
+	if err != nil {
+		return nil, nil
+	}
+
+	defer reader.Close()
+
+	// A truncated or badly checksummed stream still yields whatever decoded before the error
+	content, _ := io.ReadAll(io.LimitReader(reader, int64(budget)+1))
+
+	if len(content) > budget {
+		return nil, ErrDecompressedLimit
+	}
+
+	return content, nil
+}
@@ -0,0 +1,178 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// stream is one object of a fixture PDF, its dictionary entries and raw body
+type stream struct {
+	dictionary string
+	body       []byte
+}
+
+func content(body string) stream {
+	return stream{body: []byte(body)}
+}
+
+func flate(t *testing.T, body string) stream {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+
+	if _, err := writer.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return stream{dictionary: "/Filter /FlateDecode", body: compressed.Bytes()}
+}
+
+func buildPDF(streams ...stream) []byte {
+	var document bytes.Buffer
+
+	document.WriteString("%PDF-1.4\n")
+
+	for i, s := range streams {
+		fmt.Fprintf(&document, "%d 0 obj\n<< /Length %d %s >>\nstream\n", i+1, len(s.body), s.dictionary)
+		document.Write(s.body)
+		document.WriteString("\nendstream\nendobj\n")
+	}
+
+	document.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	return document.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	cases := []struct {
+		name    string
+		streams func(t *testing.T) []stream
+		want    string
+	}{
+		{"literal string", func(t *testing.T) []stream {
+			return []stream{content("BT /F1 12 Tf 72 712 Td (Hello world) Tj ET")}
+		}, "Hello world"},
+		{"escapes", func(t *testing.T) []stream {
+			return []stream{content(`BT (f\(x\) \\ \101\102 tab\there) Tj ET`)}
+		}, `f(x) \ AB tab here`},
+		{"escaped line break and continuation", func(t *testing.T) []stream {
+			return []stream{content("BT (one\\ntwo) Tj (con\\\ntinued) Tj ET")}
+		}, "one\ntwocontinued"},
+		{"balanced parentheses", func(t *testing.T) []stream {
+			return []stream{content("BT (f(x) = (y)) Tj ET")}
+		}, "f(x) = (y)"},
+		{"hex string", func(t *testing.T) []stream {
+			return []stream{content("BT <48 65 6C 6C 6F> Tj ET")}
+		}, "Hello"},
+		{"odd length hex string", func(t *testing.T) []stream {
+			return []stream{content("BT <4869 2> Tj ET")}
+		}, "Hi"},
+		{"UTF-16 strings", func(t *testing.T) []stream {
+			return []stream{content("BT <FEFF00630061006600E9> Tj (\xfe\xff\x00O\x00K) Tj ET")}
+		}, "caféOK"},
+		{"TJ arrays with kerning", func(t *testing.T) []stream {
+			return []stream{content("BT [(Hel) 20 (lo) -300 (world)] TJ ET")}
+		}, "Hello world"},
+		{"quote operators start new lines", func(t *testing.T) []stream {
+			return []stream{content(`BT (first) Tj (second) ' 1 2 (third) " ET`)}
+		}, "first\nsecond\nthird"},
+		{"positioning", func(t *testing.T) []stream {
+			return []stream{content("BT (a) Tj 0 -14 Td (b) Tj 20 0 Td (c) Tj T* (d) Tj ET")}
+		}, "a\nb c\nd"},
+		{"strings outside text objects and comments are ignored", func(t *testing.T) []stream {
+			return []stream{content("(outside) Tj\n% (comment) Tj\nBT /F1 <</MCID 0>> BDC (inside) Tj EMC ET")}
+		}, "inside"},
+		{"flate stream", func(t *testing.T) []stream {
+			return []stream{flate(t, "BT (compressed text) Tj ET")}
+		}, "compressed text"},
+		{"pages in order", func(t *testing.T) []stream {
+			return []stream{content("BT (page one) Tj ET"), flate(t, "BT (page two) Tj ET")}
+		}, "page one\npage two"},
+		{"images and fonts are skipped", func(t *testing.T) []stream {
+			return []stream{
+				{dictionary: "/Subtype /Image", body: []byte("BT (pixels) Tj ET")},
+				{dictionary: "/Length1 10", body: []byte("BT (glyphs) Tj ET")},
+				content("BT (text) Tj ET"),
+			}
+		}, "text"},
+		{"other filters are skipped", func(t *testing.T) []stream {
+			return []stream{{dictionary: "/Filter /DCTDecode", body: []byte("BT (jpeg) Tj ET")}, content("BT (text) Tj ET")}
+		}, "text"},
+		{"corrupt flate stream is skipped", func(t *testing.T) []stream {
+			return []stream{{dictionary: "/Filter /FlateDecode", body: []byte("not zlib at all")}, content("BT (still read) Tj ET")}
+		}, "still read"},
+		{"truncated flate stream keeps what decoded", func(t *testing.T) []stream {
+			full := flate(t, "BT (kept) Tj ET"+strings.Repeat(" ", 4096))
+			full.body = full.body[:len(full.body)-6]
+
+			return []stream{full}
+		}, "kept"},
+		{"no text", func(t *testing.T) []stream {
+			return []stream{content("0 0 m 100 100 l S")}
+		}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			text, err := ExtractText(buildPDF(tc.streams(t)...))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if text != tc.want {
+				t.Errorf("got %q, want %q", text, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractTextUnterminatedInput(t *testing.T) {
+	inputs := []string{
+		"%PDF-1.4\n1 0 obj\n<< >>\nstream\nBT (never ends) Tj ET",
+		"%PDF-1.4\n1 0 obj\n<< >>\nstream\nBT (open string Tj ET\nendstream",
+		"%PDF-1.4\n1 0 obj\n<< >>\nstream\nBT <4142 Tj ET\nendstream",
+		"stream",
+		"",
+	}
+
+	for _, input := range inputs {
+		if _, err := ExtractText([]byte(input)); err != nil {
+			t.Errorf("%q: unexpected error %v", input, err)
+		}
+	}
+}
+
+func TestExtractTextLimits(t *testing.T) {
+	oversized := append(buildPDF(content("BT (big) Tj ET")), make([]byte, MaxInputSize)...)
+
+	if _, err := ExtractText(oversized); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized input: got %v, want %v", err, ErrTooLarge)
+	}
+
+	streams := make([]stream, MaxStreams+1)
+
+	for i := range streams {
+		streams[i] = content("BT (x) Tj ET")
+	}
+
+	if _, err := ExtractText(buildPDF(streams...)); !errors.Is(err, ErrTooManyStreams) {
+		t.Errorf("too many streams: got %v, want %v", err, ErrTooManyStreams)
+	}
+
+	// Two streams each under the per-document limit that pass it together
+	half := strings.Repeat(" ", MaxDecompressedSize/2+1)
+
+	if _, err := ExtractText(buildPDF(flate(t, half), flate(t, half))); !errors.Is(err, ErrDecompressedLimit) {
+		t.Errorf("decompression bomb: got %v, want %v", err, ErrDecompressedLimit)
+	}
+}
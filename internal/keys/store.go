@@ -0,0 +1,285 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	keysFileName     = "keys.json"
+	kdfIterations    = 600_000
+	verifierText     = "agentk-keystore"
+	verifierAAD      = "verifier"
+	saltSize         = 16
+	derivedKeyLength = 32
+)
+
+// Store is nil when KEYS_MASTER_SECRET is unset, keys then come from the environment only
+var Store *KeyStore
+
+var (
+	ErrStoreDisabled = errors.New("key storage is disabled, set KEYS_MASTER_SECRET to enable it")
+	ErrKeyNotFound   = errors.New("no stored key for provider")
+	ErrWrongSecret   = errors.New("KEYS_MASTER_SECRET does not match the one the key store was created with")
+)
+
+// KeyStore holds provider keys in memory and persists them AES-GCM encrypted with a key
+// derived from the master secret, plaintext keys never touch the disk
+type KeyStore struct {
+	path  string
+	aead  cipher.AEAD
+	mutex sync.RWMutex
+	keys  map[types.Provider]string
+	data  keyFile
+}
+
+type keyFile struct {
+	Salt     string                       `json:"salt"`
+	Verifier string                       `json:"verifier"`
+	Keys     map[types.Provider]sealedKey `json:"keys"`
+}
+
+type sealedKey struct {
+	Ciphertext string `json:"ciphertext"` // base64 of nonce followed by the sealed key
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+func InitializeKeyStore(dataDir string) error {
+	secret := os.Getenv("KEYS_MASTER_SECRET")
+
+	if secret == "" {
+		return nil
+	}
+
+	store, err := NewKeyStore(dataDir, secret)
+
+	if err != nil {
+		return err
+	}
+
+	Store = store
+
+	log.Printf("Loaded key store providers=%d", len(store.keys))
+
+	return nil
+}
+
+func NewKeyStore(dataDir string, secret string) (*KeyStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir failed: %w", err)
+	}
+
+	store := &KeyStore{
+		path: filepath.Join(dataDir, keysFileName),
+		keys: map[types.Provider]string{},
+	}
+
+	bytes, err := os.ReadFile(store.path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		salt := make([]byte, saltSize)
+
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		store.data = keyFile{Salt: base64.StdEncoding.EncodeToString(salt), Keys: map[types.Provider]sealedKey{}}
+	case err != nil:
+		return nil, fmt.Errorf("read %s failed: %w", store.path, err)
+	default:
+		if err := json.Unmarshal(bytes, &store.data); err != nil {
+			return nil, fmt.Errorf("parse %s failed: %w", store.path, err)
+		}
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(store.data.Salt)
+
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: invalid salt", store.path)
+	}
+
+	if store.aead, err = newAEAD(secret, salt); err != nil {
+		return nil, err
+	}
+
+	// The verifier catches a changed secret even when the store holds no keys yet
+	if store.data.Verifier == "" {
+		if store.data.Verifier, err = store.seal([]byte(verifierText), verifierAAD); err != nil {
+			return nil, err
+		}
+
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+	} else if _, err := store.open(store.data.Verifier, verifierAAD); err != nil {
+		return nil, ErrWrongSecret
+	}
+
+	if store.data.Keys == nil {
+		store.data.Keys = map[types.Provider]sealedKey{}
+	}
+
+	for provider, sealed := range store.data.Keys {
+		plaintext, err := store.open(sealed.Ciphertext, string(provider))
+
+		if err != nil {
+			return nil, fmt.Errorf("decrypt key for %s failed: %w", provider, err)
+		}
+
+		store.keys[provider] = string(plaintext)
+	}
+
+	return store, nil
+}
+
+func (s *KeyStore) Get(provider types.Provider) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	key, ok := s.keys[provider]
+
+	return key, ok
+}
+
+// UpdatedAt reports when a provider's key was last stored, in Unix milliseconds
+func (s *KeyStore) UpdatedAt(provider types.Provider) (int64, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sealed, ok := s.data.Keys[provider]
+
+	return sealed.UpdatedAt, ok
+}
+
+// Set adds or rotates a provider's key
+func (s *KeyStore) Set(provider types.Provider, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// The provider name is bound as additional data so a ciphertext can't be moved to another provider
+	ciphertext, err := s.seal([]byte(key), string(provider))
+
+	if err != nil {
+		return err
+	}
+
+	previous, existed := s.data.Keys[provider]
+	s.data.Keys[provider] = sealedKey{Ciphertext: ciphertext, UpdatedAt: time.Now().UnixMilli()}
+
+	if err := s.save(); err != nil {
+		if existed {
+			s.data.Keys[provider] = previous
+		} else {
+			delete(s.data.Keys, provider)
+		}
+
+		return err
+	}
+
+	s.keys[provider] = key
+
+	return nil
+}
+
+func (s *KeyStore) Delete(provider types.Provider) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, ok := s.data.Keys[provider]
+
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	delete(s.data.Keys, provider)
+
+	if err := s.save(); err != nil {
+		s.data.Keys[provider] = previous
+		return err
+	}
+
+	delete(s.keys, provider)
+
+	return nil
+}
+
+func (s *KeyStore) seal(plaintext []byte, additionalData string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, plaintext, []byte(additionalData))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *KeyStore) open(encoded string, additionalData string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+
+	return s.aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+}
+
+// save writes to a temp file and renames it so a crash never leaves a half-written store
+func (s *KeyStore) save() error {
+	bytes, err := json.Marshal(s.data)
+
+	if err != nil {
+		return err
+	}
+
+	tempPath := s.path + ".tmp"
+
+	if err := os.WriteFile(tempPath, bytes, 0o600); err != nil {
+		return fmt.Errorf("write %s failed: %w", tempPath, err)
+	}
+
+	return os.Rename(tempPath, s.path)
+}
+
+func newAEAD(secret string, salt []byte) (cipher.AEAD, error) {
+	derived, err := pbkdf2.Key(sha256.New, secret, salt, kdfIterations, derivedKeyLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Lookup returns the stored key for a provider when key storage is enabled
+func Lookup(provider types.Provider) (string, bool) {
+	if Store == nil {
+		return "", false
+	}
+
+	return Store.Get(provider)
+}
@@ -0,0 +1,131 @@
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func newTestStore(t *testing.T, dataDir string, secret string) *KeyStore {
+	t.Helper()
+
+	store, err := NewKeyStore(dataDir, secret)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestKeyStoreRoundTrip(t *testing.T) {
+	dataDir := t.TempDir()
+	store := newTestStore(t, dataDir, "correct horse")
+
+	if err := store.Set("OpenAI", "sk-first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("OpenAI", "sk-rotated"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("Anthropic", "sk-ant"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete("Anthropic"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete("Anthropic"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("deleting a missing key: got %v, want %v", err, ErrKeyNotFound)
+	}
+
+	bytes, err := os.ReadFile(filepath.Join(dataDir, keysFileName))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(bytes), "sk-") {
+		t.Fatalf("plaintext key written to disk: %s", bytes)
+	}
+
+	reloaded := newTestStore(t, dataDir, "correct horse")
+
+	if key, ok := reloaded.Get("OpenAI"); !ok || key != "sk-rotated" {
+		t.Errorf("OpenAI key after reload = %q, %v, want the rotated key", key, ok)
+	}
+
+	if _, ok := reloaded.Get("Anthropic"); ok {
+		t.Error("deleted Anthropic key came back after reload")
+	}
+
+	if _, ok := reloaded.UpdatedAt("OpenAI"); !ok {
+		t.Error("OpenAI key lost its update time")
+	}
+}
+
+func TestKeyStoreRejectsWrongSecret(t *testing.T) {
+	// An empty store is checked by its verifier alone
+	emptyDir := t.TempDir()
+	newTestStore(t, emptyDir, "correct horse")
+
+	if _, err := NewKeyStore(emptyDir, "battery staple"); !errors.Is(err, ErrWrongSecret) {
+		t.Errorf("empty store: got %v, want %v", err, ErrWrongSecret)
+	}
+
+	dataDir := t.TempDir()
+	store := newTestStore(t, dataDir, "correct horse")
+
+	if err := store.Set("OpenAI", "sk-openai"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeyStore(dataDir, "battery staple"); !errors.Is(err, ErrWrongSecret) {
+		t.Errorf("store with keys: got %v, want %v", err, ErrWrongSecret)
+	}
+}
+
+func TestKeyStoreBindsKeysToProviders(t *testing.T) {
+	dataDir := t.TempDir()
+	store := newTestStore(t, dataDir, "correct horse")
+
+	if err := store.Set("OpenAI", "sk-openai"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A ciphertext copied to another provider's entry must not decrypt
+	path := filepath.Join(dataDir, keysFileName)
+	bytes, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file keyFile
+
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	file.Keys[types.Provider("Groq")] = file.Keys["OpenAI"]
+
+	if bytes, err = json.Marshal(file); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, bytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeyStore(dataDir, "correct horse"); err == nil {
+		t.Error("a key moved to another provider was accepted")
+	}
+}
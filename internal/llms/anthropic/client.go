@@ -3,35 +3,124 @@ package anthropic
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 	sdk "github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/pagination"
 )
 
 type AnthropicClient struct {
-	Client *sdk.Client
+	Client   *sdk.Client
+	Keys     *keypool.Pool
+	Endpoint string
+	Timeout  time.Duration
 }
 
-func (c *AnthropicClient) Chat(chatRequest *types.ChatRequest, contextMessages any) (string, error) {
+func (c *AnthropicClient) KeyPool() *keypool.Pool {
+	return c.Keys
+}
+
+// Encode converts canonical messages into the Conversation Chat and ChatStream expect
+func (c *AnthropicClient) Encode(messages []types.Message) (any, error) {
+	return BuildAnthropicMessages(messages)
+}
+
+// ReadsFiles is true for every Claude model, PDFs and text go out as document blocks
+func (c *AnthropicClient) ReadsFiles(modelID string) bool {
+	return true
+}
+
+func (c *AnthropicClient) Chat(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
 	// Generate a chat completion
-	llmResponse, err := c.Client.Messages.New(context.Background(), buildMessageParams(chatRequest.ModelID, chatRequest.Tokens, chatRequest.SystemPrompt, contextMessages))
+	params, err := buildMessageParams(chatRequest, contextMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*sdk.Message, error) {
+		llmResponse, err := c.Client.Messages.New(ctx, params, buildRequestOptions(c.Endpoint, key)...)
+
+		if err != nil {
+			return nil, classifyError(err)
+		}
+
+		return llmResponse, nil
+	})
 
 	if err != nil {
-		return "", fmt.Errorf("anthropic API error: %w", err)
+		return nil, err
 	}
 
 	if len(llmResponse.Content) == 0 {
-		return "", fmt.Errorf("no content in anthropic response")
+		return nil, fmt.Errorf("no content in anthropic response")
 	}
 
-	// Return the first content text
-	return llmResponse.Content[0].Text, nil
+	return buildChatResult(llmResponse), nil
 }
 
-func (c *AnthropicClient) Models() ([]*types.Model, error) {
-	llmResponse, err := c.Client.Models.List(context.Background(), sdk.ModelListParams{
-		Limit: sdk.Int(1000),
+func (c *AnthropicClient) ChatStream(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	params, err := buildMessageParams(chatRequest, contextMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.Keys.Pick()
+
+	stream := c.Client.Messages.NewStreaming(ctx, params, buildRequestOptions(c.Endpoint, key)...)
+	defer stream.Close()
+
+	// Accumulate events so the final stop reason and usage are available once the stream ends
+	message := sdk.Message{}
+
+	for stream.Next() {
+		event := stream.Current()
+
+		if err := message.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("anthropic stream error: %w", err)
+		}
+
+		if delta, ok := event.AsAny().(sdk.ContentBlockDeltaEvent); ok && delta.Delta.Text != "" {
+			if err := onDelta(delta.Delta.Text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		err = classifyError(err)
+		c.Keys.Report(key, err)
+
+		return nil, err
+	}
+
+	return buildChatResult(&message), nil
+}
+
+func (c *AnthropicClient) Models(ctx context.Context) ([]*types.Model, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*pagination.Page[sdk.ModelInfo], error) {
+		llmResponse, err := c.Client.Models.List(ctx, sdk.ModelListParams{
+			Limit: sdk.Int(1000),
+		}, buildRequestOptions(c.Endpoint, key)...)
+
+		if err != nil {
+			return nil, classifyError(err)
+		}
+
+		return llmResponse, nil
 	})
 
 	if err != nil {
@@ -41,11 +130,15 @@ func (c *AnthropicClient) Models() ([]*types.Model, error) {
 	models := make([]*types.Model, 0, len(llmResponse.Data))
 
 	for _, model := range llmResponse.Data {
-		id := model.ID
+		id, name := model.ID, model.DisplayName
+
+		if name == "" {
+			name = id
+		}
 
 		models = append(models, &types.Model{
 			ID:       id,
-			Name:     id,
+			Name:     name,
 			Provider: utils.ANTHROPIC,
 			Enabled:  true,
 		})
@@ -1,63 +1,255 @@
 package anthropic
 
 import (
+	"cmp"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 	sdk "github.com/anthropics/anthropic-sdk-go"
+	sdkOption "github.com/anthropics/anthropic-sdk-go/option"
 )
 
-func buildMessageParams(modelID string, tokens int64, systemPrompt string, messages any) sdk.MessageNewParams {
+// Conversation is the Anthropic context, messages plus any system messages pulled out of the conversation
+type Conversation struct {
+	Messages []sdk.MessageParam
+	System   []string
+}
+
+func buildMessageParams(chatRequest *types.ChatRequest, messages any) (sdk.MessageNewParams, error) {
+	tokens := chatRequest.Tokens
+
 	if tokens == 0 {
 		tokens = 4096
 	}
 
+	conversation, ok := messages.(*Conversation)
+
+	if !ok {
+		return sdk.MessageNewParams{}, fmt.Errorf("anthropic client got a %T context, expected *Conversation", messages)
+	}
+
 	params := sdk.MessageNewParams{
-		Model:     sdk.Model(modelID),
+		Model:     sdk.Model(chatRequest.ModelID),
 		MaxTokens: tokens,
-		Messages:  messages.([]sdk.MessageParam),
+		Messages:  conversation.Messages,
 	}
 
+	systemTexts := conversation.System
+
 	// Add system prompt if provided
-	if systemPrompt != "" {
+	if chatRequest.SystemPrompt != "" {
+		systemTexts = append([]string{chatRequest.SystemPrompt}, systemTexts...)
+	}
+
+	if len(systemTexts) > 0 {
 		params.System = []sdk.TextBlockParam{
 			{
-				Text: systemPrompt,
+				Text: strings.Join(systemTexts, "\n\n"),
 			},
 		}
 	}
 
-	return params
+	if len(chatRequest.Tools) == 0 {
+		return params, nil
+	}
+
+	tools, err := buildTools(chatRequest.Tools)
+
+	if err != nil {
+		return params, err
+	}
+
+	params.Tools = tools
+	params.ToolChoice = buildToolChoice(chatRequest.ToolChoice)
+
+	return params, nil
 }
 
-func BuildAnthropicMessages(messages []types.Message) ([]sdk.MessageParam, error) {
-	raw := make([]map[string]any, 0, len(messages))
+func buildTools(definitions []types.ToolDefinition) ([]sdk.ToolUnionParam, error) {
+	tools := make([]sdk.ToolUnionParam, 0, len(definitions))
 
-	for _, msg := range messages {
-		if isJSONString(msg.Content) {
-			var text string
-			if err := json.Unmarshal(msg.Content, &text); err != nil {
-				return nil, err
+	for _, definition := range definitions {
+		schema := map[string]any{}
+
+		if len(definition.Parameters) > 0 {
+			if err := json.Unmarshal(definition.Parameters, &schema); err != nil {
+				return nil, fmt.Errorf("invalid parameters schema for tool %q: %w", definition.Name, err)
 			}
+		}
 
-			raw = append(raw, map[string]any{
-				"role":    msg.Role,
-				"content": text,
-			})
-			continue
+		inputSchema := sdk.ToolInputSchemaParam{
+			Properties:  schema["properties"],
+			ExtraFields: map[string]any{},
 		}
 
-		if !isJSONArray(msg.Content) {
-			return nil, fmt.Errorf("invalid content type for anthropic")
+		// Anthropic models the schema's top level fields explicitly, everything else passes through
+		for key, value := range schema {
+			switch key {
+			case "type", "properties":
+			case "required":
+				required, err := toStringSlice(value)
+
+				if err != nil {
+					return nil, fmt.Errorf("invalid required list for tool %q: %w", definition.Name, err)
+				}
+
+				inputSchema.Required = required
+			default:
+				inputSchema.ExtraFields[key] = value
+			}
 		}
 
-		var parts []types.MessagePart
-		if err := json.Unmarshal(msg.Content, &parts); err != nil {
+		tool := &sdk.ToolParam{
+			Name:        definition.Name,
+			InputSchema: inputSchema,
+		}
+
+		if definition.Description != "" {
+			tool.Description = sdk.String(definition.Description)
+		}
+
+		tools = append(tools, sdk.ToolUnionParam{OfTool: tool})
+	}
+
+	return tools, nil
+}
+
+func buildToolChoice(choice string) sdk.ToolChoiceUnionParam {
+	switch choice {
+	case "", "auto":
+		return sdk.ToolChoiceUnionParam{OfAuto: &sdk.ToolChoiceAutoParam{}}
+	case "none":
+		return sdk.ToolChoiceUnionParam{OfNone: &sdk.ToolChoiceNoneParam{}}
+	case "required":
+		return sdk.ToolChoiceUnionParam{OfAny: &sdk.ToolChoiceAnyParam{}}
+	default:
+		return sdk.ToolChoiceUnionParam{OfTool: &sdk.ToolChoiceToolParam{Name: choice}}
+	}
+}
+
+func toStringSlice(value any) ([]string, error) {
+	items, ok := value.([]any)
+
+	if !ok {
+		return nil, fmt.Errorf("expected an array of strings")
+	}
+
+	results := make([]string, 0, len(items))
+
+	for _, item := range items {
+		text, ok := item.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("expected an array of strings")
+		}
+
+		results = append(results, text)
+	}
+
+	return results, nil
+}
+
+func buildRequestOptions(endpoint string, key string) []sdkOption.RequestOption {
+	// The SDK adds the /v1/... paths itself, so the configured model endpoint is cut back to the API root
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), "/v1/models")
+
+	return []sdkOption.RequestOption{
+		sdkOption.WithBaseURL(baseURL),
+		sdkOption.WithAPIKey(key),
+		// SDK retries are disabled, the chat service retries with provider aware backoff instead
+		sdkOption.WithMaxRetries(0),
+	}
+}
+
+func classifyError(err error) error {
+	var apiErr *sdk.Error
+
+	if !errors.As(err, &apiErr) {
+		return llmerrors.ClassifyTransport(utils.ANTHROPIC, err)
+	}
+
+	// Anthropic error bodies look like {"type": "error", "error": {"type": "overloaded_error", "message": "..."}}
+	body := struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+
+	_ = json.Unmarshal([]byte(apiErr.RawJSON()), &body)
+
+	var header http.Header
+
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+
+	return llmerrors.Classify(utils.ANTHROPIC, err, apiErr.StatusCode, body.Error.Type, body.Error.Message, header)
+}
+
+func buildChatResult(message *sdk.Message) *types.ChatResult {
+	var text strings.Builder
+	var toolCalls []types.ToolCall
+
+	// Join every text block and collect tool calls, skipping thinking and other blocks
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: block.Input,
+			})
+		}
+	}
+
+	usage := message.Usage
+
+	return &types.ChatResult{
+		Text:       text.String(),
+		ToolCalls:  toolCalls,
+		Model:      string(message.Model),
+		StopReason: string(message.StopReason),
+		Usage: types.Usage{
+			// Anthropic reports cache reads and writes separately from uncached input
+			InputTokens:  usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens,
+			OutputTokens: usage.OutputTokens,
+			CachedTokens: usage.CacheReadInputTokens,
+		},
+	}
+}
+
+// BuildAnthropicMessages converts canonical messages into Anthropic messages. System messages are
+// kept apart since Anthropic takes them as the system prompt
+func BuildAnthropicMessages(messages []types.Message) (*Conversation, error) {
+	raw := make([]map[string]any, 0, len(messages))
+	systemTexts := make([]string, 0)
+
+	for _, msg := range messages {
+		parts, err := msg.Parts()
+
+		if err != nil {
 			return nil, err
 		}
 
+		if msg.Role == "system" {
+			for _, part := range parts {
+				systemTexts = append(systemTexts, part.Text)
+			}
+
+			continue
+		}
+
 		blocks := make([]map[string]any, 0, len(parts))
 
 		for _, part := range parts {
@@ -83,9 +275,81 @@ func BuildAnthropicMessages(messages []types.Message) ([]sdk.MessageParam, error
 					"type":   "image",
 					"source": src,
 				})
+
+			case "file":
+				if part.File == nil {
+					return nil, fmt.Errorf("file missing file field")
+				}
+
+				document, err := buildDocumentBlock(part.File)
+
+				if err != nil {
+					return nil, err
+				}
+
+				blocks = append(blocks, document)
+
+			case "thinking":
+				// Anthropic only accepts thinking it signed, anything else is left out
+				if part.Signature == "" {
+					continue
+				}
+
+				blocks = append(blocks, map[string]any{
+					"type":      "thinking",
+					"thinking":  part.Text,
+					"signature": part.Signature,
+				})
+
+			case "tool_call":
+				if part.ToolCall == nil {
+					return nil, fmt.Errorf("tool_call missing tool_call field")
+				}
+
+				input := part.ToolCall.Arguments
+
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+
+				blocks = append(blocks, map[string]any{
+					"type":  "tool_use",
+					"id":    part.ToolCall.ID,
+					"name":  part.ToolCall.Name,
+					"input": input,
+				})
+
+			case "tool_result":
+				block := map[string]any{
+					"type":        "tool_result",
+					"tool_use_id": part.ToolCallID,
+					"content":     part.Text,
+				}
+
+				if part.IsError {
+					block["is_error"] = true
+				}
+
+				blocks = append(blocks, block)
 			}
 		}
 
+		if len(blocks) == 0 {
+			continue
+		}
+
+		// Back to back messages from one side become a single turn, so the results of parallel
+		// tool calls end up next to each other in the user turn that follows the tool_use blocks
+		if last := len(raw) - 1; last >= 0 && raw[last]["role"] == msg.Role {
+			blocks = append(raw[last]["content"].([]map[string]any), blocks...)
+			raw = raw[:last]
+		}
+
+		// Anthropic requires tool_result blocks to open the turn
+		slices.SortStableFunc(blocks, func(a, b map[string]any) int {
+			return cmp.Compare(toolResultOrder(a), toolResultOrder(b))
+		})
+
 		raw = append(raw, map[string]any{
 			"role":    msg.Role,
 			"content": blocks,
@@ -104,7 +368,43 @@ func BuildAnthropicMessages(messages []types.Message) ([]sdk.MessageParam, error
 		return nil, err
 	}
 
-	return parsed, nil
+	return &Conversation{Messages: parsed, System: systemTexts}, nil
+}
+
+func toolResultOrder(block map[string]any) int {
+	if block["type"] == "tool_result" {
+		return 0
+	}
+
+	return 1
+}
+
+// buildDocumentBlock sends PDFs as base64 documents and text files as text documents, the Messages API has
+// no source for files uploaded through the beta Files API
+func buildDocumentBlock(file *types.File) (map[string]any, error) {
+	if file.FileData == "" {
+		return nil, fmt.Errorf("file %s: anthropic needs the file data, upload references aren't supported", utils.FileName(file))
+	}
+
+	mediaType, data, err := utils.ParseDataURL(file.FileData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source := map[string]any{"type": "text", "media_type": "text/plain", "data": string(data)}
+
+	if mediaType == utils.PDFMediaType {
+		source = map[string]any{"type": "base64", "media_type": utils.PDFMediaType, "data": base64.StdEncoding.EncodeToString(data)}
+	}
+
+	document := map[string]any{"type": "document", "source": source}
+
+	if file.Filename != "" {
+		document["title"] = file.Filename
+	}
+
+	return document, nil
 }
 
 func parseImageURL(url string) (source map[string]any, err error) {
@@ -132,11 +432,3 @@ func parseImageURL(url string) (source map[string]any, err error) {
 		"url":  url,
 	}, nil
 }
-
-func isJSONString(b json.RawMessage) bool {
-	return len(b) > 0 && b[0] == '"'
-}
-
-func isJSONArray(b json.RawMessage) bool {
-	return len(b) > 0 && b[0] == '['
-}
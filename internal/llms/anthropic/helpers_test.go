@@ -0,0 +1,255 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func message(t *testing.T, role string, parts ...types.MessagePart) types.Message {
+	t.Helper()
+
+	content, err := json.Marshal(parts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Message{Role: role, Content: content}
+}
+
+type encodedBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	Signature string          `json:"signature"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	IsError   bool            `json:"is_error"`
+	Content   []encodedBlock  `json:"content"`
+	Title     string          `json:"title"`
+	Source    struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+		URL       string `json:"url"`
+	} `json:"source"`
+}
+
+// decodeConversation reads encoded messages back into canonical ones so a case can check nothing was lost
+func decodeConversation(t *testing.T, conversation *Conversation) []types.Message {
+	t.Helper()
+
+	encoded, err := json.Marshal(conversation.Messages)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []struct {
+		Role    string         `json:"role"`
+		Content []encodedBlock `json:"content"`
+	}
+
+	if err := json.Unmarshal(encoded, &messages); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := make([]types.Message, 0, len(messages))
+
+	for _, encodedMessage := range messages {
+		parts := make([]types.MessagePart, 0, len(encodedMessage.Content))
+
+		for _, block := range encodedMessage.Content {
+			parts = append(parts, decodeBlock(t, block))
+		}
+
+		decoded = append(decoded, message(t, encodedMessage.Role, parts...))
+	}
+
+	return decoded
+}
+
+func decodeBlock(t *testing.T, block encodedBlock) types.MessagePart {
+	t.Helper()
+
+	switch block.Type {
+	case "text":
+		return types.MessagePart{Type: "text", Text: block.Text}
+	case "thinking":
+		return types.MessagePart{Type: "thinking", Text: block.Thinking, Signature: block.Signature}
+	case "image":
+		if block.Source.Type == "url" {
+			return types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: block.Source.URL}}
+		}
+
+		return types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "data:" + block.Source.MediaType + ";base64," + block.Source.Data}}
+	case "document":
+		data := block.Source.Data
+
+		if block.Source.Type == "text" {
+			data = base64.StdEncoding.EncodeToString([]byte(data))
+		}
+
+		return types.MessagePart{Type: "file", File: &types.File{Filename: block.Title, FileData: "data:" + block.Source.MediaType + ";base64," + data}}
+	case "tool_use":
+		return types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input}}
+	case "tool_result":
+		texts := make([]string, 0, len(block.Content))
+
+		for _, content := range block.Content {
+			texts = append(texts, content.Text)
+		}
+
+		return types.MessagePart{Type: "tool_result", ToolCallID: block.ToolUseID, Text: strings.Join(texts, ""), IsError: block.IsError}
+	}
+
+	t.Fatalf("unexpected block type %q", block.Type)
+
+	return types.MessagePart{}
+}
+
+func assertMessages(t *testing.T, got []types.Message, want []types.Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		gotParts, err := got[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantParts, err := want[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got[i].Role != want[i].Role || !reflect.DeepEqual(gotParts, wantParts) {
+			gotJSON, _ := json.Marshal(gotParts)
+			wantJSON, _ := json.Marshal(wantParts)
+			t.Errorf("message %d:\n got %s %s\nwant %s %s", i, got[i].Role, gotJSON, want[i].Role, wantJSON)
+		}
+	}
+}
+
+func TestBuildAnthropicMessagesRoundTrip(t *testing.T) {
+	pdf := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 fixture"))
+	text := "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("plain notes"))
+	toolCall := &types.ToolCall{ID: "call_1", Name: "calculator", Arguments: json.RawMessage(`{"expression":"2+2"}`)}
+
+	cases := []struct {
+		name string
+		part types.MessagePart
+		role string
+	}{
+		{"text", types.MessagePart{Type: "text", Text: "hello"}, "user"},
+		{"data URL image", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}}, "user"},
+		{"remote image", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://example.com/cat.png"}}, "user"},
+		{"pdf file", types.MessagePart{Type: "file", File: &types.File{Filename: "report.pdf", FileData: pdf}}, "user"},
+		{"text file", types.MessagePart{Type: "file", File: &types.File{Filename: "notes.txt", FileData: text}}, "user"},
+		{"tool call", types.MessagePart{Type: "tool_call", ToolCall: toolCall}, "assistant"},
+		{"tool result", types.MessagePart{Type: "tool_result", ToolCallID: "call_1", Text: "4"}, "user"},
+		{"failed tool result", types.MessagePart{Type: "tool_result", ToolCallID: "call_1", Text: "division by zero", IsError: true}, "user"},
+		{"signed thinking", types.MessagePart{Type: "thinking", Text: "let me add", Signature: "sig"}, "assistant"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages := []types.Message{message(t, tc.role, tc.part)}
+
+			conversation, err := BuildAnthropicMessages(messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeConversation(t, conversation), messages)
+		})
+	}
+}
+
+func TestBuildAnthropicMessages(t *testing.T) {
+	cases := []struct {
+		name       string
+		messages   []types.Message
+		want       []types.Message
+		wantSystem []string
+	}{
+		{
+			name: "system messages become the system prompt",
+			messages: []types.Message{
+				{Role: "system", Content: json.RawMessage(`"be brief"`)},
+				{Role: "user", Content: json.RawMessage(`"hi"`)},
+			},
+			want:       []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"hi"}]`)}},
+			wantSystem: []string{"be brief"},
+		},
+		{
+			name: "unsigned thinking is dropped",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","text":"hmm"},{"type":"text","text":"done"}]`)},
+			},
+			want: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"text","text":"done"}]`)}},
+		},
+		{
+			name: "parallel tool results are grouped ahead of text in one turn",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{"x":1}}},{"type":"tool_call","tool_call":{"id":"b","name":"calc","arguments":{"x":2}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"a","text":"1"}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"also"},{"type":"tool_result","tool_call_id":"b","text":"2"}]`)},
+			},
+			want: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{"x":1}}},{"type":"tool_call","tool_call":{"id":"b","name":"calc","arguments":{"x":2}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"a","text":"1"},{"type":"tool_result","tool_call_id":"b","text":"2"},{"type":"text","text":"also"}]`)},
+			},
+		},
+		{
+			name: "tool calls without arguments send an empty input",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"now"}}]`)},
+			},
+			want: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"now","arguments":{}}}]`)}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conversation, err := BuildAnthropicMessages(tc.messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeConversation(t, conversation), tc.want)
+
+			if len(tc.wantSystem) > 0 && !reflect.DeepEqual(conversation.System, tc.wantSystem) {
+				t.Errorf("system = %q, want %q", conversation.System, tc.wantSystem)
+			}
+		})
+	}
+}
+
+func TestBuildAnthropicMessagesRejectsUploadReferences(t *testing.T) {
+	messages := []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"file","file":{"file_id":"file-1"}}]`)}}
+
+	if _, err := BuildAnthropicMessages(messages); err == nil {
+		t.Fatal("expected an error for a file_id only file")
+	}
+}
+
+func TestBuildMessageParamsRejectsForeignContext(t *testing.T) {
+	if _, err := buildMessageParams(&types.ChatRequest{ModelID: "claude-sonnet-4-0"}, []string{"not a conversation"}); err == nil {
+		t.Fatal("expected an error for a context built by another client")
+	}
+}
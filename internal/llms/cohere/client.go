@@ -0,0 +1,184 @@
+package cohere
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
+	"github.com/CodingWithKarim/AgentK/internal/llms/modellist"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// CohereClient talks to the native Cohere API, v2 chat for grounded answers with citations and v1 for the model list
+type CohereClient struct {
+	HTTPClient    *http.Client
+	Provider      types.Provider
+	Keys          *keypool.Pool
+	BaseURL       string
+	ModelEndpoint string
+	Timeout       time.Duration
+}
+
+func (c *CohereClient) KeyPool() *keypool.Pool {
+	return c.Keys
+}
+
+// Encode converts canonical messages into the messages Chat and ChatStream expect
+func (c *CohereClient) Encode(messages []types.Message) (any, error) {
+	return BuildCohereMessages(messages)
+}
+
+// ReadsFiles is false, the chat API takes documents as text only
+func (c *CohereClient) ReadsFiles(modelID string) bool {
+	return false
+}
+
+func (c *CohereClient) Chat(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	body, err := buildChatRequest(chatRequest, contextMessages, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*chatResponse, error) {
+		httpResponse, err := c.send(ctx, http.MethodPost, c.BaseURL+"/chat", key, body, "application/json")
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer httpResponse.Body.Close()
+
+		llmResponse := &chatResponse{}
+
+		if err := json.NewDecoder(httpResponse.Body).Decode(llmResponse); err != nil {
+			return nil, fmt.Errorf("cohere response parse failed: %w", err)
+		}
+
+		return llmResponse, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildChatResult(chatRequest.ModelID, llmResponse), nil
+}
+
+func (c *CohereClient) ChatStream(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	body, err := buildChatRequest(chatRequest, contextMessages, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.Keys.Pick()
+
+	httpResponse, err := c.send(ctx, http.MethodPost, c.BaseURL+"/chat", key, body, "text/event-stream")
+
+	if err != nil {
+		c.Keys.Report(key, err)
+		return nil, err
+	}
+
+	defer httpResponse.Body.Close()
+
+	// Events are folded into a full response so tool calls, citations and usage come out as in Chat
+	accumulated := &chatResponse{}
+	scanner := bufio.NewScanner(httpResponse.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+
+		if !ok {
+			continue
+		}
+
+		event := &streamEvent{}
+
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), event); err != nil {
+			return nil, fmt.Errorf("cohere stream error: %w", err)
+		}
+
+		if text := accumulated.accumulate(event); text != "" {
+			if err := onDelta(text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		err = classifyTransport(c.Provider, err)
+		c.Keys.Report(key, err)
+
+		return nil, err
+	}
+
+	return buildChatResult(chatRequest.ModelID, accumulated), nil
+}
+
+func (c *CohereClient) Models(ctx context.Context) ([]*types.Model, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	raw, err := keypool.Do(c.Keys, func(key string) ([]byte, error) {
+		httpResponse, err := c.send(ctx, http.MethodGet, c.ModelEndpoint+"/models?page_size=1000", key, nil, "application/json")
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer httpResponse.Body.Close()
+
+		return io.ReadAll(httpResponse.Body)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("%s model list failed: %w", c.Provider, err)
+	}
+
+	return modellist.ParseCohere(raw, c.Provider)
+}
+
+// send issues an authenticated request and turns error replies into ProviderErrors
+func (c *CohereClient) send(ctx context.Context, method string, endpoint string, key string, body any, accept string) (*http.Response, error) {
+	httpRequest, err := newRequest(ctx, method, endpoint, key, body, accept)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpResponse, err := httpClient.Do(httpRequest)
+
+	if err != nil {
+		return nil, classifyTransport(c.Provider, err)
+	}
+
+	if httpResponse.StatusCode >= http.StatusBadRequest {
+		defer httpResponse.Body.Close()
+
+		return nil, classifyResponse(c.Provider, httpResponse)
+	}
+
+	return httpResponse, nil
+}
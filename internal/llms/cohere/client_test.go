@@ -0,0 +1,79 @@
+package cohere
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func TestRequestsAcceptTheirResponseFormat(t *testing.T) {
+	accepted := map[string]string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		accepted[request.Method+" "+request.URL.Path] = request.Header.Get("Accept")
+
+		switch request.URL.Path {
+		case "/models":
+			fmt.Fprint(response, `{"models":[{"name":"command-a-03-2025","endpoints":["chat"],"context_length":256000,"features":["tool_use","vision"]}]}`)
+		case "/chat":
+			if request.Header.Get("Accept") == "text/event-stream" {
+				response.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(response, "event: content-delta\ndata: {\"type\":\"content-delta\",\"delta\":{\"message\":{\"content\":{\"text\":\"hi\"}}}}\n\n")
+				fmt.Fprint(response, "event: message-end\ndata: {\"type\":\"message-end\",\"delta\":{\"finish_reason\":\"COMPLETE\"}}\n\n")
+				return
+			}
+
+			fmt.Fprint(response, `{"message":{"role":"assistant","content":[{"type":"text","text":"hi"}]},"finish_reason":"COMPLETE"}`)
+		}
+	}))
+
+	defer server.Close()
+
+	client := &CohereClient{
+		Provider:      "Cohere",
+		Keys:          keypool.New("Cohere", []string{"key"}),
+		BaseURL:       server.URL,
+		ModelEndpoint: server.URL,
+		Timeout:       5 * time.Second,
+	}
+
+	request := &types.ChatRequest{ModelID: "command-a-03-2025", Tokens: 16}
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := client.Chat(context.Background(), request, messages); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := accepted["POST /chat"]; got != "application/json" {
+		t.Errorf("chat Accept = %q, want application/json", got)
+	}
+
+	streamed := ""
+
+	if _, err := client.ChatStream(context.Background(), request, messages, func(delta string) error {
+		streamed += delta
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := accepted["POST /chat"]; got != "text/event-stream" || streamed != "hi" {
+		t.Errorf("stream Accept = %q and text %q, want text/event-stream and hi", got, streamed)
+	}
+
+	models, err := client.Models(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := accepted["GET /models"]; got != "application/json" || len(models) != 1 || !models[0].SupportsTools {
+		t.Errorf("models Accept = %q with models %+v", got, models)
+	}
+}
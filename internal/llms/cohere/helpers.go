@@ -0,0 +1,477 @@
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// Stream events are single JSON lines, citations with long sources can outgrow bufio's default limit
+const maxEventSize = 8 << 20
+
+// Message is a v2 chat message, content is a string or a list of text and image_url items
+type Message struct {
+	Role       string     `json:"role"`
+	Content    any        `json:"content,omitempty"`
+	ToolPlan   string     `json:"tool_plan,omitempty"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type contentItem struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *types.ImageURL `json:"image_url,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatRequestBody struct {
+	Model      string     `json:"model"`
+	Messages   []Message  `json:"messages"`
+	Documents  []document `json:"documents,omitempty"`
+	Tools      []tool     `json:"tools,omitempty"`
+	ToolChoice string     `json:"tool_choice,omitempty"`
+	MaxTokens  int64      `json:"max_tokens,omitempty"`
+	Stream     bool       `json:"stream,omitempty"`
+}
+
+type document struct {
+	ID   string            `json:"id,omitempty"`
+	Data map[string]string `json:"data"`
+}
+
+type tool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	FinishReason string `json:"finish_reason"`
+	Message      struct {
+		Content   []contentItem `json:"content"`
+		ToolPlan  string        `json:"tool_plan"`
+		ToolCalls []toolCall    `json:"tool_calls"`
+		Citations []citation    `json:"citations"`
+	} `json:"message"`
+	Usage usage `json:"usage"`
+}
+
+type usage struct {
+	BilledUnits  tokenCounts `json:"billed_units"`
+	Tokens       tokenCounts `json:"tokens"`
+	CachedTokens int64       `json:"cached_tokens"`
+}
+
+type tokenCounts struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+type citation struct {
+	Start   int              `json:"start"`
+	End     int              `json:"end"`
+	Text    string           `json:"text"`
+	Sources []citationSource `json:"sources"`
+}
+
+// citationSource points at a request document or at the output of a tool call, both free-form maps
+type citationSource struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Document   map[string]any `json:"document"`
+	ToolOutput map[string]any `json:"tool_output"`
+}
+
+// streamEvent is one v2 stream event, the message delta's shape depends on the event type
+type streamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Message      json.RawMessage `json:"message"`
+		FinishReason string          `json:"finish_reason"`
+		Usage        *usage          `json:"usage"`
+	} `json:"delta"`
+}
+
+type messageDelta struct {
+	Content struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	ToolPlan  string   `json:"tool_plan"`
+	ToolCalls toolCall `json:"tool_calls"`
+	Citations citation `json:"citations"`
+}
+
+// BuildCohereMessages converts canonical messages into v2 chat messages. Tool results,
+// which canonical messages carry as user parts, become separate tool messages and thinking is dropped
+func BuildCohereMessages(messages []types.Message) ([]Message, error) {
+	converted := make([]Message, 0, len(messages))
+
+	for _, msg := range messages {
+		parts, err := msg.Parts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		message := Message{Role: msg.Role}
+		items := make([]contentItem, 0, len(parts))
+
+		for _, part := range parts {
+			switch part.Type {
+			case "text":
+				items = append(items, contentItem{Type: "text", Text: part.Text})
+
+			case "image_url":
+				if part.ImageURL == nil {
+					return nil, fmt.Errorf("image_url missing image_url field")
+				}
+
+				items = append(items, contentItem{Type: "image_url", ImageURL: part.ImageURL})
+
+			// The chat layer inlines files already since Cohere reads them as text only
+			case "file":
+				if part.File == nil {
+					return nil, fmt.Errorf("file missing file field")
+				}
+
+				text, err := utils.AttachedFileText(part.File)
+
+				if err != nil {
+					return nil, err
+				}
+
+				items = append(items, contentItem{Type: "text", Text: text})
+
+			case "tool_call":
+				if part.ToolCall == nil {
+					return nil, fmt.Errorf("tool_call missing tool_call field")
+				}
+
+				call := toolCall{ID: part.ToolCall.ID, Type: "function"}
+				call.Function.Name = part.ToolCall.Name
+				call.Function.Arguments = string(part.ToolCall.Arguments)
+
+				if call.Function.Arguments == "" {
+					call.Function.Arguments = "{}"
+				}
+
+				message.ToolCalls = append(message.ToolCalls, call)
+
+			case "tool_result":
+				converted = append(converted, Message{Role: "tool", ToolCallID: part.ToolCallID, Content: part.ToolOutput()})
+			}
+		}
+
+		// Cohere takes the text next to tool calls as the tool plan
+		if len(message.ToolCalls) > 0 {
+			texts := make([]string, 0, len(items))
+
+			for _, item := range items {
+				texts = append(texts, item.Text)
+			}
+
+			message.ToolPlan = strings.Join(texts, "")
+			converted = append(converted, message)
+
+			continue
+		}
+
+		if len(items) == 0 {
+			continue
+		}
+
+		message.Content = items
+
+		// Plain text is sent as a string, system messages only take that form
+		if !slices.ContainsFunc(items, func(item contentItem) bool { return item.Type != "text" }) {
+			texts := make([]string, 0, len(items))
+
+			for _, item := range items {
+				texts = append(texts, item.Text)
+			}
+
+			message.Content = strings.Join(texts, "\n")
+		}
+
+		converted = append(converted, message)
+	}
+
+	return converted, nil
+}
+
+func buildChatRequest(chatRequest *types.ChatRequest, messages any, stream bool) (*chatRequestBody, error) {
+	msgs, ok := messages.([]Message)
+
+	if !ok {
+		return nil, fmt.Errorf("cohere client got a %T context, expected []Message", messages)
+	}
+
+	body := &chatRequestBody{
+		Model:     chatRequest.ModelID,
+		Messages:  make([]Message, 0, len(msgs)+1),
+		MaxTokens: chatRequest.Tokens,
+		Stream:    stream,
+	}
+
+	// Add system prompt if provided
+	if chatRequest.SystemPrompt != "" {
+		body.Messages = append(body.Messages, Message{Role: "system", Content: chatRequest.SystemPrompt})
+	}
+
+	body.Messages = append(body.Messages, msgs...)
+
+	for _, doc := range chatRequest.Documents {
+		data := map[string]string{"text": doc.Text}
+
+		if doc.Title != "" {
+			data["title"] = doc.Title
+		}
+
+		if doc.URL != "" {
+			data["url"] = doc.URL
+		}
+
+		body.Documents = append(body.Documents, document{ID: doc.ID, Data: data})
+	}
+
+	for _, definition := range chatRequest.Tools {
+		if len(definition.Parameters) > 0 && !json.Valid(definition.Parameters) {
+			return nil, fmt.Errorf("invalid parameters schema for tool %q", definition.Name)
+		}
+
+		// Cohere can't be pointed at one tool, so a named choice sends only that tool and requires a call
+		if !slices.Contains([]string{"", "auto", "none", "required"}, chatRequest.ToolChoice) && definition.Name != chatRequest.ToolChoice {
+			continue
+		}
+
+		entry := tool{Type: "function"}
+		entry.Function.Name = definition.Name
+		entry.Function.Description = definition.Description
+		entry.Function.Parameters = definition.Parameters
+
+		body.Tools = append(body.Tools, entry)
+	}
+
+	if len(body.Tools) == 0 {
+		return body, nil
+	}
+
+	switch chatRequest.ToolChoice {
+	case "", "auto":
+	case "none":
+		body.ToolChoice = "NONE"
+	default:
+		body.ToolChoice = "REQUIRED"
+	}
+
+	return body, nil
+}
+
+func buildChatResult(modelID string, response *chatResponse) *types.ChatResult {
+	var text strings.Builder
+
+	for _, item := range response.Message.Content {
+		if item.Type == "text" {
+			text.WriteString(item.Text)
+		}
+	}
+
+	result := &types.ChatResult{
+		Text:       text.String(),
+		Model:      modelID,
+		StopReason: response.FinishReason,
+		Usage: types.Usage{
+			InputTokens:  response.Usage.Tokens.InputTokens,
+			OutputTokens: response.Usage.Tokens.OutputTokens,
+			CachedTokens: response.Usage.CachedTokens,
+		},
+	}
+
+	// Older models only report billed units
+	if result.Usage.InputTokens == 0 && result.Usage.OutputTokens == 0 {
+		result.Usage.InputTokens = response.Usage.BilledUnits.InputTokens
+		result.Usage.OutputTokens = response.Usage.BilledUnits.OutputTokens
+	}
+
+	// A tool calling turn puts its reasoning in the tool plan rather than the content
+	if result.Text == "" && len(response.Message.ToolCalls) > 0 {
+		result.Text = response.Message.ToolPlan
+	}
+
+	for _, call := range response.Message.ToolCalls {
+		arguments := json.RawMessage(call.Function.Arguments)
+
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		} else if !json.Valid(arguments) {
+			arguments, _ = json.Marshal(call.Function.Arguments)
+		}
+
+		result.ToolCalls = append(result.ToolCalls, types.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: arguments})
+	}
+
+	for _, cited := range response.Message.Citations {
+		converted := types.Citation{Start: cited.Start, End: cited.End, Text: cited.Text, Sources: make([]types.Source, 0, len(cited.Sources))}
+
+		for _, source := range cited.Sources {
+			converted.Sources = append(converted.Sources, buildSource(source))
+		}
+
+		result.Citations = append(result.Citations, converted)
+	}
+
+	return result
+}
+
+// buildSource reads the usual fields out of a cited document, Cohere echoes back whatever the document held
+func buildSource(source citationSource) types.Source {
+	fields := source.Document
+
+	if fields == nil {
+		fields = source.ToolOutput
+	}
+
+	field := func(names ...string) string {
+		for _, name := range names {
+			if value, ok := fields[name].(string); ok && value != "" {
+				return value
+			}
+		}
+
+		return ""
+	}
+
+	converted := types.Source{
+		ID:      field("id"),
+		Title:   field("title"),
+		URL:     field("url"),
+		Snippet: field("snippet", "text"),
+	}
+
+	if converted.ID == "" {
+		converted.ID = source.ID
+	}
+
+	return converted
+}
+
+// accumulate folds a stream event into the response built so far and returns any new answer text
+func (r *chatResponse) accumulate(event *streamEvent) string {
+	delta := messageDelta{}
+
+	switch event.Type {
+	case "content-delta", "tool-plan-delta", "tool-call-start", "tool-call-delta", "citation-start":
+		if err := json.Unmarshal(event.Delta.Message, &delta); err != nil {
+			return ""
+		}
+	case "message-end":
+		r.FinishReason = event.Delta.FinishReason
+
+		if event.Delta.Usage != nil {
+			r.Usage = *event.Delta.Usage
+		}
+
+		return ""
+	default:
+		return ""
+	}
+
+	switch event.Type {
+	case "content-delta":
+		if len(r.Message.Content) == 0 {
+			r.Message.Content = []contentItem{{Type: "text"}}
+		}
+
+		r.Message.Content[0].Text += delta.Content.Text
+
+		return delta.Content.Text
+	case "tool-plan-delta":
+		r.Message.ToolPlan += delta.ToolPlan
+	case "tool-call-start":
+		r.Message.ToolCalls = append(r.Message.ToolCalls, delta.ToolCalls)
+	case "tool-call-delta":
+		if last := len(r.Message.ToolCalls) - 1; last >= 0 {
+			r.Message.ToolCalls[last].Function.Arguments += delta.ToolCalls.Function.Arguments
+		}
+	case "citation-start":
+		r.Message.Citations = append(r.Message.Citations, delta.Citations)
+	}
+
+	return ""
+}
+
+// newRequest builds a JSON request, accept is text/event-stream for streamed chats
+func newRequest(ctx context.Context, method string, endpoint string, key string, body any, accept string) (*http.Request, error) {
+	var reader io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", accept)
+
+	if key != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	return httpRequest, nil
+}
+
+// Cohere error bodies look like {"id": "...", "message": "..."}
+func classifyResponse(provider types.Provider, response *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+
+	body := struct {
+		Message string `json:"message"`
+	}{}
+
+	_ = json.Unmarshal(raw, &body)
+
+	message := body.Message
+
+	if message == "" {
+		message = strings.TrimSpace(string(raw))
+	}
+
+	err := fmt.Errorf("cohere api error %d: %s", response.StatusCode, message)
+
+	return llmerrors.Classify(provider, err, response.StatusCode, "", message, response.Header)
+}
+
+func classifyTransport(provider types.Provider, err error) error {
+	return llmerrors.ClassifyTransport(provider, err)
+}
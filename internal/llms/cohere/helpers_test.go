@@ -0,0 +1,220 @@
+package cohere
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func message(t *testing.T, role string, parts ...types.MessagePart) types.Message {
+	t.Helper()
+
+	content, err := json.Marshal(parts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Message{Role: role, Content: content}
+}
+
+// decodeMessages reads v2 messages back into canonical ones, the tool messages answering one
+// assistant turn become the tool_result parts of a single user message as the chat parser does
+func decodeMessages(t *testing.T, messages []Message) []types.Message {
+	t.Helper()
+
+	encoded, err := json.Marshal(messages)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var encodedMessages []struct {
+		Role       string          `json:"role"`
+		Content    json.RawMessage `json:"content"`
+		ToolPlan   string          `json:"tool_plan"`
+		ToolCalls  []toolCall      `json:"tool_calls"`
+		ToolCallID string          `json:"tool_call_id"`
+	}
+
+	if err := json.Unmarshal(encoded, &encodedMessages); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := make([]types.Message, 0, len(encodedMessages))
+	var pending []types.MessagePart
+
+	for _, encodedMessage := range encodedMessages {
+		if encodedMessage.Role == "tool" {
+			var text string
+
+			if err := json.Unmarshal(encodedMessage.Content, &text); err != nil {
+				t.Fatal(err)
+			}
+
+			pending = append(pending, types.MessagePart{Type: "tool_result", ToolCallID: encodedMessage.ToolCallID, Text: text})
+			continue
+		}
+
+		parts := append(pending, decodeContent(t, encodedMessage.Content)...)
+		pending = nil
+
+		if encodedMessage.ToolPlan != "" {
+			parts = append(parts, types.MessagePart{Type: "text", Text: encodedMessage.ToolPlan})
+		}
+
+		for _, call := range encodedMessage.ToolCalls {
+			parts = append(parts, types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: json.RawMessage(call.Function.Arguments),
+			}})
+		}
+
+		decoded = append(decoded, message(t, encodedMessage.Role, parts...))
+	}
+
+	if len(pending) > 0 {
+		decoded = append(decoded, message(t, "user", pending...))
+	}
+
+	return decoded
+}
+
+func decodeContent(t *testing.T, content json.RawMessage) []types.MessagePart {
+	t.Helper()
+
+	if len(content) == 0 {
+		return nil
+	}
+
+	var text string
+
+	if err := json.Unmarshal(content, &text); err == nil {
+		return []types.MessagePart{{Type: "text", Text: text}}
+	}
+
+	var parts []types.MessagePart
+
+	if err := json.Unmarshal(content, &parts); err != nil {
+		t.Fatal(err)
+	}
+
+	return parts
+}
+
+func assertMessages(t *testing.T, got []types.Message, want []types.Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		gotParts, err := got[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantParts, err := want[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got[i].Role != want[i].Role || !reflect.DeepEqual(gotParts, wantParts) {
+			gotJSON, _ := json.Marshal(gotParts)
+			wantJSON, _ := json.Marshal(wantParts)
+			t.Errorf("message %d:\n got %s %s\nwant %s %s", i, got[i].Role, gotJSON, want[i].Role, wantJSON)
+		}
+	}
+}
+
+func TestBuildCohereMessagesRoundTrip(t *testing.T) {
+	toolCall := &types.ToolCall{ID: "call_1", Name: "calculator", Arguments: json.RawMessage(`{"expression":"2+2"}`)}
+
+	cases := []struct {
+		name  string
+		role  string
+		parts []types.MessagePart
+	}{
+		{"text", "user", []types.MessagePart{{Type: "text", Text: "hello"}}},
+		{"system text", "system", []types.MessagePart{{Type: "text", Text: "be brief"}}},
+		{"data URL image", "user", []types.MessagePart{{Type: "text", Text: "see"}, {Type: "image_url", ImageURL: &types.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}}}},
+		{"remote image", "user", []types.MessagePart{{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://example.com/cat.png"}}}},
+		{"tool call with plan", "assistant", []types.MessagePart{{Type: "text", Text: "I will add"}, {Type: "tool_call", ToolCall: toolCall}}},
+		{"tool result", "user", []types.MessagePart{{Type: "tool_result", ToolCallID: "call_1", Text: "4"}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages := []types.Message{message(t, tc.role, tc.parts...)}
+
+			converted, err := BuildCohereMessages(messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeMessages(t, converted), messages)
+		})
+	}
+}
+
+func TestBuildCohereMessages(t *testing.T) {
+	text := "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("plain notes"))
+
+	cases := []struct {
+		name     string
+		messages []types.Message
+		want     string
+	}{
+		{
+			name: "tool results become tool messages ahead of the user's text",
+			messages: []types.Message{
+				{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"also"},{"type":"tool_result","tool_call_id":"a","text":"1"},{"type":"tool_result","tool_call_id":"b","text":"2"}]`)},
+			},
+			want: `[{"role":"tool","content":"1","tool_call_id":"a"},{"role":"tool","content":"2","tool_call_id":"b"},{"role":"user","content":"also"}]`,
+		},
+		{
+			name:     "thinking is dropped",
+			messages: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","text":"hmm","signature":"sig"},{"type":"text","text":"done"}]`)}},
+			want:     `[{"role":"assistant","content":"done"}]`,
+		},
+		{
+			name:     "files are sent as text",
+			messages: []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"file","file":{"filename":"notes.txt","file_data":"` + text + `"}}]`)}},
+			want:     `[{"role":"user","content":"Attached file notes.txt:\n\nplain notes"}]`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			converted, err := BuildCohereMessages(tc.messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded, err := json.Marshal(converted)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(encoded) != tc.want {
+				t.Errorf("got  %s\nwant %s", encoded, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildChatRequestRejectsForeignContext(t *testing.T) {
+	if _, err := buildChatRequest(&types.ChatRequest{ModelID: "command-a-03-2025"}, []string{"not messages"}, false); err == nil {
+		t.Fatal("expected an error for a context built by another client")
+	}
+}
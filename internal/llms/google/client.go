@@ -0,0 +1,226 @@
+package google
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// GoogleClient talks to the native Gemini REST API, generateContent for chat and models for listing
+type GoogleClient struct {
+	HTTPClient *http.Client
+	Keys       *keypool.Pool
+	BaseURL    string
+	Options    Options
+	Timeout    time.Duration
+}
+
+func (c *GoogleClient) KeyPool() *keypool.Pool {
+	return c.Keys
+}
+
+// Encode converts canonical messages into the Conversation Chat and ChatStream expect
+func (c *GoogleClient) Encode(messages []types.Message) (any, error) {
+	return BuildGoogleContents(messages)
+}
+
+// ReadsFiles is true for every Gemini model, files go out as inline data or file URIs
+func (c *GoogleClient) ReadsFiles(modelID string) bool {
+	return true
+}
+
+func (c *GoogleClient) Chat(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	body, err := buildGenerateRequest(chatRequest, contextMessages, c.Options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*generateResponse, error) {
+		httpResponse, err := c.send(ctx, http.MethodPost, c.modelURL(chatRequest.ModelID, "generateContent"), key, body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer httpResponse.Body.Close()
+
+		llmResponse := &generateResponse{}
+
+		if err := json.NewDecoder(httpResponse.Body).Decode(llmResponse); err != nil {
+			return nil, fmt.Errorf("google response parse failed: %w", err)
+		}
+
+		return llmResponse, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := blockedError(llmResponse); err != nil {
+		return nil, err
+	}
+
+	return buildChatResult(chatRequest.ModelID, llmResponse), nil
+}
+
+func (c *GoogleClient) ChatStream(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	body, err := buildGenerateRequest(chatRequest, contextMessages, c.Options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.Keys.Pick()
+
+	httpResponse, err := c.send(ctx, http.MethodPost, c.modelURL(chatRequest.ModelID, "streamGenerateContent")+"?alt=sse", key, body)
+
+	if err != nil {
+		c.Keys.Report(key, err)
+		return nil, err
+	}
+
+	defer httpResponse.Body.Close()
+
+	// Each event carries the newest parts, usage and finish reason arrive with the last ones
+	accumulated := &generateResponse{}
+	scanner := bufio.NewScanner(httpResponse.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+
+		if !ok {
+			continue
+		}
+
+		chunk := &generateResponse{}
+
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), chunk); err != nil {
+			return nil, fmt.Errorf("google stream error: %w", err)
+		}
+
+		accumulated.accumulate(chunk)
+
+		for _, part := range chunk.parts() {
+			if part.Text == "" || part.Thought {
+				continue
+			}
+
+			if err := onDelta(part.Text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		err = classifyTransport(err)
+		c.Keys.Report(key, err)
+
+		return nil, err
+	}
+
+	if err := blockedError(accumulated); err != nil {
+		return nil, err
+	}
+
+	return buildChatResult(chatRequest.ModelID, accumulated), nil
+}
+
+func (c *GoogleClient) Models(ctx context.Context) ([]*types.Model, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	models := make([]*types.Model, 0)
+	pageToken := ""
+
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		page, err := keypool.Do(c.Keys, func(key string) (*modelListResponse, error) {
+			httpResponse, err := c.send(ctx, http.MethodGet, c.BaseURL+"/models?"+query.Encode(), key, nil)
+
+			if err != nil {
+				return nil, err
+			}
+
+			defer httpResponse.Body.Close()
+
+			page := &modelListResponse{}
+
+			if err := json.NewDecoder(httpResponse.Body).Decode(page); err != nil {
+				return nil, fmt.Errorf("google model list parse failed: %w", err)
+			}
+
+			return page, nil
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("google model list failed: %w", err)
+		}
+
+		for _, model := range page.Models {
+			models = append(models, buildModel(model))
+		}
+
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+
+		pageToken = page.NextPageToken
+	}
+}
+
+// modelURL addresses a model method, IDs saved from the OpenAI shim still carry the "models/" prefix
+func (c *GoogleClient) modelURL(modelID string, method string) string {
+	return fmt.Sprintf("%s/models/%s:%s", c.BaseURL, url.PathEscape(strings.TrimPrefix(modelID, "models/")), method)
+}
+
+// send issues a request with the key in the x-goog-api-key header and turns error replies into ProviderErrors
+func (c *GoogleClient) send(ctx context.Context, method string, endpoint string, key string, body any) (*http.Response, error) {
+	httpRequest, err := newRequest(ctx, method, endpoint, key, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpResponse, err := httpClient.Do(httpRequest)
+
+	if err != nil {
+		return nil, classifyTransport(err)
+	}
+
+	if httpResponse.StatusCode >= http.StatusBadRequest {
+		defer httpResponse.Body.Close()
+
+		return nil, classifyResponse(httpResponse)
+	}
+
+	return httpResponse, nil
+}
@@ -0,0 +1,650 @@
+package google
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// Stream events can carry whole inline images, well past bufio's default line limit
+const maxEventSize = 32 << 20
+
+// Files uploaded through the Files API are referenced by a URI under this host
+const geminiFilesHost = "https://generativelanguage.googleapis.com/"
+
+var safetyCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+	"HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+// Options are the Gemini features the OpenAI shim couldn't reach, read from the environment
+type Options struct {
+	SafetyThreshold string // applied to every harm category, e.g. BLOCK_ONLY_HIGH or BLOCK_NONE
+	ThinkingBudget  *int   // thinking tokens per request, 0 turns thinking off and -1 lets the model decide
+	SearchGrounding bool   // ground answers with Google Search when the request brings no tools of its own
+}
+
+// Conversation is the Google context, contents plus any system messages pulled out of the conversation
+type Conversation struct {
+	Contents []Content
+	System   []string
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type generateRequest struct {
+	Contents          []Content         `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []safetySetting   `json:"safetySettings,omitempty"`
+	Tools             []tool            `json:"tools,omitempty"`
+	ToolConfig        *toolConfig       `json:"toolConfig,omitempty"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens int64           `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig  *thinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type thinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget"`
+}
+
+type safetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations,omitempty"`
+	GoogleSearch         *struct{}             `json:"googleSearch,omitempty"`
+}
+
+type functionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type toolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+type generateResponse struct {
+	Candidates     []candidate    `json:"candidates"`
+	UsageMetadata  usageMetadata  `json:"usageMetadata"`
+	ModelVersion   string         `json:"modelVersion"`
+	PromptFeedback promptFeedback `json:"promptFeedback"`
+}
+
+type candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount        int64 `json:"promptTokenCount"`
+	CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+	CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+	ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+}
+
+type promptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type modelListResponse struct {
+	Models        []modelInfo `json:"models"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+type modelInfo struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+// Google error bodies look like {"error": {"code": 429, "message": "...", "status": "RESOURCE_EXHAUSTED", "details": [...]}}
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type       string `json:"@type"`
+			Reason     string `json:"reason"`
+			RetryDelay string `json:"retryDelay"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// LoadOptions reads GOOGLE_SAFETY_THRESHOLD, GOOGLE_THINKING_BUDGET and GOOGLE_SEARCH_GROUNDING
+func LoadOptions() Options {
+	options := Options{
+		SafetyThreshold: strings.ToUpper(strings.TrimSpace(os.Getenv("GOOGLE_SAFETY_THRESHOLD"))),
+		SearchGrounding: os.Getenv("GOOGLE_SEARCH_GROUNDING") == "true",
+	}
+
+	if value := os.Getenv("GOOGLE_THINKING_BUDGET"); value != "" {
+		budget, err := strconv.Atoi(value)
+
+		if err != nil {
+			log.Printf("ignoring invalid GOOGLE_THINKING_BUDGET=%q err=%v", value, err)
+		} else {
+			options.ThinkingBudget = &budget
+		}
+	}
+
+	return options
+}
+
+// BuildGoogleContents converts canonical messages into Gemini contents. System messages are
+// kept apart since Gemini takes them as the system instruction
+func BuildGoogleContents(messages []types.Message) (*Conversation, error) {
+	contents := make([]Content, 0, len(messages))
+	systemTexts := make([]string, 0)
+
+	// Gemini answers a function call by name, canonical tool results only carry the call ID
+	toolNames := make(map[string]string)
+
+	for _, msg := range messages {
+		parts, err := msg.Parts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if msg.Role == "system" {
+			for _, part := range parts {
+				systemTexts = append(systemTexts, part.Text)
+			}
+
+			continue
+		}
+
+		converted := make([]Part, 0, len(parts))
+
+		for _, part := range parts {
+			switch part.Type {
+			case "text":
+				if part.Text != "" {
+					converted = append(converted, Part{Text: part.Text})
+				}
+
+			case "thinking":
+				// Gemini verifies earlier thoughts by their signature, unsigned ones are left out
+				if part.Signature != "" {
+					converted = append(converted, Part{Text: part.Text, Thought: true, ThoughtSignature: part.Signature})
+				}
+
+			case "image_url":
+				if part.ImageURL == nil {
+					return nil, fmt.Errorf("image_url missing image_url field")
+				}
+
+				filePart, err := buildFilePart(part.ImageURL.URL)
+
+				if err != nil {
+					return nil, err
+				}
+
+				converted = append(converted, filePart)
+
+			case "file":
+				if part.File == nil {
+					return nil, fmt.Errorf("file missing file field")
+				}
+
+				converted = append(converted, buildDocumentPart(part.File))
+
+			case "tool_call":
+				if part.ToolCall == nil {
+					return nil, fmt.Errorf("tool_call missing tool_call field")
+				}
+
+				toolNames[part.ToolCall.ID] = part.ToolCall.Name
+
+				args := part.ToolCall.Arguments
+
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+
+				converted = append(converted, Part{
+					FunctionCall:     &FunctionCall{Name: part.ToolCall.Name, Args: args},
+					ThoughtSignature: part.ToolCall.Signature,
+				})
+
+			case "tool_result":
+				name, ok := toolNames[part.ToolCallID]
+
+				if !ok {
+					return nil, fmt.Errorf("tool_result %q has no matching tool_call", part.ToolCallID)
+				}
+
+				// Gemini reads a failed call from the error field of the response
+				response := map[string]any{"result": part.Text}
+
+				if part.IsError {
+					response = map[string]any{"error": part.Text}
+				}
+
+				converted = append(converted, Part{FunctionResponse: &FunctionResponse{
+					Name:     name,
+					Response: response,
+				}})
+			}
+		}
+
+		if len(converted) == 0 {
+			continue
+		}
+
+		role := "user"
+
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		// Keep turns alternating, back to back messages from one side become a single turn
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == role {
+			contents[last].Parts = append(contents[last].Parts, converted...)
+			continue
+		}
+
+		contents = append(contents, Content{Role: role, Parts: converted})
+	}
+
+	return &Conversation{Contents: contents, System: systemTexts}, nil
+}
+
+// buildFilePart inlines data URLs and passes Files API and Cloud Storage URIs by reference, Gemini
+// doesn't fetch arbitrary web URLs
+func buildFilePart(url string) (Part, error) {
+	if isGeminiFileURI(url) {
+		return Part{FileData: &FileData{MimeType: mime.TypeByExtension(path.Ext(url)), FileURI: url}}, nil
+	}
+
+	if !strings.HasPrefix(url, "data:") {
+		return Part{}, fmt.Errorf("gemini can't fetch image %s, send it as a base64 data URL or a Files API URI", url)
+	}
+
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+
+	if !ok {
+		return Part{}, fmt.Errorf("invalid data URL")
+	}
+
+	return Part{InlineData: &Blob{MimeType: strings.TrimSuffix(meta, ";base64"), Data: data}}, nil
+}
+
+func isGeminiFileURI(url string) bool {
+	if strings.HasPrefix(url, geminiFilesHost) {
+		return strings.Contains(url, "/files/")
+	}
+
+	return strings.HasPrefix(url, "gs://")
+}
+
+// buildDocumentPart sends file data inline and treats a file ID as the URI of a file uploaded to Gemini
+func buildDocumentPart(file *types.File) Part {
+	if file.FileData == "" {
+		return Part{FileData: &FileData{MimeType: mime.TypeByExtension(path.Ext(file.Filename)), FileURI: file.FileID}}
+	}
+
+	// Validated when the context was parsed
+	mediaType, _, _ := utils.ParseDataURL(file.FileData)
+	_, data, _ := strings.Cut(file.FileData, ",")
+
+	return Part{InlineData: &Blob{MimeType: mediaType, Data: data}}
+}
+
+func buildGenerateRequest(chatRequest *types.ChatRequest, messages any, options Options) (*generateRequest, error) {
+	conversation, ok := messages.(*Conversation)
+
+	if !ok {
+		return nil, fmt.Errorf("google client got a %T context, expected *Conversation", messages)
+	}
+
+	body := &generateRequest{
+		Contents:         conversation.Contents,
+		GenerationConfig: &generationConfig{MaxOutputTokens: chatRequest.Tokens},
+	}
+
+	systemTexts := conversation.System
+
+	// Add system prompt if provided
+	if chatRequest.SystemPrompt != "" {
+		systemTexts = append([]string{chatRequest.SystemPrompt}, systemTexts...)
+	}
+
+	if len(systemTexts) > 0 {
+		body.SystemInstruction = &Content{Parts: []Part{{Text: strings.Join(systemTexts, "\n\n")}}}
+	}
+
+	if options.ThinkingBudget != nil {
+		body.GenerationConfig.ThinkingConfig = &thinkingConfig{ThinkingBudget: *options.ThinkingBudget}
+	}
+
+	if options.SafetyThreshold != "" {
+		for _, category := range safetyCategories {
+			body.SafetySettings = append(body.SafetySettings, safetySetting{Category: category, Threshold: options.SafetyThreshold})
+		}
+	}
+
+	if len(chatRequest.Tools) == 0 {
+		// Search grounding can't be combined with function calling
+		if options.SearchGrounding {
+			body.Tools = []tool{{GoogleSearch: &struct{}{}}}
+		}
+
+		return body, nil
+	}
+
+	declarations := make([]functionDeclaration, 0, len(chatRequest.Tools))
+
+	for _, definition := range chatRequest.Tools {
+		if len(definition.Parameters) > 0 && !json.Valid(definition.Parameters) {
+			return nil, fmt.Errorf("invalid parameters schema for tool %q", definition.Name)
+		}
+
+		declarations = append(declarations, functionDeclaration{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Parameters:  definition.Parameters,
+		})
+	}
+
+	body.Tools = []tool{{FunctionDeclarations: declarations}}
+	body.ToolConfig = buildToolConfig(chatRequest.ToolChoice)
+
+	return body, nil
+}
+
+func buildToolConfig(choice string) *toolConfig {
+	config := &toolConfig{}
+
+	switch choice {
+	case "", "auto":
+		config.FunctionCallingConfig.Mode = "AUTO"
+	case "none":
+		config.FunctionCallingConfig.Mode = "NONE"
+	case "required":
+		config.FunctionCallingConfig.Mode = "ANY"
+	default:
+		config.FunctionCallingConfig.Mode = "ANY"
+		config.FunctionCallingConfig.AllowedFunctionNames = []string{choice}
+	}
+
+	return config
+}
+
+func buildChatResult(modelID string, response *generateResponse) *types.ChatResult {
+	var text strings.Builder
+	var toolCalls []types.ToolCall
+
+	// Join every answer part and collect function calls, skipping thoughts
+	for _, part := range response.parts() {
+		switch {
+		case part.FunctionCall != nil:
+			arguments := part.FunctionCall.Args
+
+			if len(arguments) == 0 {
+				arguments = json.RawMessage("{}")
+			}
+
+			// Gemini rarely sends call IDs, tool results are matched back by ID so one is made up
+			id := part.FunctionCall.ID
+
+			if id == "" {
+				id = newCallID()
+			}
+
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID:        id,
+				Name:      part.FunctionCall.Name,
+				Arguments: arguments,
+				Signature: part.ThoughtSignature,
+			})
+		case part.Text != "" && !part.Thought:
+			text.WriteString(part.Text)
+		}
+	}
+
+	result := &types.ChatResult{
+		Text:      text.String(),
+		ToolCalls: toolCalls,
+		Model:     strings.TrimPrefix(modelID, "models/"),
+		Usage: types.Usage{
+			InputTokens: response.UsageMetadata.PromptTokenCount,
+			// Thinking tokens are billed as output
+			OutputTokens: response.UsageMetadata.CandidatesTokenCount + response.UsageMetadata.ThoughtsTokenCount,
+			CachedTokens: response.UsageMetadata.CachedContentTokenCount,
+		},
+	}
+
+	if response.ModelVersion != "" {
+		result.Model = response.ModelVersion
+	}
+
+	if len(response.Candidates) > 0 {
+		result.StopReason = response.Candidates[0].FinishReason
+	}
+
+	return result
+}
+
+func buildModel(info modelInfo) *types.Model {
+	id := strings.TrimPrefix(info.Name, "models/")
+
+	name := info.DisplayName
+
+	if name == "" {
+		name = id
+	}
+
+	model := &types.Model{
+		ID:       id,
+		Name:     name,
+		Provider: utils.GOOGLE,
+		// Embedding and other non chat models are listed too but can't be chatted with
+		Enabled: utils.IsModelAllowed(utils.GOOGLE, id) && slices.Contains(info.SupportedGenerationMethods, "generateContent"),
+	}
+
+	model.ContextLength = info.InputTokenLimit
+	model.MaxOutputTokens = info.OutputTokenLimit
+
+	return model
+}
+
+// accumulate folds a stream event into the response built so far
+func (r *generateResponse) accumulate(chunk *generateResponse) {
+	if len(chunk.Candidates) > 0 {
+		if len(r.Candidates) == 0 {
+			r.Candidates = []candidate{{Content: Content{Role: "model"}}}
+		}
+
+		r.Candidates[0].Content.Parts = append(r.Candidates[0].Content.Parts, chunk.Candidates[0].Content.Parts...)
+
+		if chunk.Candidates[0].FinishReason != "" {
+			r.Candidates[0].FinishReason = chunk.Candidates[0].FinishReason
+		}
+	}
+
+	// Usage counts are running totals, the newest replaces the last
+	if chunk.UsageMetadata != (usageMetadata{}) {
+		r.UsageMetadata = chunk.UsageMetadata
+	}
+
+	if chunk.ModelVersion != "" {
+		r.ModelVersion = chunk.ModelVersion
+	}
+
+	if chunk.PromptFeedback.BlockReason != "" {
+		r.PromptFeedback = chunk.PromptFeedback
+	}
+}
+
+func (r *generateResponse) parts() []Part {
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+
+	return r.Candidates[0].Content.Parts
+}
+
+// blockedError reports a prompt Gemini refused outright, which comes back as a 200 without candidates
+func blockedError(response *generateResponse) error {
+	reason := response.PromptFeedback.BlockReason
+
+	if reason == "" {
+		return nil
+	}
+
+	return &llmerrors.ProviderError{
+		Kind:       llmerrors.KindContentFiltered,
+		Provider:   utils.GOOGLE,
+		StatusCode: http.StatusOK,
+		Message:    fmt.Sprintf("prompt blocked by Gemini safety filters (%s)", strings.ToLower(reason)),
+		Err:        errors.New(reason),
+	}
+}
+
+func newRequest(ctx context.Context, method string, endpoint string, key string, body any) (*http.Request, error) {
+	var reader io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	if key != "" {
+		httpRequest.Header.Set("x-goog-api-key", key)
+	}
+
+	return httpRequest, nil
+}
+
+func classifyResponse(response *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+
+	body := errorResponse{}
+	_ = json.Unmarshal(raw, &body)
+
+	message := body.Error.Message
+
+	if message == "" {
+		message = strings.TrimSpace(string(raw))
+	}
+
+	errorType := body.Error.Status
+	var retryDelay time.Duration
+
+	for _, detail := range body.Error.Details {
+		if detail.Reason != "" {
+			errorType += " " + detail.Reason
+		}
+
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil {
+			retryDelay = delay
+		}
+	}
+
+	err := fmt.Errorf("google api error %d: %s", response.StatusCode, message)
+	providerErr := llmerrors.Classify(utils.GOOGLE, err, response.StatusCode, errorType, message, response.Header)
+
+	if retryDelay > 0 {
+		providerErr.RetryAfter = retryDelay
+	}
+
+	// Per minute limits also say "exceeded your current quota", a retry delay marks them as short lived
+	if retryDelay > 0 && response.StatusCode == http.StatusTooManyRequests {
+		providerErr.Kind = llmerrors.KindRateLimited
+	}
+
+	return providerErr
+}
+
+func classifyTransport(err error) error {
+	return llmerrors.ClassifyTransport(utils.GOOGLE, err)
+}
+
+func newCallID() string {
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+
+	return "call_" + hex.EncodeToString(random)
+}
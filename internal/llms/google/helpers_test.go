@@ -0,0 +1,331 @@
+package google
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func message(t *testing.T, role string, parts ...types.MessagePart) types.Message {
+	t.Helper()
+
+	content, err := json.Marshal(parts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Message{Role: role, Content: content}
+}
+
+// decodeContents reads Gemini contents back into canonical messages. Gemini pairs calls with responses
+// by function name, so the fixtures name their call IDs call_<function> and decoding restores them that way
+func decodeContents(t *testing.T, conversation *Conversation) []types.Message {
+	t.Helper()
+
+	encoded, err := json.Marshal(conversation.Contents)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []Content
+
+	if err := json.Unmarshal(encoded, &contents); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := make([]types.Message, 0, len(contents))
+
+	for _, content := range contents {
+		parts := make([]types.MessagePart, 0, len(content.Parts))
+
+		for _, part := range content.Parts {
+			parts = append(parts, decodePart(t, part))
+		}
+
+		role := "user"
+
+		if content.Role == "model" {
+			role = "assistant"
+		}
+
+		decoded = append(decoded, message(t, role, parts...))
+	}
+
+	return decoded
+}
+
+func decodePart(t *testing.T, part Part) types.MessagePart {
+	t.Helper()
+
+	switch {
+	case part.Thought:
+		return types.MessagePart{Type: "thinking", Text: part.Text, Signature: part.ThoughtSignature}
+	case part.Text != "":
+		return types.MessagePart{Type: "text", Text: part.Text}
+	case part.InlineData != nil:
+		url := "data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data
+
+		if strings.HasPrefix(part.InlineData.MimeType, "image/") {
+			return types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: url}}
+		}
+
+		return types.MessagePart{Type: "file", File: &types.File{FileData: url}}
+	case part.FileData != nil:
+		if strings.HasPrefix(part.FileData.MimeType, "image/") {
+			return types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: part.FileData.FileURI}}
+		}
+
+		return types.MessagePart{Type: "file", File: &types.File{FileID: part.FileData.FileURI}}
+	case part.FunctionCall != nil:
+		return types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{
+			ID:        "call_" + part.FunctionCall.Name,
+			Name:      part.FunctionCall.Name,
+			Arguments: part.FunctionCall.Args,
+			Signature: part.ThoughtSignature,
+		}}
+	case part.FunctionResponse != nil:
+		if failure, ok := part.FunctionResponse.Response["error"].(string); ok {
+			return types.MessagePart{Type: "tool_result", ToolCallID: "call_" + part.FunctionResponse.Name, Text: failure, IsError: true}
+		}
+
+		result, _ := part.FunctionResponse.Response["result"].(string)
+
+		return types.MessagePart{Type: "tool_result", ToolCallID: "call_" + part.FunctionResponse.Name, Text: result}
+	}
+
+	t.Fatalf("unexpected part %+v", part)
+
+	return types.MessagePart{}
+}
+
+func assertMessages(t *testing.T, got []types.Message, want []types.Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		gotParts, err := got[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantParts, err := want[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got[i].Role != want[i].Role || !reflect.DeepEqual(gotParts, wantParts) {
+			gotJSON, _ := json.Marshal(gotParts)
+			wantJSON, _ := json.Marshal(wantParts)
+			t.Errorf("message %d:\n got %s %s\nwant %s %s", i, got[i].Role, gotJSON, want[i].Role, wantJSON)
+		}
+	}
+}
+
+func TestBuildGoogleContentsRoundTrip(t *testing.T) {
+	pdf := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 fixture"))
+	text := "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("plain notes"))
+	toolCall := types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{ID: "call_calculator", Name: "calculator", Arguments: json.RawMessage(`{"expression":"2+2"}`)}}
+
+	cases := []struct {
+		name     string
+		messages func(t *testing.T) []types.Message
+	}{
+		{"text", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "text", Text: "hello"})}
+		}},
+		{"data URL image", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}})}
+		}},
+		{"uploaded image", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://generativelanguage.googleapis.com/v1beta/files/cat.png"}})}
+		}},
+		{"pdf file", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "file", File: &types.File{FileData: pdf}})}
+		}},
+		{"text file", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "file", File: &types.File{FileData: text}})}
+		}},
+		{"uploaded file", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "file", File: &types.File{FileID: "https://generativelanguage.googleapis.com/v1beta/files/abc"}})}
+		}},
+		{"signed thinking", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "assistant", types.MessagePart{Type: "thinking", Text: "adding", Signature: "c2ln"}, types.MessagePart{Type: "text", Text: "4"})}
+		}},
+		{"Cloud Storage image", func(t *testing.T) []types.Message {
+			return []types.Message{message(t, "user", types.MessagePart{Type: "image_url", ImageURL: &types.ImageURL{URL: "gs://bucket/cat.png"}})}
+		}},
+		{"signed tool call", func(t *testing.T) []types.Message {
+			signed := *toolCall.ToolCall
+			signed.Signature = "c2lnbmVk"
+
+			return []types.Message{
+				message(t, "assistant", types.MessagePart{Type: "tool_call", ToolCall: &signed}),
+				message(t, "user", types.MessagePart{Type: "tool_result", ToolCallID: "call_calculator", Text: "4"}),
+			}
+		}},
+		{"tool call and result", func(t *testing.T) []types.Message {
+			return []types.Message{
+				message(t, "assistant", toolCall),
+				message(t, "user", types.MessagePart{Type: "tool_result", ToolCallID: "call_calculator", Text: "4"}),
+			}
+		}},
+		{"tool call and failed result", func(t *testing.T) []types.Message {
+			return []types.Message{
+				message(t, "assistant", toolCall),
+				message(t, "user", types.MessagePart{Type: "tool_result", ToolCallID: "call_calculator", Text: "division by zero", IsError: true}),
+			}
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages := tc.messages(t)
+
+			conversation, err := BuildGoogleContents(messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeContents(t, conversation), messages)
+		})
+	}
+}
+
+func TestBuildGoogleContents(t *testing.T) {
+	cases := []struct {
+		name       string
+		messages   []types.Message
+		want       []types.Message
+		wantSystem []string
+	}{
+		{
+			name: "system messages become the system instruction",
+			messages: []types.Message{
+				{Role: "system", Content: json.RawMessage(`"be brief"`)},
+				{Role: "user", Content: json.RawMessage(`"hi"`)},
+			},
+			want:       []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"hi"}]`)}},
+			wantSystem: []string{"be brief"},
+		},
+		{
+			name: "unsigned thinking is dropped",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","text":"hmm"},{"type":"text","text":"done"}]`)},
+			},
+			want: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"text","text":"done"}]`)}},
+		},
+		{
+			name: "parallel tool results share one user turn",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"call_a","name":"a","arguments":{}}},{"type":"tool_call","tool_call":{"id":"call_b","name":"b","arguments":{}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"call_a","text":"1"}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"call_b","text":"2"}]`)},
+			},
+			want: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"call_a","name":"a","arguments":{}}},{"type":"tool_call","tool_call":{"id":"call_b","name":"b","arguments":{}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"call_a","text":"1"},{"type":"tool_result","tool_call_id":"call_b","text":"2"}]`)},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conversation, err := BuildGoogleContents(tc.messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeContents(t, conversation), tc.want)
+
+			if len(tc.wantSystem) > 0 && !reflect.DeepEqual(conversation.System, tc.wantSystem) {
+				t.Errorf("system = %q, want %q", conversation.System, tc.wantSystem)
+			}
+		})
+	}
+}
+
+func TestBuildGoogleContentsRejectsUnmatchedToolResults(t *testing.T) {
+	messages := []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"call_x","text":"1"}]`)}}
+
+	if _, err := BuildGoogleContents(messages); err == nil {
+		t.Fatal("expected an error for a tool result without its call")
+	}
+}
+
+func TestBuildGenerateRequestRejectsForeignContext(t *testing.T) {
+	if _, err := buildGenerateRequest(&types.ChatRequest{ModelID: "gemini-2.5-flash"}, []string{"not contents"}, Options{}); err == nil {
+		t.Fatal("expected an error for a context built by another client")
+	}
+}
+
+func TestBuildGoogleContentsRejectsWebImages(t *testing.T) {
+	for _, url := range []string{"https://example.com/cat.png", "http://example.com/cat.png", "https://generativelanguage.googleapis.com/v1beta/models"} {
+		messages := []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"image_url","image_url":{"url":"` + url + `"}}]`)}}
+
+		if _, err := BuildGoogleContents(messages); err == nil {
+			t.Errorf("%s: expected an error, Gemini can't fetch web URLs", url)
+		}
+	}
+}
+
+func TestBuildChatResultKeepsCallSignatures(t *testing.T) {
+	var response generateResponse
+
+	err := json.Unmarshal([]byte(`{"candidates":[{"content":{"role":"model","parts":[
+		{"text":"planning","thought":true},
+		{"functionCall":{"name":"calculator","args":{"expression":"2+2"}},"thoughtSignature":"c2lnbmVk"},
+		{"functionCall":{"id":"call_2","name":"clock"}}]},"finishReason":"STOP"}]}`), &response)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := buildChatResult("gemini-2.5-flash", &response)
+
+	if len(result.ToolCalls) != 2 {
+		t.Fatalf("got %d tool calls, want 2", len(result.ToolCalls))
+	}
+
+	if got := result.ToolCalls[0]; got.Signature != "c2lnbmVk" || got.Name != "calculator" || string(got.Arguments) != `{"expression":"2+2"}` {
+		t.Errorf("first call = %+v, want the calculator call with its signature", got)
+	}
+
+	if got := result.ToolCalls[1]; got.Signature != "" || got.ID != "call_2" || string(got.Arguments) != "{}" {
+		t.Errorf("second call = %+v, want an unsigned clock call with empty arguments", got)
+	}
+
+	// The calls go back with their signatures on the next turn
+	content, err := json.Marshal([]types.MessagePart{
+		{Type: "tool_call", ToolCall: &result.ToolCalls[0]},
+		{Type: "tool_call", ToolCall: &result.ToolCalls[1]},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conversation, err := BuildGoogleContents([]types.Message{{Role: "assistant", Content: content}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := conversation.Contents[0].Parts
+
+	if parts[0].ThoughtSignature != "c2lnbmVk" || parts[1].ThoughtSignature != "" {
+		t.Errorf("signatures sent back = %q, %q", parts[0].ThoughtSignature, parts[1].ThoughtSignature)
+	}
+}
@@ -0,0 +1,196 @@
+package keypool
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	DefaultCooldown          = 10 * time.Minute
+	DefaultRateLimitCooldown = 30 * time.Second
+)
+
+// Pool spreads requests for one provider across its keys round-robin and benches keys
+// the provider rejects, auth and quota failures for the cooldown and rate limits until Retry-After
+type Pool struct {
+	provider types.Provider
+	mutex    sync.Mutex
+	entries  []*entry
+	next     int
+	cooldown time.Duration
+}
+
+type entry struct {
+	key          string
+	benchedUntil time.Time
+}
+
+func New(provider types.Provider, keys []string) *Pool {
+	pool := &Pool{provider: provider, cooldown: readCooldown()}
+
+	for _, key := range keys {
+		pool.entries = append(pool.entries, &entry{key: key})
+	}
+
+	// Keyless providers still get a single empty key so callers never special case them
+	if len(pool.entries) == 0 {
+		pool.entries = append(pool.entries, &entry{})
+	}
+
+	return pool
+}
+
+// Inherit copies the bench times of keys this pool shares with previous, so a rebuilt
+// client doesn't send requests with keys the provider just rejected
+func (p *Pool) Inherit(previous *Pool) {
+	if previous == nil || previous == p {
+		return
+	}
+
+	previous.mutex.Lock()
+	benched := make(map[string]time.Time, len(previous.entries))
+
+	for _, candidate := range previous.entries {
+		benched[candidate.key] = candidate.benchedUntil
+	}
+
+	previous.mutex.Unlock()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, candidate := range p.entries {
+		candidate.benchedUntil = benched[candidate.key]
+	}
+}
+
+// Pick returns the next key that isn't benched. When every key is benched the one
+// closest to coming back is used rather than failing the request outright
+func (p *Pool) Pick() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	soonest := p.entries[0]
+
+	for range p.entries {
+		candidate := p.entries[p.next%len(p.entries)]
+		p.next = (p.next + 1) % len(p.entries)
+
+		if !now.Before(candidate.benchedUntil) {
+			return candidate.key
+		}
+
+		if candidate.benchedUntil.Before(soonest.benchedUntil) {
+			soonest = candidate
+		}
+	}
+
+	return soonest.key
+}
+
+// Do runs call with the next key, moving on to another key straight away when the provider
+// rejects or rate limits the one used. It gives up once no unbenched key is left
+func Do[T any](p *Pool, call func(key string) (T, error)) (T, error) {
+	var result T
+	var err error
+
+	for attempt := 0; attempt < len(p.entries); attempt++ {
+		key := p.Pick()
+		result, err = call(key)
+
+		if err == nil || !p.Report(key, err) || p.available() == 0 {
+			return result, err
+		}
+	}
+
+	return result, err
+}
+
+// Report benches key when err shows the provider won't accept it for a while, reporting whether it did
+func (p *Pool) Report(key string, err error) bool {
+	var providerErr *llmerrors.ProviderError
+
+	if err == nil || !errors.As(err, &providerErr) {
+		return false
+	}
+
+	var bench time.Duration
+
+	switch providerErr.Kind {
+	case llmerrors.KindAuth, llmerrors.KindQuotaExceeded:
+		bench = p.cooldown
+	case llmerrors.KindRateLimited:
+		bench = providerErr.RetryAfter
+
+		if bench <= 0 {
+			bench = DefaultRateLimitCooldown
+		}
+	default:
+		return false
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for index, candidate := range p.entries {
+		if candidate.key != key {
+			continue
+		}
+
+		candidate.benchedUntil = time.Now().Add(bench)
+
+		// Keys are logged by position only
+		log.Printf("benched key provider=%q key=%d/%d kind=%s for=%s", p.provider, index+1, len(p.entries), providerErr.Kind, bench)
+
+		return true
+	}
+
+	return false
+}
+
+// Stats reports how many keys the pool holds and how many are benched right now
+func (p *Pool) Stats() (total int, benched int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, candidate := range p.entries {
+		if now.Before(candidate.benchedUntil) {
+			benched++
+		}
+	}
+
+	return len(p.entries), benched
+}
+
+func (p *Pool) available() int {
+	total, benched := p.Stats()
+
+	return total - benched
+}
+
+// readCooldown reads KEY_COOLDOWN, the bench time after an auth or quota failure
+func readCooldown() time.Duration {
+	value := os.Getenv("KEY_COOLDOWN")
+
+	if value == "" {
+		return DefaultCooldown
+	}
+
+	cooldown, err := time.ParseDuration(value)
+
+	if err != nil {
+		log.Printf("ignoring invalid KEY_COOLDOWN=%q err=%v", value, err)
+		return DefaultCooldown
+	}
+
+	return cooldown
+}
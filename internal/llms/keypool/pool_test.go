@@ -0,0 +1,112 @@
+package keypool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
+)
+
+func providerError(kind llmerrors.Kind) error {
+	return &llmerrors.ProviderError{Kind: kind, Provider: "Test", Message: string(kind)}
+}
+
+func TestPickRoundRobinsAndSkipsBenchedKeys(t *testing.T) {
+	pool := New("Test", []string{"a", "b", "c"})
+
+	for _, want := range []string{"a", "b", "c", "a"} {
+		if got := pool.Pick(); got != want {
+			t.Fatalf("Pick() = %q, want %q", got, want)
+		}
+	}
+
+	if !pool.Report("b", providerError(llmerrors.KindAuth)) {
+		t.Fatal("an auth failure didn't bench the key")
+	}
+
+	for _, want := range []string{"c", "a", "c"} {
+		if got := pool.Pick(); got != want {
+			t.Fatalf("Pick() with b benched = %q, want %q", got, want)
+		}
+	}
+
+	if total, benched := pool.Stats(); total != 3 || benched != 1 {
+		t.Errorf("Stats() = %d, %d, want 3 keys with 1 benched", total, benched)
+	}
+}
+
+func TestReportBenchesOnlyRejectedKeys(t *testing.T) {
+	t.Setenv("KEY_COOLDOWN", "1h")
+
+	pool := New("Test", []string{"a", "b"})
+
+	if pool.Report("a", providerError(llmerrors.KindOverloaded)) || pool.Report("a", errors.New("plain failure")) || pool.Report("a", nil) {
+		t.Fatal("a failure that isn't the key's fault benched it")
+	}
+
+	rateLimited := &llmerrors.ProviderError{Kind: llmerrors.KindRateLimited, RetryAfter: time.Minute}
+
+	if !pool.Report("a", rateLimited) || !pool.Report("b", providerError(llmerrors.KindQuotaExceeded)) {
+		t.Fatal("rate limit and quota failures should bench the key")
+	}
+
+	// With every key benched the one back soonest is still handed out
+	if got := pool.Pick(); got != "a" {
+		t.Errorf("Pick() with all keys benched = %q, want the rate limited key", got)
+	}
+}
+
+func TestDoMovesToTheNextKey(t *testing.T) {
+	pool := New("Test", []string{"revoked", "good"})
+
+	var tried []string
+
+	result, err := Do(pool, func(key string) (string, error) {
+		tried = append(tried, key)
+
+		if key == "revoked" {
+			return "", providerError(llmerrors.KindAuth)
+		}
+
+		return "ok from " + key, nil
+	})
+
+	if err != nil || result != "ok from good" || len(tried) != 2 {
+		t.Fatalf("Do() = %q, %v after trying %v, want the good key to answer", result, err, tried)
+	}
+
+	// Once every key is rejected the last error is returned instead of looping
+	tried = nil
+
+	_, err = Do(pool, func(key string) (string, error) {
+		tried = append(tried, key)
+		return "", providerError(llmerrors.KindQuotaExceeded)
+	})
+
+	if err == nil || len(tried) != 1 {
+		t.Errorf("Do() with no usable keys = %v after trying %v, want one attempt and its error", err, tried)
+	}
+}
+
+func TestInheritKeepsBenchTimesOfSharedKeys(t *testing.T) {
+	previous := New("Test", []string{"a", "b"})
+	previous.Report("a", providerError(llmerrors.KindAuth))
+
+	rebuilt := New("Test", []string{"a", "c"})
+	rebuilt.Inherit(previous)
+
+	if _, benched := rebuilt.Stats(); benched != 1 {
+		t.Fatalf("rebuilt pool has %d benched keys, want the shared key still benched", benched)
+	}
+
+	if got := rebuilt.Pick(); got != "c" {
+		t.Errorf("Pick() after a rebuild = %q, want the key that wasn't rejected", got)
+	}
+}
+
+func TestKeylessProviders(t *testing.T) {
+	if got := New("Test", nil).Pick(); got != "" {
+		t.Errorf("Pick() on a keyless pool = %q, want an empty key", got)
+	}
+}
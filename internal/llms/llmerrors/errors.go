@@ -0,0 +1,192 @@
+package llmerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type Kind string
+
+const (
+	KindAuth            Kind = "auth"
+	KindQuotaExceeded   Kind = "quota_exceeded"
+	KindRateLimited     Kind = "rate_limited"
+	KindOverloaded      Kind = "overloaded"
+	KindContextLength   Kind = "context_length_exceeded"
+	KindContentFiltered Kind = "content_filtered"
+	KindBadRequest      Kind = "bad_request"
+	KindNotFound        Kind = "not_found"
+	KindNetwork         Kind = "network"
+	KindCanceled        Kind = "canceled"
+	KindUnknown         Kind = "provider_error"
+)
+
+// ProviderError is the provider-neutral form of a failed upstream call
+type ProviderError struct {
+	Kind       Kind
+	Provider   types.Provider
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s %s error (status %d): %s", e.Provider, e.Kind, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether repeating the same request could succeed
+func (k Kind) Retryable() bool {
+	return k == KindRateLimited || k == KindOverloaded || k == KindNetwork
+}
+
+func IsRetryable(err error) bool {
+	var providerErr *ProviderError
+
+	return errors.As(err, &providerErr) && providerErr.Kind.Retryable()
+}
+
+// Classify builds a ProviderError from what a client could extract from its SDK error.
+// errorType and message come from the provider's error body and may be empty
+func Classify(provider types.Provider, err error, statusCode int, errorType string, message string, header http.Header) *ProviderError {
+	providerErr := &ProviderError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(header),
+		Err:        err,
+	}
+
+	if providerErr.Message == "" {
+		providerErr.Message = err.Error()
+	}
+
+	providerErr.Kind = classifyKind(err, statusCode, errorType, strings.ToLower(errorType+" "+providerErr.Message))
+
+	return providerErr
+}
+
+// ClassifyTransport handles errors that never produced an HTTP response
+func ClassifyTransport(provider types.Provider, err error) *ProviderError {
+	return Classify(provider, err, 0, "", "", nil)
+}
+
+// contentFilterCodes are the error codes and finish reasons providers use for blocked content. They are
+// matched against the error type only, messages mention "safety" or "moderation" for unrelated mistakes
+// such as an invalid safety_settings field
+var contentFilterCodes = []string{
+	"content_filter",
+	"content_policy_violation",
+	"moderation_blocked",
+	"SAFETY",
+	"PROHIBITED_CONTENT",
+	"BLOCKLIST",
+	"SPII",
+}
+
+func classifyKind(err error, statusCode int, errorType string, detail string) Kind {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindNetwork
+	case containsAny(detail, "context_length", "context length", "prompt is too long", "maximum context", "too many tokens", "reduce the length"):
+		return KindContextLength
+	case hasCode(errorType, contentFilterCodes...):
+		return KindContentFiltered
+	case containsAny(detail, "insufficient_quota", "quota exceeded", "exceeded your current quota", "credit balance", "insufficient credits"):
+		return KindQuotaExceeded
+	case containsAny(detail, "overloaded"):
+		return KindOverloaded
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return KindAuth
+	case statusCode == http.StatusPaymentRequired:
+		return KindQuotaExceeded
+	case statusCode == http.StatusTooManyRequests:
+		return KindRateLimited
+	case statusCode == http.StatusNotFound:
+		return KindNotFound
+	case statusCode == 529 || statusCode == http.StatusServiceUnavailable:
+		return KindOverloaded
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusBadGateway || statusCode == http.StatusGatewayTimeout:
+		return KindNetwork
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity || statusCode == http.StatusRequestEntityTooLarge:
+		// Some providers report bad keys as a 400
+		if containsAny(detail, utils.AuthSubstrings...) {
+			return KindAuth
+		}
+
+		return KindBadRequest
+	case statusCode >= http.StatusInternalServerError:
+		return KindOverloaded
+	}
+
+	var netErr net.Error
+
+	if statusCode == 0 && errors.As(err, &netErr) {
+		return KindNetwork
+	}
+
+	if containsAny(detail, utils.AuthSubstrings...) {
+		return KindAuth
+	}
+
+	return KindUnknown
+}
+
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	value := header.Get("Retry-After")
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+
+	return 0
+}
+
+// hasCode reports whether any word of an error type is one of the codes
+func hasCode(errorType string, codes ...string) bool {
+	for _, field := range strings.Fields(errorType) {
+		for _, code := range codes {
+			if strings.EqualFold(field, code) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func containsAny(text string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(text, substring) {
+			return true
+		}
+	}
+
+	return false
+}
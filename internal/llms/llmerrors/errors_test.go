@@ -0,0 +1,50 @@
+package llmerrors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyKind(t *testing.T) {
+	cases := []struct {
+		name       string
+		statusCode int
+		errorType  string
+		message    string
+		want       Kind
+	}{
+		{"invalid safety settings are a bad request", http.StatusBadRequest, "INVALID_ARGUMENT", "safety_settings is invalid: unknown category", KindBadRequest},
+		{"moderation mentioned in a message", http.StatusBadRequest, "invalid_request_error", "the moderation field is not supported", KindBadRequest},
+		{"OpenAI content policy code", http.StatusBadRequest, "invalid_request_error content_policy_violation", "Your request was rejected", KindContentFiltered},
+		{"OpenAI moderation code", http.StatusBadRequest, "invalid_request_error moderation_blocked", "Your request was blocked", KindContentFiltered},
+		{"Azure content filter code", http.StatusBadRequest, "content_filter", "The response was filtered", KindContentFiltered},
+		{"Gemini safety finish reason", http.StatusOK, "SAFETY", "", KindContentFiltered},
+		{"context length", http.StatusBadRequest, "invalid_request_error", "This model's maximum context length is 8192 tokens", KindContextLength},
+		{"quota", http.StatusTooManyRequests, "insufficient_quota", "You exceeded your current quota", KindQuotaExceeded},
+		{"rate limit", http.StatusTooManyRequests, "rate_limit_error", "slow down", KindRateLimited},
+		{"overloaded", 529, "overloaded_error", "Overloaded", KindOverloaded},
+		{"bad key reported as a bad request", http.StatusBadRequest, "INVALID_ARGUMENT", "API key not valid. Please pass a valid API key.", KindAuth},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Classify("Test", errors.New(tc.message), tc.statusCode, tc.errorType, tc.message, nil)
+
+			if err.Kind != tc.want {
+				t.Errorf("kind = %s, want %s", err.Kind, tc.want)
+			}
+		})
+	}
+}
+
+func TestClassifyTransport(t *testing.T) {
+	if kind := ClassifyTransport("Test", context.Canceled).Kind; kind != KindCanceled {
+		t.Errorf("canceled: kind = %s", kind)
+	}
+
+	if kind := ClassifyTransport("Test", context.DeadlineExceeded).Kind; kind != KindNetwork {
+		t.Errorf("deadline: kind = %s", kind)
+	}
+}
@@ -0,0 +1,72 @@
+package llmerrors
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 3
+	retryBaseDelay     = 500 * time.Millisecond
+	retryMaxDelay      = 8 * time.Second
+	retryAfterLimit    = 30 * time.Second
+)
+
+// MaxAttempts reads RETRY_MAX_ATTEMPTS, the total number of tries including the first
+func MaxAttempts() int {
+	if value := os.Getenv("RETRY_MAX_ATTEMPTS"); value != "" {
+		if attempts, err := strconv.Atoi(value); err == nil && attempts > 0 {
+			return attempts
+		}
+
+		log.Printf("ignoring invalid RETRY_MAX_ATTEMPTS=%q", value)
+	}
+
+	return DefaultMaxAttempts
+}
+
+// Retry runs call until it succeeds, fails with a non-retryable error or runs out of attempts.
+// Delays use full jitter exponential backoff unless the provider sent a usable Retry-After
+func Retry[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	return RetryIf(ctx, nil, call)
+}
+
+// RetryIf is Retry with an extra gate, a stream that already sent output must not be replayed
+func RetryIf[T any](ctx context.Context, allow func() bool, call func() (T, error)) (T, error) {
+	maxAttempts := MaxAttempts()
+
+	for attempt := 1; ; attempt++ {
+		result, err := call()
+
+		if err == nil || attempt >= maxAttempts || !IsRetryable(err) || (allow != nil && !allow()) {
+			return result, err
+		}
+
+		delay := backoff(attempt, err)
+
+		log.Printf("retrying provider call attempt=%d/%d delay=%s err=%v", attempt+1, maxAttempts, delay.Round(time.Millisecond), err)
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func backoff(attempt int, err error) time.Duration {
+	var providerErr *ProviderError
+
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 && providerErr.RetryAfter <= retryAfterLimit {
+		return providerErr.RetryAfter
+	}
+
+	ceiling := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+
+	return time.Duration(rand.Int64N(int64(ceiling)) + 1)
+}
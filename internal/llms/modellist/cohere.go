@@ -0,0 +1,67 @@
+// Package modellist parses model lists in provider formats that more than one client reads
+package modellist
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type cohereModelResponse struct {
+	Models []cohereModel `json:"models"`
+}
+
+type cohereModel struct {
+	Name          string   `json:"name"`
+	Endpoints     []string `json:"endpoints"`
+	Finetuned     bool     `json:"finetuned"`
+	ContextLength int      `json:"context_length"`
+	TokenizerURL  string   `json:"tokenizer_url"`
+	Features      []string `json:"features"`
+}
+
+// ParseCohere reads Cohere's native v1 model list, which also reports context length and features. The
+// OpenAI-compatible client reads it too when Cohere is configured as a compatible provider
+func ParseCohere(rawJSON []byte, providerName types.Provider) ([]*types.Model, error) {
+	response := &cohereModelResponse{}
+
+	if err := json.Unmarshal(rawJSON, &response); err != nil {
+		return nil, fmt.Errorf("%s parse failed: %w", providerName, err)
+	}
+
+	models := make([]*types.Model, 0, len(response.Models))
+
+	for _, model := range response.Models {
+		if model.Name == "" {
+			continue
+		}
+
+		entry := &types.Model{
+			ID:       model.Name,
+			Name:     model.Name,
+			Provider: providerName,
+			// Embed and rerank models are listed too but can't chat
+			Enabled: utils.IsModelAllowed(providerName, model.Name) && (len(model.Endpoints) == 0 || slices.Contains(model.Endpoints, "chat")),
+		}
+
+		entry.ContextLength = model.ContextLength
+
+		for _, feature := range model.Features {
+			switch feature {
+			case "tool_use", "strict_tools":
+				entry.SupportsTools = true
+			case "json_mode", "json_schema":
+				entry.SupportsJSON = true
+			case "vision":
+				entry.InputModalities = []string{"text", "image"}
+			}
+		}
+
+		models = append(models, entry)
+	}
+
+	return models, nil
+}
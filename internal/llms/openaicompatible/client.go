@@ -3,59 +3,197 @@ package openaicompatible
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
+	"github.com/CodingWithKarim/AgentK/internal/llms/modellist"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 	sdk "github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/pagination"
 )
 
+// OpenAIClient carries its own copy of the provider settings so a config reload never changes
+// an in-flight request halfway through
+// OpenAIClient sends chats to BaseURL and lists models from ModelEndpoint, which falls back to BaseURL
 type OpenAIClient struct {
-	Client   *sdk.Client
-	Provider types.Provider
-	Key      string
+	Client        *sdk.Client
+	Provider      types.Provider
+	Keys          *keypool.Pool
+	BaseURL       string
+	ModelEndpoint string
+	Headers       map[string]string
+	StaticModels  []string
+	Timeout       time.Duration
 }
 
-func (c *OpenAIClient) Chat(chatRequest *types.ChatRequest, contextMessages any) (string, error) {
-	llmResponse, err := c.Client.Chat.Completions.New(
-		context.Background(),
-		buildChatCompletionParams(chatRequest.ModelID, chatRequest.Tokens, chatRequest.SystemPrompt, contextMessages),
-		buildRequestOptions(chatRequest.Provider, c.Key)...)
+func (c *OpenAIClient) KeyPool() *keypool.Pool {
+	return c.Keys
+}
+
+// Encode converts canonical messages into the chat completion messages Chat and ChatStream expect
+func (c *OpenAIClient) Encode(messages []types.Message) (any, error) {
+	return BuildOpenAIMessages(messages)
+}
+
+// ReadsFiles trusts the catalog, compatible APIs that don't take file parts reject them outright
+func (c *OpenAIClient) ReadsFiles(modelID string) bool {
+	capabilities, _ := utils.GetModelCapabilities(c.Provider, modelID)
+
+	return slices.Contains(capabilities.InputModalities, "file")
+}
+
+func (c *OpenAIClient) Chat(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	params, err := buildChatCompletionParams(chatRequest, contextMessages)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if len(llmResponse.Choices) == 0 {
-		return "", nil
+	if c.Provider == utils.PERPLEXITY {
+		applySearchOptions(&params, chatRequest.Search)
 	}
 
-	return llmResponse.Choices[0].Message.Content, nil
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*sdk.ChatCompletion, error) {
+		llmResponse, err := c.Client.Chat.Completions.New(
+			ctx,
+			params,
+			buildRequestOptions(c.BaseURL, key, c.Headers)...)
+
+		if err != nil {
+			return nil, classifyError(c.Provider, err)
+		}
+
+		return llmResponse, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buildChatResult(llmResponse), nil
 }
 
-func (c *OpenAIClient) Models() ([]*types.Model, error) {
+func (c *OpenAIClient) ChatStream(ctx context.Context, chatRequest *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	params, err := buildChatCompletionParams(chatRequest, contextMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
 	if c.Provider == utils.PERPLEXITY {
-		return loadStaticModels(), nil
+		applySearchOptions(&params, chatRequest.Search)
+	}
+
+	// Ask for a trailing usage chunk so token counts can be reported at the end of the stream
+	params.StreamOptions = sdk.ChatCompletionStreamOptionsParam{
+		IncludeUsage: sdk.Bool(true),
 	}
 
-	llmResponse, err := c.Client.Models.List(context.Background(), buildRequestOptions(c.Provider, c.Key)...)
+	key := c.Keys.Pick()
+
+	stream := c.Client.Chat.Completions.NewStreaming(
+		ctx,
+		params,
+		buildRequestOptions(c.BaseURL, key, c.Headers)...)
+
+	defer stream.Close()
+
+	accumulator := sdk.ChatCompletionAccumulator{}
+	var sources []types.Source
+
+	for stream.Next() {
+		chunk := stream.Current()
+		accumulator.AddChunk(chunk)
+
+		// The accumulator drops Perplexity's sources, chunks repeat them so the latest list wins
+		if chunkSources := parseSources(chunk.RawJSON()); chunkSources != nil {
+			sources = chunkSources
+		}
+
+		// The accumulator sums token counts but drops the cached token breakdown
+		if chunk.JSON.Usage.Valid() {
+			accumulator.Usage.PromptTokensDetails = chunk.Usage.PromptTokensDetails
+		}
+
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if err := onDelta(chunk.Choices[0].Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		err = classifyError(c.Provider, err)
+		c.Keys.Report(key, err)
+
+		return nil, err
+	}
+
+	result := buildChatResult(&accumulator.ChatCompletion)
+	result.Sources = sources
+
+	return result, nil
+}
+
+func (c *OpenAIClient) Models(ctx context.Context) ([]*types.Model, error) {
+	ctx, cancel := utils.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	modelEndpoint := c.ModelEndpoint
+
+	if modelEndpoint == "" {
+		modelEndpoint = c.BaseURL
+	}
+
+	llmResponse, err := keypool.Do(c.Keys, func(key string) (*pagination.Page[sdk.Model], error) {
+		llmResponse, err := c.Client.Models.List(ctx, buildRequestOptions(modelEndpoint, key, c.Headers)...)
+
+		if err != nil {
+			return nil, classifyError(c.Provider, err)
+		}
+
+		return llmResponse, nil
+	})
+
+	// The static list stands in for a model endpoint the provider doesn't have, other failures are still reported
+	if err != nil && len(c.StaticModels) > 0 && modelEndpointMissing(err) {
+		return loadStaticModels(c.Provider, c.StaticModels), nil
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("%s model list failed: %w", c.Provider, err)
 	}
 
 	if c.Provider == utils.COHERE {
-		return parseCohereModels([]byte(llmResponse.RawJSON()), c.Provider)
+		return modellist.ParseCohere([]byte(llmResponse.RawJSON()), c.Provider)
 	}
 
 	models := make([]*types.Model, 0, len(llmResponse.Data))
 
 	for _, model := range llmResponse.Data {
-		models = append(models, &types.Model{
+		entry := &types.Model{
 			ID:       model.ID,
 			Name:     model.ID,
 			Provider: c.Provider,
-			Enabled:  allowOpenAIModel(model.ID),
-		})
+			// Configured models are trusted even when the name trips the filter, like sonar-deep-research
+			Enabled: slices.Contains(c.StaticModels, model.ID) || utils.IsModelAllowed(c.Provider, model.ID),
+		}
+
+		applyModelMetadata(entry, []byte(model.RawJSON()))
+
+		models = append(models, entry)
+	}
+
+	if len(models) == 0 && len(c.StaticModels) > 0 {
+		return loadStaticModels(c.Provider, c.StaticModels), nil
 	}
 
 	return models, nil
@@ -2,57 +2,211 @@ package openaicompatible
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/CodingWithKarim/AgentK/internal/llms/llmerrors"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 	sdk "github.com/openai/openai-go"
 	sdkOption "github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
 )
 
-type CohereModelResponse struct {
-	Models []CohereModel `json:"models"`
+// ModelMetadata holds the non-standard fields some compatible providers add to /models entries,
+// OpenRouter fills architecture, pricing and supported_parameters while Groq sends context_window
+type ModelMetadata struct {
+	Name                string   `json:"name"`
+	ContextLength       int      `json:"context_length"`
+	ContextWindow       int      `json:"context_window"`
+	MaxCompletionTokens int      `json:"max_completion_tokens"`
+	SupportedParameters []string `json:"supported_parameters"`
+	Architecture        struct {
+		InputModalities  []string `json:"input_modalities"`
+		OutputModalities []string `json:"output_modalities"`
+	} `json:"architecture"`
+	TopProvider struct {
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+	} `json:"top_provider"`
+	Pricing *struct {
+		Prompt         string `json:"prompt"`
+		Completion     string `json:"completion"`
+		InputCacheRead string `json:"input_cache_read"`
+	} `json:"pricing"`
 }
 
-type CohereModel struct {
-	Name          string   `json:"name"`
-	Endpoints     []string `json:"endpoints"`
-	Finetuned     bool     `json:"finetuned"`
-	ContextLength int      `json:"context_length"`
-	TokenizerURL  string   `json:"tokenizer_url"`
-	Features      any      `json:"features"`
+// SearchResults holds the sources Perplexity adds next to the choices of a completion or stream chunk
+type SearchResults struct {
+	Citations     []string `json:"citations"`
+	SearchResults []struct {
+		Title   string `json:"title"`
+		URL     string `json:"url"`
+		Date    string `json:"date"`
+		Snippet string `json:"snippet"`
+	} `json:"search_results"`
 }
 
-func allowOpenAIModel(id string) bool {
-	modelID := strings.ToLower(id)
+// BuildOpenAIMessages converts canonical messages into chat completion messages. Tool calls move onto
+// the assistant message, each tool result becomes its own tool message and thinking parts are dropped
+func BuildOpenAIMessages(messages []types.Message) ([]sdk.ChatCompletionMessageParamUnion, error) {
+	converted := make([]map[string]any, 0, len(messages))
 
-	for _, badWord := range utils.DisallowedModels {
-		if strings.Contains(modelID, badWord) {
-			return false
+	for _, msg := range messages {
+		parts, err := msg.Parts()
+
+		if err != nil {
+			return nil, err
+		}
+
+		content := make([]map[string]any, 0, len(parts))
+		texts := make([]string, 0, len(parts))
+		toolCalls := make([]map[string]any, 0)
+
+		for _, part := range parts {
+			switch part.Type {
+			case "text":
+				texts = append(texts, part.Text)
+				content = append(content, map[string]any{"type": "text", "text": part.Text})
+
+			case "image_url":
+				if part.ImageURL == nil {
+					return nil, fmt.Errorf("image_url missing image_url field")
+				}
+
+				content = append(content, map[string]any{"type": "image_url", "image_url": map[string]string{"url": part.ImageURL.URL}})
+
+			case "file":
+				if part.File == nil {
+					return nil, fmt.Errorf("file missing file field")
+				}
+
+				filePart, err := buildFilePart(part.File)
+
+				if err != nil {
+					return nil, err
+				}
+
+				content = append(content, filePart)
+
+			case "tool_call":
+				if part.ToolCall == nil {
+					return nil, fmt.Errorf("tool_call missing tool_call field")
+				}
+
+				arguments := string(part.ToolCall.Arguments)
+
+				if arguments == "" {
+					arguments = "{}"
+				}
+
+				toolCalls = append(toolCalls, map[string]any{
+					"id":   part.ToolCall.ID,
+					"type": "function",
+					"function": map[string]any{
+						"name":      part.ToolCall.Name,
+						"arguments": arguments,
+					},
+				})
+
+			// Tool results answer the previous assistant turn so they go before anything else in the message
+			case "tool_result":
+				converted = append(converted, map[string]any{
+					"role":         "tool",
+					"tool_call_id": part.ToolCallID,
+					"content":      part.ToolOutput(),
+				})
+			}
+		}
+
+		if msg.Role == "assistant" {
+			if len(texts) == 0 && len(toolCalls) == 0 {
+				continue
+			}
+
+			assistant := map[string]any{"role": "assistant", "content": strings.Join(texts, "\n")}
+
+			if len(toolCalls) > 0 {
+				assistant["tool_calls"] = toolCalls
+			}
+
+			converted = append(converted, assistant)
+			continue
+		}
+
+		// Plain text goes out as a string, not every compatible provider takes content arrays
+		switch {
+		case len(content) == 0:
+		case len(texts) == len(content):
+			converted = append(converted, map[string]any{"role": msg.Role, "content": strings.Join(texts, "\n")})
+		default:
+			converted = append(converted, map[string]any{"role": msg.Role, "content": content})
 		}
 	}
 
-	return true
+	encoded, err := json.Marshal(converted)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var params []sdk.ChatCompletionMessageParamUnion
+
+	if err := json.Unmarshal(encoded, &params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
 }
 
-func buildChatCompletionParams(
-	modelID string,
-	tokens int64,
-	systemPrompt string,
-	messages any,
-) sdk.ChatCompletionNewParams {
-	msgs := messages.([]sdk.ChatCompletionMessageParamUnion)
+// buildFilePart sends PDFs and upload references as file parts, text documents go inline since OpenAI
+// only reads PDFs through file parts
+func buildFilePart(file *types.File) (map[string]any, error) {
+	if file.FileData != "" {
+		// Validated when the context was parsed
+		if mediaType, _, _ := utils.ParseDataURL(file.FileData); mediaType != utils.PDFMediaType {
+			text, err := utils.AttachedFileText(file)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{"type": "text", "text": text}, nil
+		}
+	}
+
+	filePart := map[string]any{}
+
+	if file.FileID != "" {
+		filePart["file_id"] = file.FileID
+	}
+
+	if file.FileData != "" {
+		filePart["file_data"] = file.FileData
+		filePart["filename"] = utils.FileName(file)
+	}
+
+	return map[string]any{"type": "file", "file": filePart}, nil
+}
+
+func buildChatCompletionParams(chatRequest *types.ChatRequest, messages any) (sdk.ChatCompletionNewParams, error) {
+	msgs, ok := messages.([]sdk.ChatCompletionMessageParamUnion)
+
+	if !ok {
+		return sdk.ChatCompletionNewParams{}, fmt.Errorf("openai compatible client got a %T context, expected chat completion messages", messages)
+	}
 
 	finalMessages := make([]sdk.ChatCompletionMessageParamUnion, 0, len(msgs)+1)
 
 	// Add system prompt if provided
-	if systemPrompt != "" {
+	if chatRequest.SystemPrompt != "" {
 		finalMessages = append(finalMessages, sdk.ChatCompletionMessageParamUnion{
 			OfSystem: &sdk.ChatCompletionSystemMessageParam{
 				Content: sdk.ChatCompletionSystemMessageParamContentUnion{
-					OfString: sdk.String(systemPrompt),
+					OfString: sdk.String(chatRequest.SystemPrompt),
 				},
 			},
 		})
@@ -62,57 +216,279 @@ func buildChatCompletionParams(
 	finalMessages = append(finalMessages, msgs...)
 
 	params := sdk.ChatCompletionNewParams{
-		Model:    modelID,
+		Model:    chatRequest.ModelID,
 		Messages: finalMessages,
 	}
 
-	if tokens > 0 {
-		params.MaxCompletionTokens = param.Opt[int64]{Value: tokens}
+	if chatRequest.Tokens > 0 {
+		params.MaxCompletionTokens = param.Opt[int64]{Value: chatRequest.Tokens}
 	}
 
-	return params
+	if len(chatRequest.Tools) == 0 {
+		return params, nil
+	}
+
+	tools, err := buildTools(chatRequest.Tools)
+
+	if err != nil {
+		return params, err
+	}
+
+	params.Tools = tools
+	params.ToolChoice = buildToolChoice(chatRequest.ToolChoice)
+
+	return params, nil
 }
 
-func buildRequestOptions(providerName types.Provider, key string) []sdkOption.RequestOption {
-	return []sdkOption.RequestOption{
-		sdkOption.WithBaseURL(utils.ProviderEndpointsMap[providerName].ModelEndpoint),
-		sdkOption.WithAPIKey(key),
+func buildTools(definitions []types.ToolDefinition) ([]sdk.ChatCompletionToolParam, error) {
+	tools := make([]sdk.ChatCompletionToolParam, 0, len(definitions))
+
+	for _, definition := range definitions {
+		function := shared.FunctionDefinitionParam{
+			Name: definition.Name,
+		}
+
+		if definition.Description != "" {
+			function.Description = sdk.String(definition.Description)
+		}
+
+		if len(definition.Parameters) > 0 {
+			if err := json.Unmarshal(definition.Parameters, &function.Parameters); err != nil {
+				return nil, fmt.Errorf("invalid parameters schema for tool %q: %w", definition.Name, err)
+			}
+		}
+
+		tools = append(tools, sdk.ChatCompletionToolParam{Function: function})
 	}
+
+	return tools, nil
 }
 
-func parseCohereModels(rawJSON []byte, providerName types.Provider) ([]*types.Model, error) {
-	response := &CohereModelResponse{}
+func buildToolChoice(choice string) sdk.ChatCompletionToolChoiceOptionUnionParam {
+	switch choice {
+	case "":
+		return sdk.ChatCompletionToolChoiceOptionUnionParam{}
+	case "auto", "none", "required":
+		return sdk.ChatCompletionToolChoiceOptionUnionParam{OfAuto: sdk.String(choice)}
+	default:
+		return sdk.ChatCompletionToolChoiceOptionUnionParam{
+			OfChatCompletionNamedToolChoice: &sdk.ChatCompletionNamedToolChoiceParam{
+				Function: sdk.ChatCompletionNamedToolChoiceFunctionParam{Name: choice},
+			},
+		}
+	}
+}
+
+func buildChatResult(completion *sdk.ChatCompletion) *types.ChatResult {
+	result := &types.ChatResult{
+		Model: completion.Model,
+		Usage: types.Usage{
+			InputTokens:  completion.Usage.PromptTokens,
+			OutputTokens: completion.Usage.CompletionTokens,
+			CachedTokens: completion.Usage.PromptTokensDetails.CachedTokens,
+		},
+	}
 
-	if err := json.Unmarshal(rawJSON, &response); err != nil {
-		return nil, fmt.Errorf("%s parse failed: %w", providerName, err)
+	if len(completion.Choices) == 0 {
+		return result
 	}
 
-	models := make([]*types.Model, 0, len(response.Models))
+	message := completion.Choices[0].Message
 
-	for _, model := range response.Models {
-		if model.Name == "" {
-			continue
+	result.Text = message.Content
+	result.StopReason = completion.Choices[0].FinishReason
+
+	result.Sources = parseSources(completion.RawJSON())
+
+	for _, toolCall := range message.ToolCalls {
+		arguments := json.RawMessage(toolCall.Function.Arguments)
+
+		// Some providers send an empty string when a tool takes no arguments, and
+		// truncated output can leave malformed JSON that is passed through as a string
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		} else if !json.Valid(arguments) {
+			arguments, _ = json.Marshal(toolCall.Function.Arguments)
 		}
 
-		models = append(models, &types.Model{
-			ID:       model.Name,
-			Name:     model.Name,
-			Provider: utils.COHERE,
-			Enabled:  allowOpenAIModel(model.Name),
+		result.ToolCalls = append(result.ToolCalls, types.ToolCall{
+			ID:        toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: arguments,
 		})
 	}
 
-	return models, nil
+	return result
+}
+
+func buildRequestOptions(baseURL string, key string, headers map[string]string) []sdkOption.RequestOption {
+	options := []sdkOption.RequestOption{
+		sdkOption.WithBaseURL(baseURL),
+		sdkOption.WithAPIKey(key),
+		// SDK retries are disabled, the chat service retries with provider aware backoff instead
+		sdkOption.WithMaxRetries(0),
+	}
+
+	// The shared client defaults to OPENAI_API_KEY, which must never reach a keyless provider
+	if key == "" {
+		options = append(options, sdkOption.WithHeaderDel("Authorization"))
+	}
+
+	for name, value := range headers {
+		options = append(options, sdkOption.WithHeader(name, value))
+	}
+
+	return options
+}
+
+func classifyError(provider types.Provider, err error) error {
+	var apiErr *sdk.Error
+
+	if !errors.As(err, &apiErr) {
+		return llmerrors.ClassifyTransport(provider, err)
+	}
+
+	var header http.Header
+
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+
+	message := apiErr.Message
+
+	// Not every compatible provider fills the OpenAI error shape, fall back to the raw body
+	if message == "" {
+		message = apiErr.RawJSON()
+	}
+
+	return llmerrors.Classify(provider, err, apiErr.StatusCode, apiErr.Type+" "+apiErr.Code, message, header)
+}
+
+// modelEndpointMissing tells a provider without a usable model endpoint apart from failures worth reporting
+func modelEndpointMissing(err error) bool {
+	var providerErr *llmerrors.ProviderError
+
+	if !errors.As(err, &providerErr) {
+		return false
+	}
+
+	return providerErr.Kind == llmerrors.KindNotFound || providerErr.Kind == llmerrors.KindBadRequest || providerErr.Kind == llmerrors.KindUnknown
+}
+
+// applySearchOptions adds Perplexity's search filters to the request body
+func applySearchOptions(params *sdk.ChatCompletionNewParams, search *types.SearchOptions) {
+	if search == nil {
+		return
+	}
+
+	fields := map[string]any{}
+
+	if search.RecencyFilter != "" {
+		fields["search_recency_filter"] = search.RecencyFilter
+	}
+
+	if len(search.DomainFilter) > 0 {
+		fields["search_domain_filter"] = search.DomainFilter
+	}
+
+	if search.ContextSize != "" {
+		fields["web_search_options"] = map[string]string{"search_context_size": search.ContextSize}
+	}
+
+	params.SetExtraFields(fields)
+}
+
+// parseSources reads the web results Perplexity adds to a completion. search_results carries titles and
+// snippets, the older citations field only URLs, and either is numbered the way the text's [n] markers are
+func parseSources(rawJSON string) []types.Source {
+	results := SearchResults{}
+
+	if rawJSON == "" || json.Unmarshal([]byte(rawJSON), &results) != nil {
+		return nil
+	}
+
+	sources := make([]types.Source, 0, max(len(results.SearchResults), len(results.Citations)))
+
+	for i, result := range results.SearchResults {
+		sources = append(sources, types.Source{ID: strconv.Itoa(i + 1), Title: result.Title, URL: result.URL, Snippet: result.Snippet})
+	}
+
+	if len(sources) > 0 {
+		return sources
+	}
+
+	for i, url := range results.Citations {
+		sources = append(sources, types.Source{ID: strconv.Itoa(i + 1), URL: url})
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	return sources
+}
+
+// applyModelMetadata copies whatever capabilities the provider reported for a model, anything
+// left unset is filled from the catalog later
+func applyModelMetadata(model *types.Model, rawJSON []byte) {
+	metadata := ModelMetadata{}
+
+	if len(rawJSON) == 0 || json.Unmarshal(rawJSON, &metadata) != nil {
+		return
+	}
+
+	if metadata.Name != "" {
+		model.Name = metadata.Name
+	}
+
+	model.ContextLength = max(metadata.ContextLength, metadata.ContextWindow)
+	model.MaxOutputTokens = max(metadata.MaxCompletionTokens, metadata.TopProvider.MaxCompletionTokens)
+	model.InputModalities = metadata.Architecture.InputModalities
+	model.OutputModalities = metadata.Architecture.OutputModalities
+
+	for _, parameter := range metadata.SupportedParameters {
+		switch parameter {
+		case "tools":
+			model.SupportsTools = true
+		case "response_format", "structured_outputs":
+			model.SupportsJSON = true
+		}
+	}
+
+	if metadata.Pricing == nil {
+		return
+	}
+
+	// OpenRouter prices are USD per token as strings, the catalog uses USD per million tokens
+	input, inputErr := strconv.ParseFloat(metadata.Pricing.Prompt, 64)
+	output, outputErr := strconv.ParseFloat(metadata.Pricing.Completion, 64)
+
+	// Negative prices mark router models such as openrouter/auto whose price depends on the pick
+	if inputErr != nil || outputErr != nil || input < 0 || output < 0 {
+		return
+	}
+
+	cachedInput, err := strconv.ParseFloat(metadata.Pricing.InputCacheRead, 64)
+
+	if err != nil {
+		cachedInput = input
+	}
+
+	model.Pricing = &types.ModelPricing{
+		Input:       input * 1_000_000,
+		CachedInput: cachedInput * 1_000_000,
+		Output:      output * 1_000_000,
+	}
 }
 
-func loadStaticModels() []*types.Model {
-	models := make([]*types.Model, 0)
+func loadStaticModels(provider types.Provider, modelIDs []string) []*types.Model {
+	models := make([]*types.Model, 0, len(modelIDs))
 
-	for _, modelID := range utils.PerplexityModels {
+	for _, modelID := range modelIDs {
 		model := &types.Model{
 			ID:       modelID,
 			Name:     strings.ReplaceAll(modelID, "-", " "),
-			Provider: utils.PERPLEXITY,
+			Provider: provider,
 			Enabled:  true,
 		}
 
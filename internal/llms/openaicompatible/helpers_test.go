@@ -0,0 +1,240 @@
+package openaicompatible
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func message(t *testing.T, role string, parts ...types.MessagePart) types.Message {
+	t.Helper()
+
+	content, err := json.Marshal(parts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Message{Role: role, Content: content}
+}
+
+type encodedMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCallID string          `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// decodeMessages reads encoded messages back into canonical ones, tool messages answering one
+// assistant turn become the tool_result parts of a single user message as the chat parser does
+func decodeMessages(t *testing.T, params any) []types.Message {
+	t.Helper()
+
+	encoded, err := json.Marshal(params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []encodedMessage
+
+	if err := json.Unmarshal(encoded, &messages); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := make([]types.Message, 0, len(messages))
+	var pending []types.MessagePart
+
+	for _, encodedMessage := range messages {
+		if encodedMessage.Role == "tool" {
+			var text string
+
+			if err := json.Unmarshal(encodedMessage.Content, &text); err != nil {
+				t.Fatal(err)
+			}
+
+			pending = append(pending, types.MessagePart{Type: "tool_result", ToolCallID: encodedMessage.ToolCallID, Text: text})
+			continue
+		}
+
+		parts := append(pending, decodeContent(t, encodedMessage.Content)...)
+		pending = nil
+
+		for _, toolCall := range encodedMessage.ToolCalls {
+			parts = append(parts, types.MessagePart{Type: "tool_call", ToolCall: &types.ToolCall{
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: json.RawMessage(toolCall.Function.Arguments),
+			}})
+		}
+
+		decoded = append(decoded, message(t, encodedMessage.Role, parts...))
+	}
+
+	if len(pending) > 0 {
+		decoded = append(decoded, message(t, "user", pending...))
+	}
+
+	return decoded
+}
+
+func decodeContent(t *testing.T, content json.RawMessage) []types.MessagePart {
+	t.Helper()
+
+	var text string
+
+	if err := json.Unmarshal(content, &text); err == nil {
+		if text == "" {
+			return nil
+		}
+
+		return []types.MessagePart{{Type: "text", Text: text}}
+	}
+
+	var parts []types.MessagePart
+
+	if err := json.Unmarshal(content, &parts); err != nil {
+		t.Fatal(err)
+	}
+
+	return parts
+}
+
+func assertMessages(t *testing.T, got []types.Message, want []types.Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		gotParts, err := got[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantParts, err := want[i].Parts()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got[i].Role != want[i].Role || !reflect.DeepEqual(gotParts, wantParts) {
+			gotJSON, _ := json.Marshal(gotParts)
+			wantJSON, _ := json.Marshal(wantParts)
+			t.Errorf("message %d:\n got %s %s\nwant %s %s", i, got[i].Role, gotJSON, want[i].Role, wantJSON)
+		}
+	}
+}
+
+func TestBuildOpenAIMessagesRoundTrip(t *testing.T) {
+	pdf := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 fixture"))
+	toolCall := &types.ToolCall{ID: "call_1", Name: "calculator", Arguments: json.RawMessage(`{"expression":"2+2"}`)}
+
+	cases := []struct {
+		name  string
+		role  string
+		parts []types.MessagePart
+	}{
+		{"text", "user", []types.MessagePart{{Type: "text", Text: "hello"}}},
+		{"system text", "system", []types.MessagePart{{Type: "text", Text: "be brief"}}},
+		{"data URL image", "user", []types.MessagePart{{Type: "text", Text: "see"}, {Type: "image_url", ImageURL: &types.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}}}},
+		{"remote image", "user", []types.MessagePart{{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://example.com/cat.png"}}}},
+		{"pdf file", "user", []types.MessagePart{{Type: "file", File: &types.File{Filename: "report.pdf", FileData: pdf}}}},
+		{"uploaded file", "user", []types.MessagePart{{Type: "file", File: &types.File{FileID: "file-abc"}}}},
+		{"tool call", "assistant", []types.MessagePart{{Type: "text", Text: "checking"}, {Type: "tool_call", ToolCall: toolCall}}},
+		{"tool result", "user", []types.MessagePart{{Type: "tool_result", ToolCallID: "call_1", Text: "4"}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages := []types.Message{message(t, tc.role, tc.parts...)}
+
+			params, err := BuildOpenAIMessages(messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertMessages(t, decodeMessages(t, params), messages)
+		})
+	}
+}
+
+func TestBuildOpenAIMessages(t *testing.T) {
+	text := "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("plain notes"))
+
+	cases := []struct {
+		name     string
+		messages []types.Message
+		want     string
+	}{
+		{
+			name: "tool results become tool messages ahead of the user's text",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{"x":1}}},{"type":"tool_call","tool_call":{"id":"b","name":"calc","arguments":{"x":2}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"also"},{"type":"tool_result","tool_call_id":"a","text":"1"},{"type":"tool_result","tool_call_id":"b","text":"2"}]`)},
+			},
+			want: `[{"content":"","tool_calls":[{"id":"a","function":{"arguments":"{\"x\":1}","name":"calc"},"type":"function"},{"id":"b","function":{"arguments":"{\"x\":2}","name":"calc"},"type":"function"}],"role":"assistant"},{"content":"1","tool_call_id":"a","role":"tool"},{"content":"2","tool_call_id":"b","role":"tool"},{"content":"also","role":"user"}]`,
+		},
+		{
+			name: "failed tool results are marked in the text",
+			messages: []types.Message{
+				{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"calc","arguments":{}}}]`)},
+				{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_call_id":"a","text":"division by zero","is_error":true}]`)},
+			},
+			want: `[{"content":"","tool_calls":[{"id":"a","function":{"arguments":"{}","name":"calc"},"type":"function"}],"role":"assistant"},{"content":"Error: division by zero","tool_call_id":"a","role":"tool"}]`,
+		},
+		{
+			name:     "thinking is dropped and an empty assistant turn skipped",
+			messages: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"thinking","text":"hmm","signature":"sig"}]`)}},
+			want:     `[]`,
+		},
+		{
+			name:     "text files are sent inline",
+			messages: []types.Message{{Role: "user", Content: json.RawMessage(`[{"type":"file","file":{"filename":"notes.txt","file_data":"` + text + `"}}]`)}},
+			want:     `[{"content":[{"text":"Attached file notes.txt:\n\nplain notes","type":"text"}],"role":"user"}]`,
+		},
+		{
+			name:     "tool calls without arguments send an empty object",
+			messages: []types.Message{{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_call","tool_call":{"id":"a","name":"now"}}]`)}},
+			want:     `[{"content":"","tool_calls":[{"id":"a","function":{"arguments":"{}","name":"now"},"type":"function"}],"role":"assistant"}]`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			params, err := BuildOpenAIMessages(tc.messages)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded, err := json.Marshal(params)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(encoded) != tc.want {
+				t.Errorf("got  %s\nwant %s", encoded, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildChatCompletionParamsRejectsForeignContext(t *testing.T) {
+	if _, err := buildChatCompletionParams(&types.ChatRequest{ModelID: "gpt-4o"}, []string{"not messages"}); err == nil {
+		t.Fatal("expected an error for a context built by another client")
+	}
+}
@@ -1,7 +1,16 @@
 package llms
 
 import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/CodingWithKarim/AgentK/internal/keys"
 	"github.com/CodingWithKarim/AgentK/internal/llms/anthropic"
+	"github.com/CodingWithKarim/AgentK/internal/llms/cohere"
+	"github.com/CodingWithKarim/AgentK/internal/llms/google"
+	"github.com/CodingWithKarim/AgentK/internal/llms/keypool"
 	"github.com/CodingWithKarim/AgentK/internal/llms/openaicompatible"
 	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
@@ -10,28 +19,157 @@ import (
 )
 
 type LLMClient interface {
-	Chat(request *types.ChatRequest, context any) (string, error)
-	Models() ([]*types.Model, error)
+	// Encode converts canonical messages into the context Chat and ChatStream take, always encode
+	// with the client that will send the request
+	Encode(messages []types.Message) (any, error)
+	Chat(ctx context.Context, request *types.ChatRequest, contextMessages any) (*types.ChatResult, error)
+	ChatStream(ctx context.Context, request *types.ChatRequest, contextMessages any, onDelta func(delta string) error) (*types.ChatResult, error)
+	Models(ctx context.Context) ([]*types.Model, error)
+}
+
+// FileReader is implemented by clients that can tell whether a model takes file parts,
+// files are replaced with their text for any other model
+type FileReader interface {
+	ReadsFiles(modelID string) bool
 }
 
-var Clients map[types.Provider]LLMClient
+// KeyPooled is implemented by clients that spread requests over several keys
+type KeyPooled interface {
+	KeyPool() *keypool.Pool
+}
+
+var (
+	clients atomic.Pointer[map[types.Provider]LLMClient]
+
+	openAISDKClient    *openaiSDK.Client
+	anthropicSDKClient *anthropicSDK.Client
+)
 
 func InitializeClients(openAIClient *openaiSDK.Client, anthropicClient *anthropicSDK.Client) {
-	Clients = make(map[types.Provider]LLMClient, len(utils.OpenAICompatibleProviders)+1) // +1 for Anthropic
+	openAISDKClient = openAIClient
+	anthropicSDKClient = anthropicClient
+
+	ReloadClients()
+}
+
+// ReloadClients builds a fresh client set from the current keys and settings and swaps it in at once
+func ReloadClients() {
+	SwapClients(BuildClients(utils.CurrentSettings()))
+}
 
-	if key := utils.GetKey(utils.ANTHROPIC); key != "" {
-		Clients[utils.ANTHROPIC] = &anthropic.AnthropicClient{
-			Client: anthropicClient,
+// BuildClients creates a client for every provider in settings that has keys or needs none. Key pools
+// carry over the benched keys of the clients they replace so a reload doesn't retry rejected keys
+func BuildClients(settings *utils.Settings) map[types.Provider]LLMClient {
+	previous := Clients()
+	built := make(map[types.Provider]LLMClient, len(settings.NativeProviders)+len(settings.OpenAICompatibleProviders))
+
+	for _, provider := range settings.ConfiguredProviders() {
+		keys := resolveKeys(settings, provider)
+
+		if len(keys) == 0 && !settings.KeylessProviders[provider] {
+			continue
+		}
+
+		client, err := newClient(settings, provider, keys)
+
+		if err != nil {
+			log.Printf("skipping provider, client setup failed provider=%q err=%v", provider, err)
+			continue
 		}
-	}
 
-	for _, provider := range utils.OpenAICompatibleProviders {
-		if key := utils.GetKey(provider); key != "" {
-			Clients[provider] = &openaicompatible.OpenAIClient{
-				Provider: provider,
-				Client:   openAIClient,
-				Key:      key,
+		if pooled, ok := client.(KeyPooled); ok {
+			if old, ok := previous[provider].(KeyPooled); ok {
+				pooled.KeyPool().Inherit(old.KeyPool())
 			}
 		}
+
+		built[provider] = client
+	}
+
+	return built
+}
+
+// SwapClients replaces the client set. Requests that already looked up a client keep using it until they finish
+func SwapClients(built map[types.Provider]LLMClient) {
+	clients.Store(&built)
+}
+
+// ResolveKeys prefers keys stored through the admin API over the environment
+func ResolveKeys(provider types.Provider) []string {
+	return resolveKeys(utils.CurrentSettings(), provider)
+}
+
+func resolveKeys(settings *utils.Settings, provider types.Provider) []string {
+	if stored, ok := keys.Lookup(provider); ok {
+		return utils.SplitKeys(stored)
+	}
+
+	return utils.GetKeysFrom(settings, provider)
+}
+
+// NewClient builds a client for a configured provider, also used to test a key before it is saved
+func NewClient(provider types.Provider, providerKeys []string) (LLMClient, error) {
+	return newClient(utils.CurrentSettings(), provider, providerKeys)
+}
+
+func newClient(settings *utils.Settings, provider types.Provider, providerKeys []string) (LLMClient, error) {
+	endpoints, ok := settings.ProviderEndpoints[provider]
+
+	if !ok {
+		return nil, utils.ErrProviderNotSupported
+	}
+
+	if settings.IsNativeProvider(provider) {
+		switch provider {
+		case utils.ANTHROPIC:
+			return &anthropic.AnthropicClient{
+				Client:   anthropicSDKClient,
+				Keys:     keypool.New(provider, providerKeys),
+				Endpoint: endpoints.ModelEndpoint,
+				Timeout:  settings.Timeout(provider),
+			}, nil
+		case utils.GOOGLE:
+			return &google.GoogleClient{
+				Keys:    keypool.New(provider, providerKeys),
+				BaseURL: strings.TrimSuffix(endpoints.BaseURL, "/"),
+				Options: google.LoadOptions(),
+				Timeout: settings.Timeout(provider),
+			}, nil
+		case utils.COHERE:
+			return &cohere.CohereClient{
+				Provider:      provider,
+				Keys:          keypool.New(provider, providerKeys),
+				BaseURL:       strings.TrimSuffix(endpoints.BaseURL, "/"),
+				ModelEndpoint: strings.TrimSuffix(endpoints.ModelEndpoint, "/"),
+				Timeout:       settings.Timeout(provider),
+			}, nil
+		}
 	}
+
+	return &openaicompatible.OpenAIClient{
+		Provider:      provider,
+		Client:        openAISDKClient,
+		Keys:          keypool.New(provider, providerKeys),
+		BaseURL:       endpoints.BaseURL,
+		ModelEndpoint: endpoints.ModelEndpoint,
+		Headers:       settings.ProviderHeaders[provider],
+		StaticModels:  settings.StaticModels[provider],
+		Timeout:       settings.Timeout(provider),
+	}, nil
+}
+
+// GetClient returns the client registered for a provider
+func GetClient(provider types.Provider) (LLMClient, bool) {
+	client, ok := Clients()[provider]
+
+	return client, ok
+}
+
+// Clients returns the current client set, callers must treat it as read-only
+func Clients() map[types.Provider]LLMClient {
+	if current := clients.Load(); current != nil {
+		return *current
+	}
+
+	return map[types.Provider]LLMClient{}
 }
@@ -0,0 +1,45 @@
+package llms
+
+import (
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/llms/openaicompatible"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func TestNewClientEndpoints(t *testing.T) {
+	settings := utils.BuiltInSettings()
+	settings.ProviderEndpoints[utils.OPENROUTER] = types.ProviderEndpoints{
+		BaseURL:       "https://gateway.example.com/v1",
+		ModelEndpoint: "https://catalog.example.com/v1",
+	}
+
+	client, err := newClient(settings, utils.OPENROUTER, []string{"key"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openAIClient, ok := client.(*openaicompatible.OpenAIClient)
+
+	if !ok {
+		t.Fatalf("client = %T, want *openaicompatible.OpenAIClient", client)
+	}
+
+	if openAIClient.BaseURL != "https://gateway.example.com/v1" || openAIClient.ModelEndpoint != "https://catalog.example.com/v1" {
+		t.Errorf("chat goes to %q and models to %q", openAIClient.BaseURL, openAIClient.ModelEndpoint)
+	}
+}
+
+func TestBuildClientsSkipsUnknownProviders(t *testing.T) {
+	settings := utils.BuiltInSettings()
+	settings.OpenAICompatibleProviders = append(settings.OpenAICompatibleProviders, "Unlisted")
+	settings.KeylessProviders = map[types.Provider]bool{"Unlisted": true}
+
+	built := BuildClients(settings)
+
+	if _, ok := built["Unlisted"]; ok {
+		t.Error("a provider without endpoints got a client")
+	}
+}
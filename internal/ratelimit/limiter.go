@@ -0,0 +1,256 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// idleBucketTTL is how long an untouched, fully refilled bucket is kept before it is swept
+const idleBucketTTL = 10 * time.Minute
+
+type LimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %s, retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+type Limits struct {
+	RequestsPerMinute float64
+	Burst             float64
+	MaxConcurrent     int
+}
+
+// limitKeys records which limits were set in the environment, a limit set to zero is not the same as one left out
+type limitKeys struct {
+	rpm        bool
+	burst      bool
+	concurrent bool
+}
+
+// Limiter enforces a token bucket and a concurrency cap per caller and provider pair
+type Limiter struct {
+	defaults  Limits
+	providers map[types.Provider]Limits
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	provider types.Provider
+	tokens   float64
+	updated  time.Time
+	inFlight int
+}
+
+var Default *Limiter
+
+// InitializeLimiter builds the limiter from the environment, see NewLimiter
+func InitializeLimiter(providers []types.Provider) error {
+	limiter, err := NewLimiter(providers)
+
+	if err != nil {
+		return err
+	}
+
+	Default = limiter
+
+	return nil
+}
+
+// NewLimiter reads RATE_LIMIT_RPM, RATE_LIMIT_BURST and RATE_LIMIT_CONCURRENT with optional
+// <PROVIDER>_RATE_LIMIT_RPM, _BURST and _CONCURRENT overrides. An override left out takes the global
+// value and zero disables a limit, so <PROVIDER>_RATE_LIMIT_RPM=0 lifts the global rate for that provider
+func NewLimiter(providers []types.Provider) (*Limiter, error) {
+	defaults, _, err := readLimits("RATE_LIMIT")
+
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := &Limiter{
+		defaults:  defaults,
+		providers: map[types.Provider]Limits{},
+		buckets:   map[string]*bucket{},
+	}
+
+	for _, provider := range providers {
+		limits, set, err := readLimits(strings.ToUpper(string(provider)) + "_RATE_LIMIT")
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !set.rpm {
+			limits.RequestsPerMinute = defaults.RequestsPerMinute
+		}
+
+		// A burst follows the rate it was set with, an overridden rate brings its own default burst
+		if !set.burst && !set.rpm {
+			limits.Burst = defaults.Burst
+		}
+
+		if !set.concurrent {
+			limits.MaxConcurrent = defaults.MaxConcurrent
+		}
+
+		limiter.providers[provider] = limits
+	}
+
+	return limiter, nil
+}
+
+// Update takes the limits of next while keeping every caller's bucket, so a reload neither refills
+// spent tokens nor forgets requests in flight. Tokens are capped to a lowered burst
+func (l *Limiter) Update(next *Limiter) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.defaults = next.defaults
+	l.providers = next.providers
+
+	for _, current := range l.buckets {
+		if limits := l.limitsFor(current.provider); limits.RequestsPerMinute > 0 {
+			current.tokens = math.Min(current.tokens, limits.Burst)
+		}
+	}
+}
+
+// Acquire reserves a request slot, the returned release must be called once the call finishes
+func (l *Limiter) Acquire(caller string, provider types.Provider) (release func(), err error) {
+	key := caller + "|" + string(provider)
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limits := l.limitsFor(provider)
+
+	if limits.RequestsPerMinute <= 0 && limits.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	l.sweep(now)
+
+	current, ok := l.buckets[key]
+
+	if !ok {
+		current = &bucket{provider: provider, tokens: limits.Burst, updated: now}
+		l.buckets[key] = current
+	}
+
+	if limits.MaxConcurrent > 0 && current.inFlight >= limits.MaxConcurrent {
+		return nil, &LimitError{
+			Reason:     fmt.Sprintf("%d concurrent %s requests already in flight", current.inFlight, provider),
+			RetryAfter: time.Second,
+		}
+	}
+
+	if limits.RequestsPerMinute > 0 {
+		ratePerSecond := limits.RequestsPerMinute / 60
+
+		current.tokens = math.Min(limits.Burst, current.tokens+now.Sub(current.updated).Seconds()*ratePerSecond)
+		current.updated = now
+
+		if current.tokens < 1 {
+			return nil, &LimitError{
+				Reason:     fmt.Sprintf("more than %g %s requests per minute", limits.RequestsPerMinute, provider),
+				RetryAfter: time.Duration((1 - current.tokens) / ratePerSecond * float64(time.Second)),
+			}
+		}
+
+		current.tokens--
+	}
+
+	current.inFlight++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+
+			current.inFlight--
+		})
+	}, nil
+}
+
+func (l *Limiter) limitsFor(provider types.Provider) Limits {
+	if limits, ok := l.providers[provider]; ok {
+		return limits
+	}
+
+	return l.defaults
+}
+
+// sweep drops idle buckets so the map doesn't grow with every caller ever seen
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, current := range l.buckets {
+		if current.inFlight == 0 && now.Sub(current.updated) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// readLimits reads the limits under prefix and reports which of them were set
+func readLimits(prefix string) (Limits, limitKeys, error) {
+	limits := Limits{}
+	set := limitKeys{}
+
+	if value := os.Getenv(prefix + "_RPM"); value != "" {
+		rpm, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || rpm < 0 {
+			return limits, set, fmt.Errorf("invalid %s_RPM=%q", prefix, value)
+		}
+
+		limits.RequestsPerMinute, limits.Burst = rpm, math.Max(rpm, 1)
+		set.rpm = true
+	}
+
+	if value := os.Getenv(prefix + "_BURST"); value != "" {
+		burst, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || burst < 1 {
+			return limits, set, fmt.Errorf("invalid %s_BURST=%q", prefix, value)
+		}
+
+		limits.Burst = burst
+		set.burst = true
+	}
+
+	if value := os.Getenv(prefix + "_CONCURRENT"); value != "" {
+		concurrent, err := strconv.Atoi(value)
+
+		if err != nil || concurrent < 0 {
+			return limits, set, fmt.Errorf("invalid %s_CONCURRENT=%q", prefix, value)
+		}
+
+		limits.MaxConcurrent = concurrent
+		set.concurrent = true
+	}
+
+	if set.rpm || set.burst || set.concurrent {
+		log.Printf("Rate limits %s rpm=%g burst=%g concurrent=%d", prefix, limits.RequestsPerMinute, limits.Burst, limits.MaxConcurrent)
+	}
+
+	return limits, set, nil
+}
@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func TestNewLimiterOverrides(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "30")
+	t.Setenv("RATE_LIMIT_BURST", "10")
+	t.Setenv("RATE_LIMIT_CONCURRENT", "4")
+	t.Setenv("OPENAI_RATE_LIMIT_RPM", "120")
+	t.Setenv("ANTHROPIC_RATE_LIMIT_RPM", "0")
+	t.Setenv("GOOGLE_RATE_LIMIT_BURST", "2")
+	t.Setenv("GROQ_RATE_LIMIT_CONCURRENT", "0")
+
+	limiter, err := NewLimiter([]types.Provider{"OpenAI", "Anthropic", "Google", "Groq", "Cohere"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		provider types.Provider
+		want     Limits
+	}{
+		{"OpenAI", Limits{RequestsPerMinute: 120, Burst: 120, MaxConcurrent: 4}},
+		{"Anthropic", Limits{RequestsPerMinute: 0, Burst: 1, MaxConcurrent: 4}},
+		{"Google", Limits{RequestsPerMinute: 30, Burst: 2, MaxConcurrent: 4}},
+		{"Groq", Limits{RequestsPerMinute: 30, Burst: 10, MaxConcurrent: 0}},
+		{"Cohere", Limits{RequestsPerMinute: 30, Burst: 10, MaxConcurrent: 4}},
+		{"Unlisted", Limits{RequestsPerMinute: 30, Burst: 10, MaxConcurrent: 4}},
+	}
+
+	for _, tc := range cases {
+		if got := limiter.limitsFor(tc.provider); got != tc.want {
+			t.Errorf("%s limits = %+v, want %+v", tc.provider, got, tc.want)
+		}
+	}
+}
+
+func TestNewLimiterRejectsInvalidValues(t *testing.T) {
+	for _, key := range []string{"RATE_LIMIT_RPM", "OPENAI_RATE_LIMIT_BURST", "OPENAI_RATE_LIMIT_CONCURRENT"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "-1")
+
+			if _, err := NewLimiter([]types.Provider{"OpenAI"}); err == nil {
+				t.Errorf("%s=-1 was accepted", key)
+			}
+		})
+	}
+}
+
+func TestAcquireBurst(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "1")
+	t.Setenv("RATE_LIMIT_BURST", "3")
+
+	limiter, err := NewLimiter(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		release, err := limiter.Acquire("alice", "OpenAI")
+
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+
+		release()
+	}
+
+	var limitErr *LimitError
+
+	if _, err := limiter.Acquire("alice", "OpenAI"); !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+		t.Fatalf("request past the burst: got %v, want a LimitError with a retry delay", err)
+	}
+
+	// Buckets are per caller and provider
+	if _, err := limiter.Acquire("bob", "OpenAI"); err != nil {
+		t.Errorf("another caller was limited: %v", err)
+	}
+
+	if _, err := limiter.Acquire("alice", "Anthropic"); err != nil {
+		t.Errorf("another provider was limited: %v", err)
+	}
+}
+
+func TestAcquireConcurrency(t *testing.T) {
+	t.Setenv("RATE_LIMIT_CONCURRENT", "2")
+
+	limiter, err := NewLimiter(nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := limiter.Acquire("alice", "OpenAI")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := limiter.Acquire("alice", "OpenAI"); err != nil {
+		t.Fatal(err)
+	}
+
+	var limitErr *LimitError
+
+	if _, err := limiter.Acquire("alice", "OpenAI"); !errors.As(err, &limitErr) {
+		t.Fatalf("third concurrent request: got %v, want a LimitError", err)
+	}
+
+	// Releasing twice frees a single slot
+	first()
+	first()
+
+	if _, err := limiter.Acquire("alice", "OpenAI"); err != nil {
+		t.Fatalf("request after a release: %v", err)
+	}
+
+	if _, err := limiter.Acquire("alice", "OpenAI"); !errors.As(err, &limitErr) {
+		t.Errorf("double release freed two slots: got %v", err)
+	}
+}
+
+func TestAcquireZeroDisablesLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "1")
+	t.Setenv("RATE_LIMIT_CONCURRENT", "1")
+	t.Setenv("OPENAI_RATE_LIMIT_RPM", "0")
+	t.Setenv("OPENAI_RATE_LIMIT_CONCURRENT", "0")
+
+	limiter, err := NewLimiter([]types.Provider{"OpenAI"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10 {
+		if _, err := limiter.Acquire("alice", "OpenAI"); err != nil {
+			t.Fatalf("request %d to an unlimited provider: %v", i+1, err)
+		}
+	}
+
+	if _, err := limiter.Acquire("alice", "Anthropic"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := limiter.Acquire("alice", "Anthropic"); err == nil {
+		t.Error("the global limits no longer apply to other providers")
+	}
+}
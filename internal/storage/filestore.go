@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const sessionsFileName = "sessions.json"
+
+// FileStore keeps every session and message in memory and rewrites a single JSON file on each change,
+// a change that fails to save is rolled back so memory never drifts from the file.
+// Every append rewrites the whole file, so a save costs time proportional to all stored history across
+// every owner. That suits a personal or small team server; larger deployments need a SessionStore
+// backed by a database
+type FileStore struct {
+	path  string
+	mutex sync.RWMutex
+	data  fileStoreData
+}
+
+type fileStoreData struct {
+	Sessions      []*storedSession       `json:"sessions"`
+	Messages      []*types.StoredMessage `json:"messages"`
+	NextMessageID int64                  `json:"nextMessageID"`
+}
+
+// storedSession records who created a session, sessions saved before owners existed belong to ""
+type storedSession struct {
+	types.Session
+	Owner string `json:"owner,omitempty"`
+}
+
+func NewFileStore(dataDir string) (*FileStore, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir failed: %w", err)
+	}
+
+	store := &FileStore{
+		path: filepath.Join(dataDir, sessionsFileName),
+		data: fileStoreData{NextMessageID: 1},
+	}
+
+	bytes, err := os.ReadFile(store.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", store.path, err)
+	}
+
+	if err := json.Unmarshal(bytes, &store.data); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", store.path, err)
+	}
+
+	return store, nil
+}
+
+func (s *FileStore) ListSessions(owner string) ([]*types.Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sessions := make([]*types.Session, 0, len(s.data.Sessions))
+
+	for _, session := range s.data.Sessions {
+		if session.Owner != owner {
+			continue
+		}
+
+		copied := session.Session
+		sessions = append(sessions, &copied)
+	}
+
+	// Newest sessions first, matching the frontend ordering
+	slices.SortFunc(sessions, func(a, b *types.Session) int {
+		return cmp.Compare(b.StartedAt, a.StartedAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *FileStore) CreateSession(owner string, name string) (*types.Session, error) {
+	id, err := newID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	session := &storedSession{
+		Session: types.Session{
+			ID:        id,
+			Name:      name,
+			StartedAt: time.Now().UnixMilli(),
+		},
+		Owner: owner,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Sessions = append(s.data.Sessions, session)
+
+	if err := s.save(); err != nil {
+		s.data.Sessions = s.data.Sessions[:len(s.data.Sessions)-1]
+		return nil, err
+	}
+
+	copied := session.Session
+
+	return &copied, nil
+}
+
+func (s *FileStore) RenameSession(owner string, id string, name string) (*types.Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session := s.findSession(owner, id)
+
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	previous := session.Name
+	session.Name = name
+
+	if err := s.save(); err != nil {
+		session.Name = previous
+		return nil, err
+	}
+
+	copied := session.Session
+
+	return &copied, nil
+}
+
+func (s *FileStore) DeleteSession(owner string, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.findSession(owner, id) == nil {
+		return ErrSessionNotFound
+	}
+
+	// DeleteFunc works in place, so it runs on copies the rollback can ignore
+	previousSessions, previousMessages := s.data.Sessions, s.data.Messages
+
+	s.data.Sessions = slices.DeleteFunc(slices.Clone(s.data.Sessions), func(session *storedSession) bool {
+		return session.ID == id
+	})
+
+	s.data.Messages = slices.DeleteFunc(slices.Clone(s.data.Messages), func(message *types.StoredMessage) bool {
+		return message.SessionID == id
+	})
+
+	if err := s.save(); err != nil {
+		s.data.Sessions, s.data.Messages = previousSessions, previousMessages
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) ListMessages(owner string, sessionID string, modelID string) ([]*types.StoredMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.findSession(owner, sessionID) == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	messages := make([]*types.StoredMessage, 0)
+
+	for _, message := range s.data.Messages {
+		if message.SessionID != sessionID || (modelID != "" && message.ModelID != modelID) {
+			continue
+		}
+
+		copied := *message
+		messages = append(messages, &copied)
+	}
+
+	slices.SortStableFunc(messages, func(a, b *types.StoredMessage) int {
+		return cmp.Compare(a.TS, b.TS)
+	})
+
+	return messages, nil
+}
+
+func (s *FileStore) AddMessage(owner string, message *types.StoredMessage) (*types.StoredMessage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := ValidateMessage(message); err != nil {
+		return nil, err
+	}
+
+	if s.findSession(owner, message.SessionID) == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	stored := *message
+	stored.ID = s.data.NextMessageID
+
+	if stored.TS == 0 {
+		stored.TS = time.Now().UnixMilli()
+	}
+
+	s.data.NextMessageID++
+	s.data.Messages = append(s.data.Messages, &stored)
+
+	if err := s.save(); err != nil {
+		s.data.NextMessageID--
+		s.data.Messages = s.data.Messages[:len(s.data.Messages)-1]
+		return nil, err
+	}
+
+	copied := stored
+
+	return &copied, nil
+}
+
+func (s *FileStore) UpdateMessage(owner string, message *types.StoredMessage) (*types.StoredMessage, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.findSession(owner, message.SessionID) == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	stored := s.findMessage(message.SessionID, message.ID)
+
+	if stored == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	previous := *stored
+
+	// Only provided mutable fields change, identity and ordering stay intact
+	if message.ModelID != "" {
+		stored.ModelID = message.ModelID
+	}
+
+	if message.ModelName != "" {
+		stored.ModelName = message.ModelName
+	}
+
+	if len(message.Content) > 0 {
+		stored.Content = message.Content
+	}
+
+	if err := ValidateMessage(stored); err != nil {
+		*stored = previous
+		return nil, err
+	}
+
+	if err := s.save(); err != nil {
+		*stored = previous
+		return nil, err
+	}
+
+	copied := *stored
+
+	return &copied, nil
+}
+
+func (s *FileStore) DeleteMessage(owner string, sessionID string, messageID int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.findSession(owner, sessionID) == nil {
+		return ErrSessionNotFound
+	}
+
+	if s.findMessage(sessionID, messageID) == nil {
+		return ErrMessageNotFound
+	}
+
+	previous := s.data.Messages
+
+	s.data.Messages = slices.DeleteFunc(slices.Clone(s.data.Messages), func(message *types.StoredMessage) bool {
+		return message.SessionID == sessionID && message.ID == messageID
+	})
+
+	if err := s.save(); err != nil {
+		s.data.Messages = previous
+		return err
+	}
+
+	return nil
+}
+
+// findSession only finds the owner's sessions, anyone else's look missing rather than forbidden
+func (s *FileStore) findSession(owner string, id string) *storedSession {
+	for _, session := range s.data.Sessions {
+		if session.ID == id && session.Owner == owner {
+			return session
+		}
+	}
+
+	return nil
+}
+
+func (s *FileStore) findMessage(sessionID string, messageID int64) *types.StoredMessage {
+	for _, message := range s.data.Messages {
+		if message.SessionID == sessionID && message.ID == messageID {
+			return message
+		}
+	}
+
+	return nil
+}
+
+// save writes to a temp file and renames it so a crash never leaves a half-written store
+func (s *FileStore) save() error {
+	bytes, err := json.Marshal(s.data)
+
+	if err != nil {
+		return err
+	}
+
+	tempPath := s.path + ".tmp"
+
+	if err := os.WriteFile(tempPath, bytes, 0o600); err != nil {
+		return fmt.Errorf("write %s failed: %w", tempPath, err)
+	}
+
+	return os.Rename(tempPath, s.path)
+}
+
+func newID() (string, error) {
+	bytes := make([]byte, 16)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	// Format as an RFC 4122 version 4 UUID to match crypto.randomUUID on the frontend
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
+
+	encoded := hex.EncodeToString(bytes)
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s", encoded[0:8], encoded[8:12], encoded[12:16], encoded[16:20], encoded[20:]), nil
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+
+	store, err := NewFileStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// breakSaves puts a directory where the store file goes so every later save fails to rename into place
+func breakSaves(t *testing.T, store *FileStore) {
+	t.Helper()
+
+	if err := os.Remove(store.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(store.path, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addMessage(t *testing.T, store *FileStore, owner string, sessionID string, text string) *types.StoredMessage {
+	t.Helper()
+
+	message, err := store.AddMessage(owner, &types.StoredMessage{SessionID: sessionID, Role: "user", Content: json.RawMessage(`"` + text + `"`)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return message
+}
+
+func TestFileStoreKeepsOwnersApart(t *testing.T) {
+	store := newTestStore(t)
+
+	alice, err := store.CreateSession("alice", "plans")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.CreateSession("bob", "notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	message := addMessage(t, store, "alice", alice.ID, "hi")
+
+	sessions, err := store.ListSessions("bob")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 1 || sessions[0].Name != "notes" {
+		t.Fatalf("bob sees %+v, want only his own session", sessions)
+	}
+
+	if _, err := store.ListMessages("bob", alice.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("ListMessages: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if _, err := store.AddMessage("bob", &types.StoredMessage{SessionID: alice.ID, Role: "user", Content: json.RawMessage(`"x"`)}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("AddMessage: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if _, err := store.UpdateMessage("bob", &types.StoredMessage{SessionID: alice.ID, ID: message.ID, Content: json.RawMessage(`"x"`)}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("UpdateMessage: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if err := store.DeleteMessage("bob", alice.ID, message.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("DeleteMessage: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if _, err := store.RenameSession("bob", alice.ID, "mine"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RenameSession: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	if err := store.DeleteSession("bob", alice.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("DeleteSession: got %v, want %v", err, ErrSessionNotFound)
+	}
+
+	// Owners survive a reload from disk
+	reloaded, err := NewFileStore(filepath.Dir(store.path))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := reloaded.ListMessages("alice", alice.ID, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || string(messages[0].Content) != `"hi"` {
+		t.Errorf("alice's messages after reload = %+v", messages)
+	}
+}
+
+func TestFileStoreRollsBackFailedSaves(t *testing.T) {
+	store := newTestStore(t)
+
+	session, err := store.CreateSession("", "kept")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := addMessage(t, store, "", session.ID, "one")
+	addMessage(t, store, "", session.ID, "two")
+
+	breakSaves(t, store)
+
+	if _, err := store.CreateSession("", "lost"); err == nil {
+		t.Error("CreateSession: expected a save error")
+	}
+
+	if _, err := store.RenameSession("", session.ID, "lost"); err == nil {
+		t.Error("RenameSession: expected a save error")
+	}
+
+	if _, err := store.AddMessage("", &types.StoredMessage{SessionID: session.ID, Role: "user", Content: json.RawMessage(`"lost"`)}); err == nil {
+		t.Error("AddMessage: expected a save error")
+	}
+
+	if _, err := store.UpdateMessage("", &types.StoredMessage{SessionID: session.ID, ID: first.ID, ModelID: "lost", Content: json.RawMessage(`"lost"`)}); err == nil {
+		t.Error("UpdateMessage: expected a save error")
+	}
+
+	if err := store.DeleteMessage("", session.ID, first.ID); err == nil {
+		t.Error("DeleteMessage: expected a save error")
+	}
+
+	if err := store.DeleteSession("", session.ID); err == nil {
+		t.Error("DeleteSession: expected a save error")
+	}
+
+	sessions, err := store.ListSessions("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sessions) != 1 || sessions[0].Name != "kept" {
+		t.Fatalf("sessions after failed saves = %+v", sessions)
+	}
+
+	messages, err := store.ListMessages("", session.ID, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 || string(messages[0].Content) != `"one"` || messages[0].ModelID != "" || string(messages[1].Content) != `"two"` {
+		t.Fatalf("messages after failed saves = %+v", messages)
+	}
+
+	// The next message reuses the ID the failed add gave back
+	if err := os.RemoveAll(store.path); err != nil {
+		t.Fatal(err)
+	}
+
+	if third := addMessage(t, store, "", session.ID, "three"); third.ID != first.ID+2 {
+		t.Errorf("next message ID = %d, want %d", third.ID, first.ID+2)
+	}
+}
+
+func TestFileStoreValidatesMessages(t *testing.T) {
+	store := newTestStore(t)
+
+	session, err := store.CreateSession("", "checked")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := addMessage(t, store, "", session.ID, "kept")
+
+	invalidAdds := []*types.StoredMessage{
+		{SessionID: session.ID, Role: "system", Content: json.RawMessage(`"x"`)},
+		{SessionID: session.ID, Role: "user"},
+		{SessionID: session.ID, Role: "user", Content: json.RawMessage(`null`)},
+	}
+
+	for _, invalid := range invalidAdds {
+		if _, err := store.AddMessage("", invalid); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("AddMessage(%+v): got %v, want %v", invalid, err, ErrInvalidMessage)
+		}
+	}
+
+	if _, err := store.UpdateMessage("", &types.StoredMessage{SessionID: session.ID, ID: message.ID, ModelID: "lost", Content: json.RawMessage(`null`)}); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("UpdateMessage: got %v, want %v", err, ErrInvalidMessage)
+	}
+
+	messages, err := store.ListMessages("", session.ID, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || string(messages[0].Content) != `"kept"` || messages[0].ModelID != "" {
+		t.Fatalf("messages after invalid writes = %+v", messages)
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// SessionStore keeps each owner's sessions apart, the owner is the authenticated user or "" when auth is
+// disabled. Sessions of another owner are reported as not found
+type SessionStore interface {
+	ListSessions(owner string) ([]*types.Session, error)
+	CreateSession(owner string, name string) (*types.Session, error)
+	RenameSession(owner string, id string, name string) (*types.Session, error)
+	DeleteSession(owner string, id string) error
+
+	// ListMessages returns a session's messages ordered by timestamp, optionally filtered to one model
+	ListMessages(owner string, sessionID string, modelID string) ([]*types.StoredMessage, error)
+	AddMessage(owner string, message *types.StoredMessage) (*types.StoredMessage, error)
+	UpdateMessage(owner string, message *types.StoredMessage) (*types.StoredMessage, error)
+	DeleteMessage(owner string, sessionID string, messageID int64) error
+}
+
+var Sessions SessionStore
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrMessageNotFound = errors.New("message not found")
+	ErrInvalidMessage  = errors.New("invalid message")
+)
+
+// ValidateMessage checks the fields every stored message needs, stores run it on adds and on the
+// result of updates
+func ValidateMessage(message *types.StoredMessage) error {
+	if message.Role != "user" && message.Role != "assistant" {
+		return fmt.Errorf("%w: role must be user or assistant", ErrInvalidMessage)
+	}
+
+	if len(message.Content) == 0 || string(message.Content) == "null" {
+		return fmt.Errorf("%w: content is required", ErrInvalidMessage)
+	}
+
+	return nil
+}
+
+func InitializeStore(dataDir string) error {
+	store, err := NewFileStore(dataDir)
+
+	if err != nil {
+		return err
+	}
+
+	Sessions = store
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+var Budgets []types.Budget
+
+var ErrBudgetExceeded = errors.New("spend budget exceeded")
+
+// ErrUnpricedModel refuses a model with no known price while a budget covers it, its spend would record as $0
+var ErrUnpricedModel = errors.New("model has no known price")
+
+// LoadBudgets parses the budgets and swaps them in, see ParseBudgets
+func LoadBudgets() error {
+	budgets, err := ParseBudgets()
+
+	if err != nil {
+		return err
+	}
+
+	SetBudgets(budgets)
+
+	return nil
+}
+
+// ParseBudgets reads BUDGET_DAILY_USD and BUDGET_MONTHLY_USD as global caps plus any
+// provider or model scoped caps listed in the JSON file at BUDGETS_FILE
+func ParseBudgets() ([]types.Budget, error) {
+	budgets := make([]types.Budget, 0)
+
+	for period, name := range map[string]string{PeriodDaily: "BUDGET_DAILY_USD", PeriodMonthly: "BUDGET_MONTHLY_USD"} {
+		value := os.Getenv(name)
+
+		if value == "" {
+			continue
+		}
+
+		limit, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s=%q: %w", name, value, err)
+		}
+
+		budgets = append(budgets, types.Budget{Period: period, LimitUSD: limit})
+	}
+
+	if path := os.Getenv("BUDGETS_FILE"); path != "" {
+		bytes, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("read %s failed: %w", path, err)
+		}
+
+		var fileBudgets []types.Budget
+
+		if err := json.Unmarshal(bytes, &fileBudgets); err != nil {
+			return nil, fmt.Errorf("parse %s failed: %w", path, err)
+		}
+
+		budgets = append(budgets, fileBudgets...)
+	}
+
+	for _, budget := range budgets {
+		if budget.Period != PeriodDaily && budget.Period != PeriodMonthly {
+			return nil, fmt.Errorf("budget period must be %q or %q, got %q", PeriodDaily, PeriodMonthly, budget.Period)
+		}
+	}
+
+	return budgets, nil
+}
+
+// SetBudgets swaps the budgets, reservations already held keep counting against the new ones
+func SetBudgets(budgets []types.Budget) {
+	reservationsMutex.Lock()
+	defer reservationsMutex.Unlock()
+
+	Budgets = budgets
+}
+
+// Reservation holds a call's estimated cost against the budgets until its actual cost is recorded, so
+// concurrent calls can't all pass the check and overshoot together
+type Reservation struct {
+	provider types.Provider
+	modelID  string
+	costUSD  float64
+}
+
+var (
+	reservationsMutex sync.Mutex
+	reservations      = map[*Reservation]struct{}{}
+)
+
+// Reserve refuses a call once any budget covering the provider and model would be spent by it and the
+// calls still in flight, otherwise it holds estimateUSD until the reservation is settled
+func Reserve(provider types.Provider, modelID string, estimateUSD float64) (*Reservation, error) {
+	reservationsMutex.Lock()
+	defer reservationsMutex.Unlock()
+
+	for _, status := range budgetStatuses(time.Now()) {
+		if !budgetCovers(status.Budget, provider, modelID) {
+			continue
+		}
+
+		committed := status.SpentUSD + status.ReservedUSD
+
+		if committed < status.LimitUSD && committed+estimateUSD <= status.LimitUSD {
+			continue
+		}
+
+		return nil, fmt.Errorf(
+			"%w: %s limit of $%.2f%s reached ($%.2f spent, $%.2f in flight, this call may cost $%.4f)",
+			ErrBudgetExceeded,
+			status.Period,
+			status.LimitUSD,
+			budgetScope(status.Budget),
+			status.SpentUSD,
+			status.ReservedUSD,
+			estimateUSD,
+		)
+	}
+
+	reservation := &Reservation{provider: provider, modelID: modelID, costUSD: estimateUSD}
+	reservations[reservation] = struct{}{}
+
+	return reservation, nil
+}
+
+// Settle releases the hold once the call's actual cost is in the ledger or the call failed
+func (r *Reservation) Settle() {
+	reservationsMutex.Lock()
+	defer reservationsMutex.Unlock()
+
+	delete(reservations, r)
+}
+
+// BudgetStatuses reports how much of each configured budget is spent in its current period
+func BudgetStatuses(now time.Time) []*types.BudgetStatus {
+	reservationsMutex.Lock()
+	defer reservationsMutex.Unlock()
+
+	return budgetStatuses(now)
+}
+
+// budgetStatuses expects reservationsMutex to be held
+func budgetStatuses(now time.Time) []*types.BudgetStatus {
+	statuses := make([]*types.BudgetStatus, 0, len(Budgets))
+
+	if Records == nil {
+		return statuses
+	}
+
+	for _, budget := range Budgets {
+		status := &types.BudgetStatus{Budget: budget}
+
+		for key, costUSD := range Records.Spend(budget.Period, now) {
+			if budgetCovers(budget, key.Provider, key.Model) {
+				status.SpentUSD += costUSD
+			}
+		}
+
+		for reservation := range reservations {
+			if budgetCovers(budget, reservation.provider, reservation.modelID) {
+				status.ReservedUSD += reservation.costUSD
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// Covers reports whether any budget applies to calls to the model
+func Covers(provider types.Provider, modelID string) bool {
+	reservationsMutex.Lock()
+	defer reservationsMutex.Unlock()
+
+	for _, budget := range Budgets {
+		if budgetCovers(budget, provider, modelID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Provider names are matched case-insensitively so "openai" in a budgets file covers OpenAI, and
+// a model budget covers the model's pinned snapshots, which providers report as the model served
+func budgetCovers(budget types.Budget, provider types.Provider, modelID string) bool {
+	if budget.Provider != "" && !strings.EqualFold(string(budget.Provider), string(provider)) {
+		return false
+	}
+
+	return budget.Model == "" || utils.ModelSnapshotOf(modelID, budget.Model)
+}
+
+func budgetScope(budget types.Budget) string {
+	switch {
+	case budget.Model != "":
+		return fmt.Sprintf(" for %s %s", budget.Provider, budget.Model)
+	case budget.Provider != "":
+		return fmt.Sprintf(" for %s", budget.Provider)
+	default:
+		return ""
+	}
+}
+
+// Periods follow UTC calendar days and months
+func periodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+
+	if period == PeriodMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
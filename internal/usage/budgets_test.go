@@ -0,0 +1,237 @@
+package usage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func useLedger(t *testing.T, budgets []types.Budget, records ...*types.UsageRecord) *FileLedger {
+	t.Helper()
+
+	ledger, err := NewFileLedger(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records {
+		if err := ledger.Record(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousRecords, previousBudgets := Records, Budgets
+	Records, Budgets = ledger, budgets
+
+	t.Cleanup(func() {
+		Records, Budgets = previousRecords, previousBudgets
+	})
+
+	return ledger
+}
+
+func TestReserveHoldsInFlightCost(t *testing.T) {
+	ledger := useLedger(t, []types.Budget{{Period: PeriodDaily, LimitUSD: 1}})
+
+	var (
+		mutex    sync.Mutex
+		admitted []*Reservation
+		refused  int
+		group    sync.WaitGroup
+	)
+
+	// Ten concurrent calls estimated at $0.30 each, only three fit under $1
+	for range 10 {
+		group.Add(1)
+
+		go func() {
+			defer group.Done()
+
+			reservation, err := Reserve("OpenAI", "gpt-4o", 0.3)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if errors.Is(err, ErrBudgetExceeded) {
+				refused++
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			admitted = append(admitted, reservation)
+		}()
+	}
+
+	group.Wait()
+
+	if len(admitted) != 3 || refused != 7 {
+		t.Fatalf("admitted %d and refused %d calls, want 3 and 7", len(admitted), refused)
+	}
+
+	if reserved := BudgetStatuses(time.Now())[0].ReservedUSD; reserved < 0.89 || reserved > 0.91 {
+		t.Errorf("reserved $%.2f, want $0.90", reserved)
+	}
+
+	// Settling swaps the estimates for the actual costs recorded
+	for _, reservation := range admitted {
+		if err := ledger.Record(&types.UsageRecord{Timestamp: time.Now().UnixMilli(), Provider: "OpenAI", Model: "gpt-4o", CostUSD: 0.1}); err != nil {
+			t.Fatal(err)
+		}
+
+		reservation.Settle()
+	}
+
+	status := BudgetStatuses(time.Now())[0]
+
+	if status.ReservedUSD != 0 || status.SpentUSD < 0.29 || status.SpentUSD > 0.31 {
+		t.Errorf("after settling spent $%.2f with $%.2f reserved, want $0.30 and nothing reserved", status.SpentUSD, status.ReservedUSD)
+	}
+
+	if _, err := Reserve("OpenAI", "gpt-4o", 0.3); err != nil {
+		t.Errorf("a call that fits after settling was refused: %v", err)
+	}
+}
+
+func TestReserveRefusesSpentBudgets(t *testing.T) {
+	now := time.Now().UnixMilli()
+
+	useLedger(t,
+		[]types.Budget{
+			{Provider: "openai", Period: PeriodDaily, LimitUSD: 1},
+			{Provider: "Anthropic", Model: "claude-sonnet-4-0", Period: PeriodMonthly, LimitUSD: 5},
+		},
+		&types.UsageRecord{Timestamp: now, Provider: "OpenAI", Model: "gpt-4o", CostUSD: 1},
+		&types.UsageRecord{Timestamp: now, Provider: "Anthropic", Model: "claude-sonnet-4-0", CostUSD: 4.99},
+	)
+
+	cases := []struct {
+		name     string
+		provider types.Provider
+		modelID  string
+		estimate float64
+		refused  bool
+	}{
+		{"provider budget named in another case", "OpenAI", "gpt-4o-mini", 0, true},
+		{"model budget the call would overshoot", "Anthropic", "claude-sonnet-4-0", 0.02, true},
+		{"model budget the call fits", "Anthropic", "claude-sonnet-4-0", 0.01, false},
+		{"model outside the budget", "Anthropic", "claude-opus-4-1", 10, false},
+		{"provider without a budget", "Groq", "llama-3.3-70b-versatile", 10, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reservation, err := Reserve(tc.provider, tc.modelID, tc.estimate)
+
+			if tc.refused != errors.Is(err, ErrBudgetExceeded) {
+				t.Fatalf("refused = %v (%v), want %v", err != nil, err, tc.refused)
+			}
+
+			if reservation != nil {
+				reservation.Settle()
+			}
+		})
+	}
+}
+
+func TestBuildUserReport(t *testing.T) {
+	now := time.Now().UnixMilli()
+
+	useLedger(t,
+		[]types.Budget{{Period: PeriodDaily, LimitUSD: 10}},
+		&types.UsageRecord{Timestamp: now, User: "alice", Provider: "OpenAI", Model: "gpt-4o", CostUSD: 1},
+		&types.UsageRecord{Timestamp: now, User: "bob", Provider: "OpenAI", Model: "gpt-4o", CostUSD: 2},
+		&types.UsageRecord{Timestamp: now, User: "alice", Provider: "Google", Model: "gemini-2.5-flash", CostUSD: 0.5},
+	)
+
+	report, err := BuildUserReport(0, 0, "alice")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Totals.Requests != 2 || report.Totals.CostUSD != 1.5 {
+		t.Errorf("alice's totals = %+v, want 2 requests costing $1.50", report.Totals)
+	}
+
+	if len(report.ByUser) != 1 || report.ByUser[0].User != "alice" || len(report.Budgets) != 0 {
+		t.Errorf("alice's report shows users %+v and budgets %+v, want only her own spend", report.ByUser, report.Budgets)
+	}
+
+	full, err := BuildReport(0, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if full.Totals.Requests != 3 || len(full.ByUser) != 2 || len(full.Budgets) != 1 || full.Budgets[0].SpentUSD != 3.5 {
+		t.Errorf("full report = %+v with budgets %+v", full.Totals, full.Budgets)
+	}
+}
+
+func TestBudgetStatusesUseRunningTotals(t *testing.T) {
+	now := time.Now()
+	lastMonth := periodStart(PeriodMonthly, now).Add(-time.Hour).UnixMilli()
+
+	ledger := useLedger(t,
+		[]types.Budget{
+			{Period: PeriodDaily, LimitUSD: 10},
+			{Period: PeriodMonthly, LimitUSD: 100},
+			{Provider: "OpenAI", Model: "gpt-4o", Period: PeriodMonthly, LimitUSD: 5},
+		},
+		&types.UsageRecord{Timestamp: now.UnixMilli(), Provider: "OpenAI", Model: "gpt-4o-2024-08-06", CostUSD: 1},
+		&types.UsageRecord{Timestamp: now.UnixMilli(), Provider: "OpenAI", Model: "gpt-4o-mini", CostUSD: 2},
+		&types.UsageRecord{Timestamp: lastMonth, Provider: "OpenAI", Model: "gpt-4o", CostUSD: 50},
+	)
+
+	// Totals loaded from disk match the ones kept while recording
+	reloaded, err := NewFileLedger(filepath.Dir(ledger.path))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Records = reloaded
+
+	if err := reloaded.Record(&types.UsageRecord{Timestamp: now.UnixMilli(), Provider: "Anthropic", Model: "claude-sonnet-4-0", CostUSD: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := BudgetStatuses(now)
+	want := []float64{7, 7, 1}
+
+	for i, status := range statuses {
+		if status.SpentUSD != want[i] {
+			t.Errorf("%s budget%s spent $%.2f, want $%.2f", status.Period, budgetScope(status.Budget), status.SpentUSD, want[i])
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	useLedger(t, []types.Budget{{Provider: "Anthropic", Model: "claude-sonnet-4", Period: PeriodDaily, LimitUSD: 1}})
+
+	cases := []struct {
+		provider types.Provider
+		modelID  string
+		want     bool
+	}{
+		{"Anthropic", "claude-sonnet-4", true},
+		{"anthropic", "claude-sonnet-4-20250514", true},
+		{"Anthropic", "claude-sonnet-4-5", false},
+		{"OpenAI", "gpt-4o", false},
+	}
+
+	for _, tc := range cases {
+		if got := Covers(tc.provider, tc.modelID); got != tc.want {
+			t.Errorf("Covers(%s, %s) = %v, want %v", tc.provider, tc.modelID, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const ledgerFileName = "usage.jsonl"
+
+type Ledger interface {
+	Record(record *types.UsageRecord) error
+	// Records returns every record with a timestamp in [from, to), zero bounds are open
+	Records(from int64, to int64) ([]*types.UsageRecord, error)
+	// Spend returns the cost recorded per provider and model in the daily or monthly period containing now
+	Spend(period string, now time.Time) map[SpendKey]float64
+}
+
+type SpendKey struct {
+	Provider types.Provider
+	Model    string
+}
+
+var Records Ledger
+
+func InitializeLedger(dataDir string) error {
+	ledger, err := NewFileLedger(dataDir)
+
+	if err != nil {
+		return err
+	}
+
+	Records = ledger
+
+	return nil
+}
+
+// FileLedger appends one JSON line per call and keeps every record in memory for aggregation, along
+// with running spend totals per period so budget checks don't rescan the records
+type FileLedger struct {
+	path    string
+	mutex   sync.RWMutex
+	records []*types.UsageRecord
+	spend   map[string]map[SpendKey]float64
+}
+
+func NewFileLedger(dataDir string) (*FileLedger, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data dir failed: %w", err)
+	}
+
+	ledger := &FileLedger{
+		path:  filepath.Join(dataDir, ledgerFileName),
+		spend: map[string]map[SpendKey]float64{},
+	}
+
+	file, err := os.Open(ledger.path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", ledger.path, err)
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		record := &types.UsageRecord{}
+
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("parse %s failed: %w", ledger.path, err)
+		}
+
+		ledger.records = append(ledger.records, record)
+		ledger.addSpend(record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s failed: %w", ledger.path, err)
+	}
+
+	return ledger, nil
+}
+
+func (l *FileLedger) Record(record *types.UsageRecord) error {
+	line, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+
+	if err != nil {
+		return fmt.Errorf("open %s failed: %w", l.path, err)
+	}
+
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write %s failed: %w", l.path, err)
+	}
+
+	copied := *record
+	l.records = append(l.records, &copied)
+	l.addSpend(&copied)
+
+	return nil
+}
+
+func (l *FileLedger) Records(from int64, to int64) ([]*types.UsageRecord, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	records := make([]*types.UsageRecord, 0)
+
+	for _, record := range l.records {
+		if (from != 0 && record.Timestamp < from) || (to != 0 && record.Timestamp >= to) {
+			continue
+		}
+
+		copied := *record
+		records = append(records, &copied)
+	}
+
+	return records, nil
+}
+
+func (l *FileLedger) Spend(period string, now time.Time) map[SpendKey]float64 {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	totals := l.spend[periodKey(period, now)]
+	spend := make(map[SpendKey]float64, len(totals))
+
+	for key, costUSD := range totals {
+		spend[key] = costUSD
+	}
+
+	return spend
+}
+
+// addSpend adds a record to the totals of the day and month it falls in, the caller holds the lock
+func (l *FileLedger) addSpend(record *types.UsageRecord) {
+	timestamp := time.UnixMilli(record.Timestamp)
+	key := SpendKey{Provider: record.Provider, Model: record.Model}
+
+	for _, period := range []string{PeriodDaily, PeriodMonthly} {
+		name := periodKey(period, timestamp)
+
+		if l.spend[name] == nil {
+			l.spend[name] = map[SpendKey]float64{}
+		}
+
+		l.spend[name][key] += record.CostUSD
+	}
+}
+
+// periodKey names the period containing t, e.g. "daily:2025-06-01" or "monthly:2025-06-01"
+func periodKey(period string, t time.Time) string {
+	return period + ":" + periodStart(period, t).Format(time.DateOnly)
+}
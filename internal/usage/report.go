@@ -0,0 +1,113 @@
+package usage
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+type Report struct {
+	Totals     *types.UsageTotals    `json:"totals"`
+	ByProvider []*types.UsageTotals  `json:"byProvider"`
+	ByModel    []*types.UsageTotals  `json:"byModel"`
+	ByDay      []*types.UsageTotals  `json:"byDay"`
+	ByUser     []*types.UsageTotals  `json:"byUser"`
+	Budgets    []*types.BudgetStatus `json:"budgets"`
+}
+
+// BuildReport aggregates every user's records in [from, to) given as unix milliseconds, zero bounds are open
+func BuildReport(from int64, to int64) (*Report, error) {
+	records, err := Records.Records(from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := aggregate(records)
+	report.Budgets = BudgetStatuses(time.Now())
+
+	return report, nil
+}
+
+// BuildUserReport aggregates one user's records, budgets are left out since their spend covers every user
+func BuildUserReport(from int64, to int64, user string) (*Report, error) {
+	records, err := Records.Records(from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	records = slices.DeleteFunc(records, func(record *types.UsageRecord) bool {
+		return record.User != user
+	})
+
+	return aggregate(records), nil
+}
+
+func aggregate(records []*types.UsageRecord) *Report {
+	totals := &types.UsageTotals{}
+	byProvider := map[types.Provider]*types.UsageTotals{}
+	byModel := map[string]*types.UsageTotals{}
+	byDay := map[string]*types.UsageTotals{}
+	byUser := map[string]*types.UsageTotals{}
+
+	for _, record := range records {
+		day := time.UnixMilli(record.Timestamp).UTC().Format(time.DateOnly)
+
+		addRecord(totals, record)
+		addRecord(getOrCreate(byProvider, record.Provider, &types.UsageTotals{Provider: record.Provider}), record)
+		addRecord(getOrCreate(byModel, string(record.Provider)+"/"+record.Model, &types.UsageTotals{Provider: record.Provider, Model: record.Model}), record)
+		addRecord(getOrCreate(byDay, day, &types.UsageTotals{Day: day}), record)
+		addRecord(getOrCreate(byUser, record.User, &types.UsageTotals{User: record.User}), record)
+	}
+
+	report := &Report{
+		Totals:     totals,
+		ByProvider: sortedTotals(byProvider),
+		ByModel:    sortedTotals(byModel),
+		ByDay:      sortedTotals(byDay),
+		ByUser:     sortedTotals(byUser),
+		Budgets:    []*types.BudgetStatus{},
+	}
+
+	// Days read best in calendar order, everything else by spend
+	slices.SortFunc(report.ByDay, func(a, b *types.UsageTotals) int {
+		return cmp.Compare(a.Day, b.Day)
+	})
+
+	return report
+}
+
+func addRecord(totals *types.UsageTotals, record *types.UsageRecord) {
+	totals.Requests++
+	totals.InputTokens += record.InputTokens
+	totals.OutputTokens += record.OutputTokens
+	totals.CachedTokens += record.CachedTokens
+	totals.CostUSD += record.CostUSD
+}
+
+func getOrCreate[K comparable](groups map[K]*types.UsageTotals, key K, empty *types.UsageTotals) *types.UsageTotals {
+	if totals, ok := groups[key]; ok {
+		return totals
+	}
+
+	groups[key] = empty
+
+	return empty
+}
+
+func sortedTotals[K comparable](groups map[K]*types.UsageTotals) []*types.UsageTotals {
+	results := make([]*types.UsageTotals, 0, len(groups))
+
+	for _, totals := range groups {
+		results = append(results, totals)
+	}
+
+	slices.SortFunc(results, func(a, b *types.UsageTotals) int {
+		return cmp.Compare(b.CostUSD, a.CostUSD)
+	})
+
+	return results
+}
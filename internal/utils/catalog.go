@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+var (
+	textOnly      = []string{"text"}
+	textImage     = []string{"text", "image"}
+	textImageFile = []string{"text", "image", "file"}
+	multimodal    = []string{"text", "image", "audio", "video", "file"}
+)
+
+// ModelCatalogMap fills in capabilities for models whose provider doesn't report them. It is keyed
+// by model ID prefix and the longest matching prefix wins, variants share their family's limits
+var ModelCatalogMap = map[types.Provider]map[string]types.ModelCapabilities{
+	OPENAI: {
+		"gpt-5":          {ContextLength: 400_000, MaxOutputTokens: 128_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gpt-4.1":        {ContextLength: 1_047_576, MaxOutputTokens: 32_768, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gpt-4o":         {ContextLength: 128_000, MaxOutputTokens: 16_384, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gpt-4-turbo":    {ContextLength: 128_000, MaxOutputTokens: 4_096, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gpt-3.5":        {ContextLength: 16_385, MaxOutputTokens: 4_096, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"o1":             {ContextLength: 200_000, MaxOutputTokens: 100_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"o3":             {ContextLength: 200_000, MaxOutputTokens: 100_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"o3-mini":        {ContextLength: 200_000, MaxOutputTokens: 100_000, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"o4-mini":        {ContextLength: 200_000, MaxOutputTokens: 100_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gpt-image-1":    {InputModalities: textImage, OutputModalities: []string{"image"}},
+		"dall-e":         {InputModalities: textOnly, OutputModalities: []string{"image"}},
+		"whisper":        {InputModalities: []string{"audio"}, OutputModalities: textOnly},
+		"tts":            {InputModalities: textOnly, OutputModalities: []string{"audio"}},
+		"text-embedding": {ContextLength: 8_191, InputModalities: textOnly},
+	},
+	ANTHROPIC: {
+		"claude-opus-4":     {ContextLength: 200_000, MaxOutputTokens: 32_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true},
+		"claude-sonnet-4":   {ContextLength: 200_000, MaxOutputTokens: 64_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true},
+		"claude-haiku-4-5":  {ContextLength: 200_000, MaxOutputTokens: 64_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true},
+		"claude-3-7-sonnet": {ContextLength: 200_000, MaxOutputTokens: 64_000, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true},
+		"claude-3-5-sonnet": {ContextLength: 200_000, MaxOutputTokens: 8_192, InputModalities: textImageFile, OutputModalities: textOnly, SupportsTools: true},
+		"claude-3-5-haiku":  {ContextLength: 200_000, MaxOutputTokens: 8_192, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true},
+		"claude-3-opus":     {ContextLength: 200_000, MaxOutputTokens: 4_096, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true},
+		"claude-3-haiku":    {ContextLength: 200_000, MaxOutputTokens: 4_096, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true},
+	},
+	GOOGLE: {
+		"gemini-2.5-pro":         {ContextLength: 1_048_576, MaxOutputTokens: 65_536, InputModalities: multimodal, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gemini-2.5-flash":       {ContextLength: 1_048_576, MaxOutputTokens: 65_536, InputModalities: multimodal, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gemini-2.5-flash-image": {ContextLength: 32_768, MaxOutputTokens: 32_768, InputModalities: textImage, OutputModalities: textImage, SupportsJSON: true},
+		"gemini-2.0-flash":       {ContextLength: 1_048_576, MaxOutputTokens: 8_192, InputModalities: multimodal, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gemini-1.5-pro":         {ContextLength: 2_097_152, MaxOutputTokens: 8_192, InputModalities: multimodal, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gemini-1.5-flash":       {ContextLength: 1_048_576, MaxOutputTokens: 8_192, InputModalities: multimodal, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"gemma-3":                {ContextLength: 131_072, MaxOutputTokens: 8_192, InputModalities: textImage, OutputModalities: textOnly},
+		"text-embedding":         {ContextLength: 2_048, InputModalities: textOnly},
+		"gemini-embedding":       {ContextLength: 2_048, InputModalities: textOnly},
+	},
+	xAI: {
+		"grok-4":        {ContextLength: 256_000, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"grok-4-fast":   {ContextLength: 2_000_000, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"grok-code":     {ContextLength: 256_000, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"grok-3":        {ContextLength: 131_072, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"grok-2-vision": {ContextLength: 32_768, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"grok-2-image":  {InputModalities: textOnly, OutputModalities: []string{"image"}},
+	},
+	GROQ: {
+		"llama-3.3-70b-versatile": {ContextLength: 131_072, MaxOutputTokens: 32_768, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"llama-3.1-8b-instant":    {ContextLength: 131_072, MaxOutputTokens: 131_072, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"meta-llama/llama-4":      {ContextLength: 131_072, MaxOutputTokens: 8_192, InputModalities: textImage, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"openai/gpt-oss":          {ContextLength: 131_072, MaxOutputTokens: 65_536, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"whisper":                 {InputModalities: []string{"audio"}, OutputModalities: textOnly},
+	},
+	PERPLEXITY: {
+		"sonar":               {ContextLength: 128_000, InputModalities: textImage, OutputModalities: textOnly, SupportsJSON: true},
+		"sonar-pro":           {ContextLength: 200_000, MaxOutputTokens: 8_000, InputModalities: textImage, OutputModalities: textOnly, SupportsJSON: true},
+		"sonar-reasoning":     {ContextLength: 128_000, InputModalities: textImage, OutputModalities: textOnly, SupportsJSON: true},
+		"sonar-deep-research": {ContextLength: 128_000, InputModalities: textOnly, OutputModalities: textOnly},
+	},
+	COHERE: {
+		"command-a":        {ContextLength: 256_000, MaxOutputTokens: 8_000, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"command-a-vision": {ContextLength: 128_000, MaxOutputTokens: 8_000, InputModalities: textImage, OutputModalities: textOnly, SupportsJSON: true},
+		"command-r":        {ContextLength: 128_000, MaxOutputTokens: 4_000, InputModalities: textOnly, OutputModalities: textOnly, SupportsTools: true, SupportsJSON: true},
+		"embed":            {ContextLength: 512, InputModalities: textImage},
+		"rerank":           {ContextLength: 4_096, InputModalities: textOnly},
+	},
+}
+
+// GetModelCapabilities looks a model up in the catalog, reporting false when it isn't listed
+func GetModelCapabilities(provider types.Provider, modelID string) (types.ModelCapabilities, bool) {
+	modelID = strings.TrimPrefix(strings.ToLower(modelID), "models/")
+
+	capabilities, matchedPrefix := types.ModelCapabilities{}, ""
+
+	for prefix, candidate := range ModelCatalogMap[provider] {
+		if strings.HasPrefix(modelID, prefix) && len(prefix) > len(matchedPrefix) {
+			capabilities, matchedPrefix = candidate, prefix
+		}
+	}
+
+	return capabilities, matchedPrefix != ""
+}
+
+// EnrichModel fills whatever the provider left unset from the catalog and the pricing table,
+// values the provider reported always win
+func EnrichModel(model *types.Model) {
+	if capabilities, ok := GetModelCapabilities(model.Provider, model.ID); ok {
+		if model.ContextLength == 0 {
+			model.ContextLength = capabilities.ContextLength
+		}
+
+		if model.MaxOutputTokens == 0 {
+			model.MaxOutputTokens = capabilities.MaxOutputTokens
+		}
+
+		if len(model.InputModalities) == 0 {
+			model.InputModalities = capabilities.InputModalities
+		}
+
+		if len(model.OutputModalities) == 0 {
+			model.OutputModalities = capabilities.OutputModalities
+		}
+
+		model.SupportsTools = model.SupportsTools || capabilities.SupportsTools
+		model.SupportsJSON = model.SupportsJSON || capabilities.SupportsJSON
+	}
+
+	if model.Pricing != nil {
+		return
+	}
+
+	if pricing, ok := GetModelPricing(model.Provider, model.ID); ok {
+		model.Pricing = &pricing
+	}
+}
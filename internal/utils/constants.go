@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/CodingWithKarim/AgentK/internal/utils/types"
 )
@@ -21,11 +25,14 @@ const (
 	DEEPINFRA   types.Provider = "DeepInfra"
 )
 
-var OpenAICompatibleProviders = []types.Provider{
-	OPENAI, GOOGLE, xAI, GROQ, PERPLEXITY, COHERE, HUGGINGFACE, OPENROUTER, DEEPINFRA,
+// The compiled-in provider tables, BuiltInSettings copies them into the settings used until a config file is applied
+var builtInNativeProviders = []types.Provider{ANTHROPIC, GOOGLE, COHERE}
+
+var builtInCompatibleProviders = []types.Provider{
+	OPENAI, xAI, GROQ, PERPLEXITY, HUGGINGFACE, OPENROUTER, DEEPINFRA,
 }
 
-var ProviderEndpointsMap = map[types.Provider]types.ProviderEndpoints{
+var builtInEndpoints = map[types.Provider]types.ProviderEndpoints{
 	OPENAI: {
 		BaseURL:       "https://api.openai.com/v1",
 		ModelEndpoint: "https://api.openai.com/v1",
@@ -35,8 +42,8 @@ var ProviderEndpointsMap = map[types.Provider]types.ProviderEndpoints{
 		ModelEndpoint: "https://api.anthropic.com/v1/models",
 	},
 	GOOGLE: {
-		BaseURL:       "https://generativelanguage.googleapis.com/v1beta/openai",
-		ModelEndpoint: "https://generativelanguage.googleapis.com/v1beta/openai",
+		BaseURL:       "https://generativelanguage.googleapis.com/v1beta",
+		ModelEndpoint: "https://generativelanguage.googleapis.com/v1beta",
 	},
 	xAI: {
 		BaseURL:       "https://api.x.ai/v1",
@@ -47,7 +54,7 @@ var ProviderEndpointsMap = map[types.Provider]types.ProviderEndpoints{
 		ModelEndpoint: "https://api.groq.com/openai/v1",
 	},
 	COHERE: {
-		BaseURL:       "https://api.cohere.ai/compatibility/v1",
+		BaseURL:       "https://api.cohere.ai/v2",
 		ModelEndpoint: "https://api.cohere.ai/v1",
 	},
 	PERPLEXITY: {
@@ -68,9 +75,73 @@ var ProviderEndpointsMap = map[types.Provider]types.ProviderEndpoints{
 	},
 }
 
-var PerplexityModels = []string{"sonar", "sonar-pro", "sonar-reasoning", "sonar-reasoning-pro", "sonar-deep-research"}
+// ModelPricingMap is keyed by exact model ID, a pinned snapshot of a listed model shares its price
+// (see ModelSnapshotOf). Variants such as gpt-5-pro are priced apart, so each needs its own entry
+var ModelPricingMap = map[types.Provider]map[string]types.ModelPricing{
+	OPENAI: {
+		"gpt-5":        {Input: 1.25, CachedInput: 0.125, Output: 10.00},
+		"gpt-5-chat":   {Input: 1.25, CachedInput: 0.125, Output: 10.00},
+		"gpt-5-codex":  {Input: 1.25, CachedInput: 0.125, Output: 10.00},
+		"gpt-5-mini":   {Input: 0.25, CachedInput: 0.025, Output: 2.00},
+		"gpt-5-nano":   {Input: 0.05, CachedInput: 0.005, Output: 0.40},
+		"gpt-5-pro":    {Input: 15.00, CachedInput: 15.00, Output: 120.00},
+		"gpt-4.1":      {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+		"gpt-4.1-mini": {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+		"gpt-4.1-nano": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+		"gpt-4o":       {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+		"gpt-4o-mini":  {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+		"o3":           {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+		"o3-pro":       {Input: 20.00, CachedInput: 20.00, Output: 80.00},
+		"o3-mini":      {Input: 1.10, CachedInput: 0.55, Output: 4.40},
+		"o4-mini":      {Input: 1.10, CachedInput: 0.275, Output: 4.40},
+	},
+	ANTHROPIC: {
+		"claude-opus-4":     {Input: 15.00, CachedInput: 1.50, Output: 75.00},
+		"claude-opus-4-0":   {Input: 15.00, CachedInput: 1.50, Output: 75.00},
+		"claude-opus-4-1":   {Input: 15.00, CachedInput: 1.50, Output: 75.00},
+		"claude-opus-4-5":   {Input: 5.00, CachedInput: 0.50, Output: 25.00},
+		"claude-sonnet-4":   {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+		"claude-sonnet-4-0": {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+		"claude-sonnet-4-5": {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+		"claude-haiku-4-5":  {Input: 1.00, CachedInput: 0.10, Output: 5.00},
+		"claude-3-7-sonnet": {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+		"claude-3-5-haiku":  {Input: 0.80, CachedInput: 0.08, Output: 4.00},
+	},
+	GOOGLE: {
+		"gemini-2.5-pro":        {Input: 1.25, CachedInput: 0.31, Output: 10.00},
+		"gemini-2.5-flash":      {Input: 0.30, CachedInput: 0.075, Output: 2.50},
+		"gemini-2.5-flash-lite": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+		"gemini-2.0-flash":      {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	},
+	xAI: {
+		"grok-4":      {Input: 3.00, CachedInput: 0.75, Output: 15.00},
+		"grok-3":      {Input: 3.00, CachedInput: 0.75, Output: 15.00},
+		"grok-3-mini": {Input: 0.30, CachedInput: 0.075, Output: 0.50},
+	},
+	GROQ: {
+		"llama-3.3-70b-versatile": {Input: 0.59, CachedInput: 0.59, Output: 0.79},
+		"llama-3.1-8b-instant":    {Input: 0.05, CachedInput: 0.05, Output: 0.08},
+	},
+	PERPLEXITY: {
+		"sonar":               {Input: 1.00, CachedInput: 1.00, Output: 1.00},
+		"sonar-pro":           {Input: 3.00, CachedInput: 3.00, Output: 15.00},
+		"sonar-reasoning":     {Input: 1.00, CachedInput: 1.00, Output: 5.00},
+		"sonar-reasoning-pro": {Input: 2.00, CachedInput: 2.00, Output: 8.00},
+		"sonar-deep-research": {Input: 2.00, CachedInput: 2.00, Output: 8.00},
+	},
+	COHERE: {
+		"command-a":      {Input: 2.50, CachedInput: 2.50, Output: 10.00},
+		"command-r-plus": {Input: 2.50, CachedInput: 2.50, Output: 10.00},
+		"command-r":      {Input: 0.15, CachedInput: 0.15, Output: 0.60},
+		"command-r7b":    {Input: 0.0375, CachedInput: 0.0375, Output: 0.15},
+	},
+}
+
+var builtInStaticModels = map[types.Provider][]string{
+	PERPLEXITY: {"sonar", "sonar-pro", "sonar-reasoning", "sonar-reasoning-pro", "sonar-deep-research"},
+}
 
-var DisallowedModels = []string{
+var builtInDisallowedModels = []string{
 	"embed",
 	"embedding",
 	"davinci",
@@ -107,16 +178,166 @@ var DisallowedModels = []string{
 	"veo-",
 }
 
+// Perplexity search filter values, the API takes at most MaxSearchDomains domains
+var (
+	SearchRecencyFilters = []string{"hour", "day", "week", "month", "year"}
+	SearchContextSizes   = []string{"low", "medium", "high"}
+	MaxSearchDomains     = 20
+)
+
+// MaxFallbacks caps the fallback models a request can name, every fallback tried is another billed call
+const MaxFallbacks = 3
+
 var AuthSubstrings = []string{
 	"invalid api key",
 	"incorrect api key",
 	"invalid x-api-key",
+	"api key not valid",
+	"invalid api token",
 	"no cookie auth credentials",
 	"invalid username or password",
 }
 
+// DefaultProviderTimeout bounds a single provider call when no timeout is configured
+const DefaultProviderTimeout = 5 * time.Minute
+
 var ErrProviderNotSupported = fmt.Errorf("the specified provider is not supported")
 
-func GetKey(provider types.Provider) string {
-	return os.Getenv(fmt.Sprintf("%s_API_KEY", strings.ToUpper(string(provider))))
+// GetKeys reads every key for a provider from <PROVIDER>_API_KEY and <PROVIDER>_API_KEYS,
+// both of which accept a comma separated list
+func GetKeys(provider types.Provider) []string {
+	return GetKeysFrom(CurrentSettings(), provider)
+}
+
+// GetKeysFrom reads a provider's keys using the env var names of a given settings snapshot
+func GetKeysFrom(settings *Settings, provider types.Provider) []string {
+	name := settings.KeyEnvName(provider)
+
+	return SplitKeys(os.Getenv(name) + "," + os.Getenv(name+"S"))
+}
+
+// SplitKeys parses a comma separated key list, dropping blanks and duplicates
+func SplitKeys(value string) []string {
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+
+		if key == "" || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// ConfiguredProviders lists every provider in the current configuration, native ones first
+func ConfiguredProviders() []types.Provider {
+	return CurrentSettings().ConfiguredProviders()
+}
+
+// IsNativeProvider reports whether a provider is served by its own client rather than the OpenAI compatible one
+func IsNativeProvider(provider types.Provider) bool {
+	return CurrentSettings().IsNativeProvider(provider)
+}
+
+func KeyEnvName(provider types.Provider) string {
+	return CurrentSettings().KeyEnvName(provider)
+}
+
+// IsModelAllowed applies the global and provider specific model filters
+func IsModelAllowed(provider types.Provider, modelID string) bool {
+	return CurrentSettings().IsModelAllowed(provider, modelID)
+}
+
+// GetTimeout reads <PROVIDER>_TIMEOUT, falling back to PROVIDER_TIMEOUT and then the configured default.
+// Values use Go duration syntax (e.g. "90s", "2m"); "0" disables the timeout.
+func GetTimeout(provider types.Provider) time.Duration {
+	return CurrentSettings().Timeout(provider)
+}
+
+// GetProviderDuration reads <PROVIDER>_<name> falling back to <name> and then to fallback
+func GetProviderDuration(provider types.Provider, name string, fallback time.Duration) time.Duration {
+	return readDuration(fallback, fmt.Sprintf("%s_%s", strings.ToUpper(string(provider)), name), name)
+}
+
+func readDuration(fallback time.Duration, names ...string) time.Duration {
+	for _, name := range names {
+		value := os.Getenv(name)
+
+		if value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+
+		if err != nil {
+			log.Printf("ignoring invalid %s=%q err=%v", name, value, err)
+			continue
+		}
+
+		return duration
+	}
+
+	return fallback
+}
+
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// snapshotSuffix matches the date, version or alias providers append to pin a model, e.g. gpt-4o-2024-08-06,
+// claude-sonnet-4-20250514, claude-3-7-sonnet-latest, grok-4-0709, gemini-2.0-flash-001,
+// gemini-2.5-flash-preview-05-20 and command-a-03-2025
+var snapshotSuffix = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}|\d{8}|\d{3,4}|\d{2}-\d{4}|preview-\d{2}-\d{2,4}|latest)$`)
+
+// ModelSnapshotOf reports whether modelID is model or a pinned snapshot of it, so claude-opus-4-20250514
+// is a snapshot of claude-opus-4 while claude-opus-4-5 is another model
+func ModelSnapshotOf(modelID string, model string) bool {
+	modelID, model = normalizeModelID(modelID), normalizeModelID(model)
+
+	return modelID == model || snapshotSuffix.ReplaceAllString(modelID, "") == model
+}
+
+// Gemini model IDs come back from the OpenAI shim as "models/<id>"
+func normalizeModelID(modelID string) string {
+	return strings.TrimPrefix(strings.ToLower(modelID), "models/")
+}
+
+func GetModelPricing(provider types.Provider, modelID string) (types.ModelPricing, bool) {
+	modelID = normalizeModelID(modelID)
+	prices := ModelPricingMap[provider]
+
+	if pricing, ok := prices[modelID]; ok {
+		return pricing, true
+	}
+
+	pricing, ok := prices[snapshotSuffix.ReplaceAllString(modelID, "")]
+
+	return pricing, ok
+}
+
+// EstimateCost prices usage in USD, reporting false when the model has no known pricing
+func EstimateCost(provider types.Provider, modelID string, usage types.Usage) (float64, bool) {
+	pricing, ok := GetModelPricing(provider, modelID)
+
+	if !ok {
+		return 0, false
+	}
+
+	uncachedInput := usage.InputTokens - usage.CachedTokens
+
+	cost := float64(uncachedInput)*pricing.Input +
+		float64(usage.CachedTokens)*pricing.CachedInput +
+		float64(usage.OutputTokens)*pricing.Output
+
+	return cost / 1_000_000, true
 }
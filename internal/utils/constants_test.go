@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+func TestGetModelPricing(t *testing.T) {
+	cases := []struct {
+		provider types.Provider
+		modelID  string
+		want     string // the ModelPricingMap entry expected, empty when the model is unpriced
+	}{
+		{OPENAI, "gpt-5", "gpt-5"},
+		{OPENAI, "gpt-5-2025-08-07", "gpt-5"},
+		{OPENAI, "gpt-5-pro", "gpt-5-pro"},
+		{OPENAI, "gpt-5-pro-2025-10-06", "gpt-5-pro"},
+		{OPENAI, "gpt-5-mini-2025-08-07", "gpt-5-mini"},
+		{OPENAI, "gpt-5-chat-latest", "gpt-5-chat"},
+		{OPENAI, "GPT-4o", "gpt-4o"},
+		{OPENAI, "gpt-4o-2024-08-06", "gpt-4o"},
+		{OPENAI, "gpt-4o-mini-2024-07-18", "gpt-4o-mini"},
+		{OPENAI, "gpt-4o-audio-preview", ""},
+		{OPENAI, "o3", "o3"},
+		{OPENAI, "o3-2025-04-16", "o3"},
+		{OPENAI, "o3-pro", "o3-pro"},
+		{OPENAI, "o3-pro-2025-06-10", "o3-pro"},
+		{OPENAI, "o3-deep-research", ""},
+		{ANTHROPIC, "claude-opus-4-20250514", "claude-opus-4"},
+		{ANTHROPIC, "claude-opus-4-0", "claude-opus-4-0"},
+		{ANTHROPIC, "claude-opus-4-1-20250805", "claude-opus-4-1"},
+		{ANTHROPIC, "claude-opus-4-5", "claude-opus-4-5"},
+		{ANTHROPIC, "claude-opus-4-5-20251101", "claude-opus-4-5"},
+		{ANTHROPIC, "claude-sonnet-4-5-20250929", "claude-sonnet-4-5"},
+		{ANTHROPIC, "claude-3-7-sonnet-latest", "claude-3-7-sonnet"},
+		{ANTHROPIC, "claude-3-5-haiku-20241022", "claude-3-5-haiku"},
+		{ANTHROPIC, "claude-3-5-sonnet-20241022", ""},
+		{GOOGLE, "models/gemini-2.5-flash", "gemini-2.5-flash"},
+		{GOOGLE, "gemini-2.5-flash-lite", "gemini-2.5-flash-lite"},
+		{GOOGLE, "gemini-2.5-flash-preview-05-20", "gemini-2.5-flash"},
+		{GOOGLE, "gemini-2.0-flash-001", "gemini-2.0-flash"},
+		{GOOGLE, "gemini-2.5-flash-image", ""},
+		{xAI, "grok-4-0709", "grok-4"},
+		{xAI, "grok-4-fast-reasoning", ""},
+		{xAI, "grok-3-mini-latest", "grok-3-mini"},
+		{COHERE, "command-a-03-2025", "command-a"},
+		{COHERE, "command-r-plus-08-2024", "command-r-plus"},
+		{COHERE, "command-a-vision-07-2025", ""},
+		{PERPLEXITY, "sonar-pro", "sonar-pro"},
+		{GROQ, "gpt-4o", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.provider)+"/"+tc.modelID, func(t *testing.T) {
+			got, ok := GetModelPricing(tc.provider, tc.modelID)
+
+			if tc.want == "" {
+				if ok {
+					t.Errorf("priced at %+v, want unpriced", got)
+				}
+
+				return
+			}
+
+			if want := ModelPricingMap[tc.provider][tc.want]; !ok || got != want {
+				t.Errorf("got %+v (priced %v), want the %s price %+v", got, ok, tc.want, want)
+			}
+		})
+	}
+}
+
+func TestModelSnapshotOf(t *testing.T) {
+	cases := []struct {
+		modelID string
+		model   string
+		want    bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o-2024-08-06", "gpt-4o", true},
+		{"gpt-4o-mini", "gpt-4o", false},
+		{"claude-sonnet-4-20250514", "claude-sonnet-4", true},
+		{"claude-sonnet-4-5", "claude-sonnet-4", false},
+		{"models/Gemini-2.5-Pro", "gemini-2.5-pro", true},
+	}
+
+	for _, tc := range cases {
+		if got := ModelSnapshotOf(tc.modelID, tc.model); got != tc.want {
+			t.Errorf("ModelSnapshotOf(%q, %q) = %v, want %v", tc.modelID, tc.model, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/CodingWithKarim/AgentK/internal/files/pdf"
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+const PDFMediaType = "application/pdf"
+
+// IsSupportedFileType reports whether a file part may carry the media type, PDFs and text documents
+func IsSupportedFileType(mediaType string) bool {
+	return mediaType == PDFMediaType || strings.HasPrefix(mediaType, "text/")
+}
+
+// ParseDataURL splits a base64 data URL into its media type, without parameters, and the decoded bytes
+func ParseDataURL(url string) (string, []byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+
+	if !strings.HasPrefix(url, "data:") || !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, errors.New("file data must be a base64 data URL")
+	}
+
+	mediaType, _, _ := strings.Cut(strings.TrimSuffix(meta, ";base64"), ";")
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+
+	if err != nil {
+		return "", nil, fmt.Errorf("file data is not valid base64: %w", err)
+	}
+
+	return strings.ToLower(mediaType), decoded, nil
+}
+
+// FileName names a file in messages and errors, upload references only have their ID
+func FileName(file *types.File) string {
+	if file.Filename != "" {
+		return file.Filename
+	}
+
+	if file.FileID != "" {
+		return file.FileID
+	}
+
+	return "attachment"
+}
+
+// ExtractFileText reads the text of a file part for models that can't take the file itself
+func ExtractFileText(file *types.File) (string, error) {
+	if file.FileData == "" {
+		return "", fmt.Errorf("file %s is an upload reference, this model needs the file data to read it", FileName(file))
+	}
+
+	mediaType, data, err := ParseDataURL(file.FileData)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("file %s is not UTF-8 text", FileName(file))
+		}
+
+		return string(data), nil
+
+	case mediaType == PDFMediaType:
+		text, err := pdf.ExtractText(data)
+
+		if err != nil {
+			return "", fmt.Errorf("file %s: %w", FileName(file), err)
+		}
+
+		if text == "" {
+			return "", fmt.Errorf("no text could be extracted from %s, it may be scanned or use embedded font encodings", FileName(file))
+		}
+
+		return text, nil
+	}
+
+	return "", fmt.Errorf("unsupported file type %q", mediaType)
+}
+
+// AttachedFileText is the text part a file is replaced with for models that can't read it
+func AttachedFileText(file *types.File) (string, error) {
+	text, err := ExtractFileText(file)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Attached file %s:\n\n%s", FileName(file), text), nil
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodingWithKarim/AgentK/internal/utils/types"
+)
+
+// Settings is the provider configuration a request runs with. A snapshot is never modified once
+// stored, a reload builds a new one and swaps it in, so a request that loads it once reads one config
+type Settings struct {
+	// NativeProviders have a client of their own rather than the OpenAI compatible one
+	NativeProviders           []types.Provider
+	OpenAICompatibleProviders []types.Provider
+	ProviderEndpoints         map[types.Provider]types.ProviderEndpoints
+	// StaticModels are listed when a provider's model endpoint is missing or unusable
+	StaticModels map[types.Provider][]string
+	// ProviderKeyEnvs overrides the <PROVIDER>_API_KEY env var name a provider's key is read from
+	ProviderKeyEnvs map[types.Provider]string
+	// KeylessProviders are registered without an API key, typically local servers
+	KeylessProviders map[types.Provider]bool
+	// ProviderHeaders are extra headers sent with every request to a provider
+	ProviderHeaders map[types.Provider]map[string]string
+	// ProviderDisallowedModels adds provider specific filters on top of DisallowedModels
+	ProviderDisallowedModels map[types.Provider][]string
+	DisallowedModels         []string
+
+	// Request defaults, applied when a chat request leaves the field empty
+	DefaultProvider  types.Provider
+	DefaultModelID   string
+	DefaultMaxTokens int64
+	// DefaultTimeout bounds a single provider call when no timeout env var is set
+	DefaultTimeout time.Duration
+}
+
+var settings atomic.Pointer[Settings]
+
+// BuiltInSettings returns a fresh copy of the compiled-in configuration
+func BuiltInSettings() *Settings {
+	return &Settings{
+		NativeProviders:           slices.Clone(builtInNativeProviders),
+		OpenAICompatibleProviders: slices.Clone(builtInCompatibleProviders),
+		ProviderEndpoints:         maps.Clone(builtInEndpoints),
+		StaticModels:              maps.Clone(builtInStaticModels),
+		ProviderKeyEnvs:           map[types.Provider]string{},
+		KeylessProviders:          map[types.Provider]bool{},
+		ProviderHeaders:           map[types.Provider]map[string]string{},
+		ProviderDisallowedModels:  map[types.Provider][]string{},
+		DisallowedModels:          slices.Clone(builtInDisallowedModels),
+		DefaultTimeout:            DefaultProviderTimeout,
+	}
+}
+
+// CurrentSettings returns the settings in effect, load it once per request and read every field from that copy
+func CurrentSettings() *Settings {
+	if current := settings.Load(); current != nil {
+		return current
+	}
+
+	builtIn := BuiltInSettings()
+
+	if settings.CompareAndSwap(nil, builtIn) {
+		return builtIn
+	}
+
+	return settings.Load()
+}
+
+// StoreSettings swaps in a new snapshot, callers must not modify it afterwards
+func StoreSettings(next *Settings) {
+	settings.Store(next)
+}
+
+// ConfiguredProviders lists every provider in the snapshot, native ones first
+func (s *Settings) ConfiguredProviders() []types.Provider {
+	providers := make([]types.Provider, 0, len(s.NativeProviders)+len(s.OpenAICompatibleProviders))
+
+	return append(append(providers, s.NativeProviders...), s.OpenAICompatibleProviders...)
+}
+
+func (s *Settings) IsNativeProvider(provider types.Provider) bool {
+	return slices.Contains(s.NativeProviders, provider)
+}
+
+func (s *Settings) KeyEnvName(provider types.Provider) string {
+	if name, ok := s.ProviderKeyEnvs[provider]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%s_API_KEY", strings.ToUpper(string(provider)))
+}
+
+func (s *Settings) IsModelAllowed(provider types.Provider, modelID string) bool {
+	modelID = strings.ToLower(modelID)
+
+	for _, badWord := range slices.Concat(s.DisallowedModels, s.ProviderDisallowedModels[provider]) {
+		if strings.Contains(modelID, strings.ToLower(badWord)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Settings) Timeout(provider types.Provider) time.Duration {
+	return readDuration(s.DefaultTimeout, fmt.Sprintf("%s_TIMEOUT", strings.ToUpper(string(provider))), "PROVIDER_TIMEOUT")
+}
@@ -2,14 +2,48 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
 )
 
 type ChatRequest struct {
-	ModelID      string          `json:"modelID"`
-	Provider     Provider        `json:"provider"`
-	Context      json.RawMessage `json:"context"`
-	Tokens       int64           `json:"tokens"`
-	SystemPrompt string          `json:"systemPrompt,omitempty"`
+	ModelID      string           `json:"modelID"`
+	Provider     Provider         `json:"provider"`
+	Context      json.RawMessage  `json:"context"`
+	Tokens       int64            `json:"tokens"`
+	SystemPrompt string           `json:"systemPrompt,omitempty"`
+	Tools        []ToolDefinition `json:"tools,omitempty"`
+	ToolChoice   string           `json:"toolChoice,omitempty"` // auto, none, required or a tool name
+	Fallbacks    []string         `json:"fallbacks,omitempty"`  // provider/model names tried in order when the model is unavailable
+	Documents    []Document       `json:"documents,omitempty"`  // grounding documents, Cohere answers from them with citations, other providers ignore them
+	Search       *SearchOptions   `json:"search,omitempty"`     // web search filters, only Perplexity applies them
+}
+
+type SearchOptions struct {
+	RecencyFilter string   `json:"recencyFilter,omitempty"` // hour, day, week, month or year
+	DomainFilter  []string `json:"domainFilter,omitempty"`  // domains to search, a leading "-" excludes a domain instead
+	ContextSize   string   `json:"contextSize,omitempty"`   // low, medium or high, how much search context the model reads
+}
+
+type Document struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Citation ties a span of the response text, by character offsets, to the sources backing it
+type Citation struct {
+	Start   int      `json:"start"`
+	End     int      `json:"end"`
+	Text    string   `json:"text"`
+	Sources []Source `json:"sources"`
+}
+
+type Source struct {
+	ID      string `json:"id,omitempty"` // the document ID given in the request, the tool call the source came from, or the [n] marker of a web result
+	Title   string `json:"title,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
 }
 
 type Model struct {
@@ -17,6 +51,34 @@ type Model struct {
 	Name     string   `json:"name"`
 	Provider Provider `json:"provider,omitempty"`
 	Enabled  bool     `json:"enabled"`
+	ModelCapabilities
+	Pricing *ModelPricing `json:"pricing,omitempty"`
+}
+
+// ModelCapabilities is what a model accepts and produces, zero values mean unknown
+type ModelCapabilities struct {
+	ContextLength    int      `json:"contextLength,omitempty"`
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	InputModalities  []string `json:"inputModalities,omitempty"` // text, image, file, audio or video
+	OutputModalities []string `json:"outputModalities,omitempty"`
+	SupportsTools    bool     `json:"supportsTools,omitempty"`
+	SupportsJSON     bool     `json:"supportsJSON,omitempty"`
+}
+
+// ModelList is the merged model listing, providers that couldn't be listed or were served
+// from an expired cache entry are reported rather than silently dropped
+type ModelList struct {
+	Models []*Model               `json:"models"`
+	Stale  []ProviderModelsStatus `json:"stale,omitempty"`
+	Failed []ProviderModelsStatus `json:"failed,omitempty"`
+}
+
+type ProviderModelsStatus struct {
+	Provider  Provider `json:"provider"`
+	FetchedAt int64    `json:"fetchedAt,omitempty"` // when the served list was fetched, in Unix milliseconds
+	Code      string   `json:"code,omitempty"`
+	Error     string   `json:"error,omitempty"` // the last fetch failure, a stale entry may carry one too
+	Err       error    `json:"-"`
 }
 
 type Provider string
@@ -26,17 +88,231 @@ type ProviderEndpoints struct {
 	ModelEndpoint string
 }
 
+// Message is one turn of the canonical context, every provider's messages are encoded from it.
+// Content is a string or a list of parts, the chat service parses it into parts before encoding
 type Message struct {
-	Role    string          `json:"role"`
+	Role    string          `json:"role"` // system, user or assistant
 	Content json.RawMessage `json:"content"`
 }
 
+// MessagePart is a text, image_url, file, tool_call, tool_result or thinking part. A thinking part holds
+// the model's reasoning in Text and the signature the provider needs to accept it back, a tool_result
+// part sets IsError when the tool failed
 type MessagePart struct {
-	Type     string    `json:"type"`
-	Text     string    `json:"text,omitempty"`
-	ImageURL *ImageURL `json:"image_url,omitempty"`
+	Type       string    `json:"type"`
+	Text       string    `json:"text,omitempty"`
+	ImageURL   *ImageURL `json:"image_url,omitempty"`
+	File       *File     `json:"file,omitempty"`
+	ToolCall   *ToolCall `json:"tool_call,omitempty"`
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	IsError    bool      `json:"is_error,omitempty"`
+	Signature  string    `json:"signature,omitempty"`
+}
+
+// ToolOutput is a tool_result's text for providers without an error flag, a failed call is marked in the text
+func (p MessagePart) ToolOutput() string {
+	if p.IsError {
+		return "Error: " + p.Text
+	}
+
+	return p.Text
+}
+
+// Parts returns the message content as parts, string content becomes a single text part
+func (m Message) Parts() ([]MessagePart, error) {
+	var text string
+
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return []MessagePart{{Type: "text", Text: text}}, nil
+	}
+
+	var parts []MessagePart
+
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return nil, errors.New("message content must be a string or an array of parts")
+	}
+
+	return parts, nil
 }
 
 type ImageURL struct {
 	URL string `json:"url"`
 }
+
+// File is a PDF or plain text document, sent inline as a base64 data URL or as a reference to a
+// file already uploaded to the provider. The fields follow OpenAI's file content part
+type File struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"` // data:application/pdf;base64,... or data:text/plain;base64,...
+	FileID   string `json:"file_id,omitempty"`
+}
+
+// Usage counts tokens for a single call, InputTokens includes CachedTokens
+type Usage struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	CachedTokens int64 `json:"cachedTokens"`
+}
+
+// ToolDefinition describes a callable tool, Parameters is a JSON Schema object
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function call requested by the model. Signature is the opaque token Gemini attaches to
+// calls made while thinking, it must be sent back with the call on the next turn
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+type ChatResult struct {
+	Text         string     `json:"response"`
+	ToolCalls    []ToolCall `json:"toolCalls,omitempty"`
+	Provider     Provider   `json:"provider,omitempty"`
+	Model        string     `json:"model"`
+	StopReason   string     `json:"stopReason"`
+	Usage        Usage      `json:"usage"`
+	LatencyMs    int64      `json:"latencyMs"`
+	CostUSD      *float64   `json:"costUSD,omitempty"`
+	FallbackFrom []string   `json:"fallbackFrom,omitempty"` // provider/model names that failed before this one answered
+	Citations    []Citation `json:"citations,omitempty"`
+	Sources      []Source   `json:"sources,omitempty"` // web results the answer was searched from, in the order the text numbers them
+}
+
+// ModelPricing holds USD prices per million tokens
+type ModelPricing struct {
+	Input       float64 `json:"input"`
+	CachedInput float64 `json:"cachedInput"`
+	Output      float64 `json:"output"`
+}
+
+type Session struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	StartedAt int64  `json:"startedAt"`
+}
+
+type StoredMessage struct {
+	ID        int64           `json:"id"`
+	SessionID string          `json:"sessionId"`
+	ModelID   string          `json:"modelId"`
+	ModelName string          `json:"modelName"`
+	Role      string          `json:"role"`
+	Content   json.RawMessage `json:"content"`
+	TS        int64           `json:"ts"`
+}
+
+type UsageRecord struct {
+	Timestamp    int64    `json:"ts"`
+	User         string   `json:"user,omitempty"`
+	Provider     Provider `json:"provider"`
+	Model        string   `json:"model"`
+	InputTokens  int64    `json:"inputTokens"`
+	OutputTokens int64    `json:"outputTokens"`
+	CachedTokens int64    `json:"cachedTokens"`
+	CostUSD      float64  `json:"costUSD"`
+	LatencyMs    int64    `json:"latencyMs"`
+}
+
+type UsageTotals struct {
+	User         string   `json:"user,omitempty"`
+	Provider     Provider `json:"provider,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Day          string   `json:"day,omitempty"`
+	Requests     int64    `json:"requests"`
+	InputTokens  int64    `json:"inputTokens"`
+	OutputTokens int64    `json:"outputTokens"`
+	CachedTokens int64    `json:"cachedTokens"`
+	CostUSD      float64  `json:"costUSD"`
+}
+
+type Budget struct {
+	Provider Provider `json:"provider,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Period   string   `json:"period"`
+	LimitUSD float64  `json:"limitUSD"`
+}
+
+// BudgetStatus reports a budget's recorded spend and the estimated cost held by calls still in flight
+type BudgetStatus struct {
+	Budget
+	SpentUSD    float64 `json:"spentUSD"`
+	ReservedUSD float64 `json:"reservedUSD"`
+}
+
+type ToolResult struct {
+	ToolCallID string `json:"toolCallID"`
+	Name       string `json:"name"`
+	Output     string `json:"output"`
+	IsError    bool   `json:"isError,omitempty"`
+}
+
+type AgentRequest struct {
+	ChatRequest
+	EnabledTools  []string `json:"enabledTools,omitempty"`
+	MaxIterations int      `json:"maxIterations,omitempty"`
+}
+
+type AgentStep struct {
+	Iteration   int          `json:"iteration"`
+	Response    *ChatResult  `json:"response"`
+	ToolResults []ToolResult `json:"toolResults,omitempty"`
+}
+
+type AgentResult struct {
+	Response   string      `json:"response"`
+	StopReason string      `json:"stopReason"`
+	Steps      []AgentStep `json:"steps"`
+	Usage      Usage       `json:"usage"`
+	CostUSD    float64     `json:"costUSD"`
+}
+
+type CompareTarget struct {
+	Provider Provider `json:"provider"`
+	ModelID  string   `json:"modelID"`
+}
+
+type CompareRequest struct {
+	Targets      []CompareTarget `json:"targets"`
+	Context      json.RawMessage `json:"context"`
+	Tokens       int64           `json:"tokens"`
+	SystemPrompt string          `json:"systemPrompt,omitempty"`
+	Stream       bool            `json:"stream,omitempty"`
+}
+
+type CompareResult struct {
+	Index    int         `json:"index"`
+	Provider Provider    `json:"provider"`
+	ModelID  string      `json:"modelID"`
+	Result   *ChatResult `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Code     string      `json:"code,omitempty"`
+	Err      error       `json:"-"`
+}
+
+// ProviderKeyStatus describes where a provider's key comes from, the key itself is never returned
+type ProviderKeyStatus struct {
+	Provider  Provider `json:"provider"`
+	Source    string   `json:"source"` // stored, env, keyless or none
+	UpdatedAt int64    `json:"updatedAt,omitempty"`
+	Active    bool     `json:"active"`
+	Keys      int      `json:"keys"`
+	Benched   int      `json:"benched"` // keys resting after an auth, quota or rate limit error
+}
+
+type KeyTestResult struct {
+	Provider  Provider `json:"provider"`
+	OK        bool     `json:"ok"`
+	Keys      int      `json:"keys"`                // keys tested, several when the provider has a key list
+	FailedKey int      `json:"failedKey,omitempty"` // 1-based position of the first rejected key
+	Models    int      `json:"models"`
+	LatencyMs int64    `json:"latencyMs"`
+	Code      string   `json:"code,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Err       error    `json:"-"`
+}
@@ -3,18 +3,29 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/CodingWithKarim/AgentK/internal/admin"
+	"github.com/CodingWithKarim/AgentK/internal/agent"
 	"github.com/CodingWithKarim/AgentK/internal/api"
+	"github.com/CodingWithKarim/AgentK/internal/auth"
+	chatservice "github.com/CodingWithKarim/AgentK/internal/chat"
+	"github.com/CodingWithKarim/AgentK/internal/config"
+	"github.com/CodingWithKarim/AgentK/internal/keys"
 	"github.com/CodingWithKarim/AgentK/internal/llms"
+	"github.com/CodingWithKarim/AgentK/internal/ratelimit"
+	"github.com/CodingWithKarim/AgentK/internal/storage"
+	"github.com/CodingWithKarim/AgentK/internal/usage"
+	"github.com/CodingWithKarim/AgentK/internal/utils"
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/joho/godotenv"
 	"github.com/openai/openai-go"
 )
 
@@ -22,21 +33,85 @@ import (
 var embeddedFiles embed.FS
 
 func main() {
-	_ = godotenv.Load()
+	if err := admin.LoadEnv(); err != nil {
+		log.Printf("failed to read .env err=%v", err)
+	}
+
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON provider config, built-in defaults when empty")
+	flag.Parse()
+
+	if err := config.InitializeConfig(*configPath); err != nil {
+		log.Fatal(err)
+	}
+
+	admin.InitializeReload(*configPath)
+
+	dataDir := os.Getenv("DATA_DIR")
+
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	if err := storage.InitializeStore(dataDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := usage.InitializeLedger(dataDir); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := keys.InitializeKeyStore(dataDir); err != nil {
+		log.Fatal(err)
+	}
 
 	openAIClient := openai.NewClient()
 	anthropicClient := anthropic.NewClient(anthropic.DefaultClientOptions()...)
 
+	// Built after the key store opens so keys stored through the admin API are used
 	llms.InitializeClients(
 		&openAIClient, // OpenAI client supports all providers except Anthropic
 		&anthropicClient,
 	)
 
+	if err := usage.LoadBudgets(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := chatservice.LoadFallbackChains(); err != nil {
+		log.Fatal(err)
+	}
+
+	chatservice.InitializeModelCache()
+
+	if err := agent.InitializeTools(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ratelimit.InitializeLimiter(utils.ConfiguredProviders()); err != nil {
+		log.Fatal(err)
+	}
+
 	router := http.NewServeMux()
 
 	router.HandleFunc("/api/chat", api.ChatHandler)
+	router.HandleFunc("/api/chat/stream", api.ChatStreamHandler)
 	router.HandleFunc("/api/models", api.GetModelsHandler)
 	router.HandleFunc("/api/health", api.GetHealthStatus)
+	router.HandleFunc("/api/sessions", api.SessionsHandler)
+	router.HandleFunc("/api/sessions/{id}", api.DeleteSessionHandler)
+	router.HandleFunc("/api/sessions/{id}/messages", api.MessagesHandler)
+	router.HandleFunc("/api/sessions/{id}/messages/{messageID}", api.MessageHandler)
+	router.HandleFunc("/api/rename", api.RenameSessionHandler)
+	router.HandleFunc("/api/usage", api.UsageHandler)
+	router.HandleFunc("/api/compare", api.CompareHandler)
+	router.HandleFunc("/api/agent", api.AgentHandler)
+	router.HandleFunc("/api/agent/tools", api.AgentToolsHandler)
+	router.HandleFunc("/v1/chat/completions", api.OpenAIChatCompletionsHandler)
+	router.HandleFunc("/v1/models", api.OpenAIModelsHandler)
+	router.HandleFunc("/api/admin/reload", api.AdminReloadHandler)
+	router.HandleFunc("/api/admin/keys", api.AdminKeysHandler)
+	router.HandleFunc("/api/admin/keys/{provider}", api.AdminKeyHandler)
+	router.HandleFunc("/api/admin/keys/{provider}/test", api.AdminKeyTestHandler)
 
 	fileSystem, err := fs.Sub(embeddedFiles, "frontend/dist")
 
@@ -46,11 +121,24 @@ func main() {
 
 	router.Handle("/", http.FileServer(http.FS(fileSystem))) // Serve frontend at root
 
+	if err := auth.LoadFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Every request context derives from this one so shutdown can cancel in-flight provider calls
+	baseContext, cancelInFlight := context.WithCancel(context.Background())
+	defer cancelInFlight()
+
 	server := &http.Server{
 		Addr:    "0.0.0.0:8080",
-		Handler: router,
+		Handler: auth.Middleware(router),
+		BaseContext: func(net.Listener) context.Context {
+			return baseContext
+		},
 	}
 
+	server.RegisterOnShutdown(cancelInFlight)
+
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen error: %v", err)
@@ -59,6 +147,18 @@ func main() {
 
 	log.Println("AgentK Server started on port 8080")
 
+	// SIGHUP reloads .env and the config file without dropping connections
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+
+	go func() {
+		for range reloadChannel {
+			if _, err := admin.Reload(); err != nil {
+				log.Printf("reload failed, keeping the current config err=%v", err)
+			}
+		}
+	}()
+
 	shutdownChannel := make(chan os.Signal, 1)
 	signal.Notify(shutdownChannel, syscall.SIGINT, syscall.SIGTERM)
 